@@ -13,6 +13,7 @@ import (
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/blocks"
 	"github.com/protolambda/rumor/control/actor/chain"
+	"github.com/protolambda/rumor/control/actor/crawl"
 	"github.com/protolambda/rumor/control/actor/dv5"
 	"github.com/protolambda/rumor/control/actor/enr"
 	"github.com/protolambda/rumor/control/actor/gossip"
@@ -24,7 +25,10 @@ import (
 	"github.com/protolambda/rumor/control/actor/rpc"
 	"github.com/protolambda/rumor/control/actor/states"
 	"github.com/protolambda/rumor/p2p/addrutil"
+	"github.com/protolambda/rumor/p2p/nettrace"
+	"github.com/protolambda/rumor/p2p/peering"
 	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/types"
 	"github.com/sirupsen/logrus"
 	"sync"
 	"time"
@@ -50,6 +54,17 @@ type Actor struct {
 
 	PeerStatusState   status.PeerStatusState
 	PeerMetadataState metadata.PeerMetadataState
+	PeerManagerState  peer.PeerManagerState
+
+	// Banned peers, enforced by the connection gater of the host
+	Bans *peering.BanList
+	// Allow and deny rules, enforced by the connection gater of the host
+	GateRules *peering.GateRules
+	// Peer scores, peers are banned when their score drops too low
+	Scorer *peering.Scorer
+
+	// Traces connections, protocol negotiation, identify results and stream resets of the host
+	NetTracer *nettrace.Tracer
 
 	ChainState chain.ChainState
 
@@ -58,7 +73,8 @@ type Actor struct {
 	GossipState gossip.GossipState
 	RPCState    rpc.RPCState
 
-	HostState host.HostState
+	HostState      host.HostState
+	BandwidthState host.BandwidthState
 
 	LazyEnrState enr.LazyEnrState
 
@@ -78,6 +94,10 @@ func NewActor(id ActorID, globals *GlobalActorData) *Actor {
 		actorCancel:      cancelAll,
 		CurrentPeerstore: track.NewDynamicPeerstore(),
 	}
+	act.Bans = peering.NewBanList()
+	act.GateRules = peering.NewGateRules()
+	act.Scorer = peering.NewScorer(peering.DefaultScoreParams(), act.Bans)
+	act.NetTracer = nettrace.NewTracer()
 	return act
 }
 
@@ -106,6 +126,15 @@ func (r *Actor) SetPriv(priv *crypto.Secp256k1PrivateKey) error {
 	return nil
 }
 
+// CurrentChain returns the chain the actor is currently on.
+func (r *Actor) CurrentChain() (chaindata.FullChain, error) {
+	ch, ok := r.GlobalChains.Find(r.ChainState.CurrentChain)
+	if !ok {
+		return nil, fmt.Errorf("current chain %q was not found. Use 'chain create' to create chains", r.ChainState.CurrentChain)
+	}
+	return ch, nil
+}
+
 func (r *Actor) GetNode() (n *enode.Node, ok bool) {
 	if current := r.LazyEnrState.Current; current != nil {
 		return current.GetNode(), true
@@ -114,6 +143,27 @@ func (r *Actor) GetNode() (n *enode.Node, ok bool) {
 	}
 }
 
+// Attnets returns the attnets of the local ENR (zero if there is no ENR or entry), and of the local metadata.
+func (r *Actor) Attnets() (enrAttnets types.AttnetBits, metaAttnets types.AttnetBits) {
+	if current := r.LazyEnrState.Current; current != nil {
+		if dat, exists, err := addrutil.ParseEnrAttnets(current.GetNode()); err == nil && exists {
+			enrAttnets = *dat
+		}
+	}
+	return enrAttnets, r.PeerMetadataState.Local().Attnets
+}
+
+// SetAttnets updates the attnets of the local ENR, if any, and of the local metadata. Nil attnets are left unchanged.
+// The metadata seq number is incremented if the attnets changed.
+func (r *Actor) SetAttnets(enrAttnets *types.AttnetBits, metaAttnets *types.AttnetBits) {
+	if current := r.LazyEnrState.Current; current != nil && enrAttnets != nil {
+		current.SetAttnets(enrAttnets)
+	}
+	if metaAttnets != nil {
+		r.PeerMetadataState.SetAttnets(*metaAttnets)
+	}
+}
+
 func (r *Actor) MakeCmd(log logrus.FieldLogger, control base.Control) *ActorCmd {
 	return &ActorCmd{
 		Actor:   r,
@@ -162,6 +212,12 @@ func (c *ActorCmd) Cmd(route string) (cmd interface{}, err error) {
 			WithCloseHost:    &c.HostState,
 			GlobalPeerstores: c.GlobalPeerstores,
 			CurrentPeerstore: c.CurrentPeerstore,
+			RPCStats:         &c.RPCState.Stats,
+			Bandwidth:        &c.BandwidthState,
+			Gater:            peering.Gaters{c.Bans, c.GateRules},
+			GateRules:        c.GateRules,
+			Scorer:           c.Scorer,
+			NetTracer:        c.NetTracer,
 		}
 	case "enr":
 		cmd = &enr.EnrCmd{Base: b, Lazy: &c.LazyEnrState, PrivSettings: c, WithHostPriv: &c.HostState}
@@ -174,6 +230,10 @@ func (c *ActorCmd) Cmd(route string) (cmd interface{}, err error) {
 			Base:              b,
 			PeerStatusState:   &c.PeerStatusState,
 			PeerMetadataState: &c.PeerMetadataState,
+			PeerManagerState:  &c.PeerManagerState,
+			Dv5State:          &c.Dv5State,
+			Bans:              c.Bans,
+			Scorer:            c.Scorer,
 			Store:             store,
 		}
 	case "peerstore":
@@ -189,7 +249,13 @@ func (c *ActorCmd) Cmd(route string) (cmd interface{}, err error) {
 		}
 		cmd = &dv5.Dv5Cmd{Base: b, Dv5State: &c.Dv5State, Dv5Settings: settings, CurrentPeerstore: c.CurrentPeerstore}
 	case "gossip":
-		cmd = &gossip.GossipCmd{Base: b, GossipState: &c.GossipState}
+		var store track.GossipStatsBook
+		if c.CurrentPeerstore.Initialized() {
+			store = c.CurrentPeerstore
+		}
+		cmd = &gossip.GossipCmd{Base: b, GossipState: &c.GossipState, WithEnrNode: c,
+			GetChain: c.CurrentChain, GetAttnets: c.Attnets, SetAttnets: c.SetAttnets, Blocks: c.Blocks, Scorer: c.Scorer,
+			Store: store, OnValidation: c.Scorer.OnValidation}
 	case "rpc":
 		cmd = &rpc.RpcCmd{Base: b, RPCState: &c.RPCState}
 	case "blocks":
@@ -199,6 +265,12 @@ func (c *ActorCmd) Cmd(route string) (cmd interface{}, err error) {
 	case "chain":
 		cmd = &chain.ChainCmd{Base: b, Chains: c.GlobalChains,
 			ChainState: &c.ChainState, Blocks: c.Blocks, States: c.States}
+	case "crawl":
+		if !c.CurrentPeerstore.Initialized() {
+			return nil, errors.New("Not available. Create a peerstore first.")
+		}
+		cmd = &crawl.CrawlCmd{Base: b, Dv5State: &c.Dv5State, PeerStatusState: &c.PeerStatusState,
+			PeerMetadataState: &c.PeerMetadataState, Store: c.CurrentPeerstore}
 	case "sleep":
 		cmd = &SleepCmd{Base: b}
 	default:
@@ -208,7 +280,7 @@ func (c *ActorCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 var topRoutes = []string{"host", "enr", "peer", "peerstore", "dv5", "gossip",
-	"rpc", "blocks", "states", "chain", "sleep"}
+	"rpc", "blocks", "states", "chain", "crawl", "sleep"}
 var topRoutesMap = map[string]struct{}{}
 
 func init() {
@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
-	"github.com/libp2p/go-libp2p-core/protocol"
 	"github.com/protolambda/rumor/chain"
 	bdb "github.com/protolambda/rumor/chain/db/blocks"
 	"github.com/protolambda/rumor/control/actor/base"
@@ -22,16 +22,18 @@ type ByRangeCmd struct {
 	Blocks bdb.DB
 	Chain  chain.FullChain
 
-	Timeout     time.Duration         `ask:"--timeout" help:"Timeout for full request and response. 0 to disable"`
-	Compression flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
+	Timeout   time.Duration       `ask:"--timeout" help:"Timeout for full request and response. 0 to disable"`
+	Encodings flags.EncodingsFlag `ask:"--encodings" help:"Comma-separated encodings to serve, e.g. 'ssz,ssz_snappy'. 'all' for all registered encodings"`
 
 	MaxCount uint64 `ask:"--max-count" help:"Max count param in range requests"`
 	MaxStep  uint64 `ask:"--max-step" help:"Max step param in range requests"`
+
+	flags.RateLimitFlags `ask:"."`
 }
 
 func (c *ByRangeCmd) Default() {
 	c.Timeout = 20 * time.Second
-	c.Compression.Compression = reqresp.SnappyCompression{}
+	c.RateLimitFlags = flags.DefaultRateLimit()
 	c.MaxCount = 100
 	c.MaxStep = 10
 }
@@ -54,10 +56,6 @@ func (c *ByRangeCmd) Run(ctx context.Context, args ...string) error {
 		return reqCtx
 	}
 	method := &methods.BlocksByRangeRPCv1
-	prot := method.Protocol
-	if c.Compression.Compression != nil {
-		prot += protocol.ID("_" + c.Compression.Compression.Name())
-	}
 	listenReq := func(ctx context.Context, peerId peer.ID, handler reqresp.ChunkedRequestHandler) {
 		f := map[string]interface{}{
 			"from": peerId.String(),
@@ -122,13 +120,17 @@ func (c *ByRangeCmd) Run(ctx context.Context, args ...string) error {
 			}
 		}
 	}
-	streamHandler := method.MakeStreamHandler(sCtxFn, c.Compression.Compression, listenReq)
-	h.SetStreamHandler(prot, streamHandler)
-	c.Log.WithField("started", true).Infof("Started by-range serving")
+	limit := c.LimitStreamHandlers(bgCtx, c.Log, h.NewStream)
+	protocols := method.SetStreamHandlers(h.SetStreamHandler, c.Encodings.Selected(), func(enc *reqresp.Encoding) network.StreamHandler {
+		return limit(enc, method.MakeStreamHandler(sCtxFn, enc, listenReq))
+	})
+	c.Log.WithField("started", true).WithField("protocols", protocols).Infof("Started by-range serving")
 
 	c.Control.RegisterStop(func(ctx context.Context) error {
 		bgCancel()
-		h.RemoveStreamHandler(prot)
+		for _, prot := range protocols {
+			h.RemoveStreamHandler(prot)
+		}
 		c.Log.Infof("Stopped by-range serving")
 		return nil
 	})
@@ -3,7 +3,6 @@ package sync
 import (
 	"context"
 	"fmt"
-	"github.com/libp2p/go-libp2p-core/protocol"
 	"github.com/protolambda/rumor/chain"
 	bdb "github.com/protolambda/rumor/chain/db/blocks"
 	"github.com/protolambda/rumor/control/actor/base"
@@ -26,7 +25,7 @@ type ByRangeCmd struct {
 	Step           uint64                `ask:"--step" help:"Step between slots of blocks of request"`
 	Timeout        time.Duration         `ask:"--timeout" help:"Timeout for full request and response. 0 to disable"`
 	ProcessTimeout time.Duration         `ask:"--process-timeout" help:"Timeout for parallel processing of blocks. 0 to disable."`
-	Compression    flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
+	Compression    flags.CompressionFlag `ask:"--compression" help:"Encoding. 'ssz' (or 'none') for uncompressed, 'ssz_snappy' (or 'snappy') for streaming-snappy, or any other registered encoding"`
 	Store          bool                  `ask:"--store" help:"If the blocks should be stored in the blocks DB"`
 	Process        bool                  `ask:"--process" help:"If the blocks should be added to the current chain view, ignored otherwise"`
 }
@@ -34,7 +33,7 @@ type ByRangeCmd struct {
 func (c *ByRangeCmd) Default() {
 	c.Timeout = 20 * time.Second
 	c.ProcessTimeout = 20 * time.Second
-	c.Compression.Compression = reqresp.SnappyCompression{}
+	c.Compression.Encoding = reqresp.SSZSnappyEncoding
 	c.Store = true
 	c.Process = true
 }
@@ -58,10 +57,7 @@ func (c *ByRangeCmd) Run(ctx context.Context, args ...string) error {
 	method := &methods.BlocksByRangeRPCv1
 	peerId := c.PeerID.PeerID
 
-	protocolId := method.Protocol
-	if c.Compression.Compression != nil {
-		protocolId += protocol.ID("_" + c.Compression.Compression.Name())
-	}
+	protocolId := method.ProtocolID(c.Compression.Encoding)
 
 	pstore := h.Peerstore()
 	if protocols, err := pstore.SupportsProtocols(peerId, string(protocolId)); err != nil {
@@ -89,7 +85,7 @@ func (c *ByRangeCmd) Run(ctx context.Context, args ...string) error {
 		Process: c.Process,
 	}.handle(procCtx, func(blocksCh chan<- *beacon.SignedBeaconBlock) error {
 
-		return method.RunRequest(reqCtx, sFn, peerId, c.Compression.Compression, reqresp.RequestSSZInput{Obj: &req}, req.Count,
+		return method.RunRequest(reqCtx, sFn, peerId, c.Compression.Encoding, reqresp.RequestSSZInput{Obj: &req}, req.Count,
 			func() error {
 				// TODO
 				return nil
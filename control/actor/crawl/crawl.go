@@ -0,0 +1,414 @@
+package crawl
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/dv5"
+	"github.com/protolambda/rumor/control/actor/flags"
+	"github.com/protolambda/rumor/control/actor/peer/metadata"
+	"github.com/protolambda/rumor/control/actor/peer/status"
+	"github.com/protolambda/rumor/p2p/addrutil"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/filter"
+	"sync"
+	"time"
+)
+
+type CrawlStats struct {
+	// Nodes found with discv5, including duplicates
+	Discovered uint64 `json:"discovered"`
+	// Nodes that did not match the filter
+	Filtered uint64 `json:"filtered"`
+	// Nodes that were crawled, or are queued to be crawled
+	Crawled   uint64 `json:"crawled"`
+	Succeeded uint64 `json:"succeeded"`
+	// Step -> number of crawls that failed the step
+	Failed map[string]uint64 `json:"failed"`
+}
+
+type CrawlCmd struct {
+	*base.Base
+	*dv5.Dv5State
+	*status.PeerStatusState
+	*metadata.PeerMetadataState
+	Store track.ExtendedPeerstore
+
+	Parallel       int                   `ask:"--parallel" help:"Max number of peers to crawl at the same time"`
+	Timeout        time.Duration         `ask:"--timeout" help:"Timeout of each crawl step: dial, identify, status and metadata"`
+	LookupInterval time.Duration         `ask:"--lookup-interval" help:"Interval between discv5 lookups of random targets, in addition to the random node walk. 0 to disable."`
+	Recrawl        time.Duration         `ask:"--recrawl" help:"Time before a rediscovered node is crawled again. 0 to crawl each node only once."`
+	Filter         []string              `ask:"--filter" help:"Only crawl nodes that match all of these filter conditions on their ENR data, comma-separated, see 'peer list'"`
+	TTL            time.Duration         `ask:"--ttl" help:"TTL of the addresses of discovered nodes in the peerstore"`
+	Progress       time.Duration         `ask:"--progress" help:"Interval to log the crawl progress at"`
+	Disconnect     bool                  `ask:"--disconnect" help:"Disconnect peers after crawling them, if not connected before"`
+	Compression    flags.CompressionFlag `ask:"--compression" help:"Encoding of the status and metadata requests"`
+}
+
+func (c *CrawlCmd) Default() {
+	c.Parallel = 16
+	c.Timeout = 10 * time.Second
+	c.LookupInterval = 30 * time.Second
+	c.TTL = time.Hour * 24 * 30 * 3
+	c.Progress = 10 * time.Second
+	c.Disconnect = true
+	c.Compression.Encoding = reqresp.SSZSnappyEncoding
+}
+
+func (c *CrawlCmd) Help() string {
+	return "Crawl the network, until the command is stopped. Nodes are discovered with discv5, added to the peerstore, and dialed. " +
+		"Each peer is then identified, and requested for its status and metadata. " +
+		"The results are recorded in the peerstore, see the 'crawl' data in 'peer info' and 'peer list'. " +
+		"Requires discv5 to run, see 'dv5 run'."
+}
+
+type crawler struct {
+	*CrawlCmd
+	h      host.Host
+	filter *filter.Filter
+	queue  chan *enode.Node
+
+	lock  sync.Mutex
+	stats CrawlStats
+	// peer -> time of the last crawl
+	seen map[peer.ID]time.Time
+	// peer -> channel for the identify result, for peers that are being dialed
+	identifying map[peer.ID]chan error
+}
+
+func (c *CrawlCmd) Run(ctx context.Context, args ...string) error {
+	if c.Dv5State.Dv5Node == nil {
+		return dv5.NoDv5Err
+	}
+	h, err := c.Host()
+	if err != nil {
+		return err
+	}
+	f, err := filter.Parse(c.Filter...)
+	if err != nil {
+		return err
+	}
+	if c.Parallel < 1 {
+		return errors.New("need at least 1 parallel crawl")
+	}
+	sub, err := h.EventBus().Subscribe([]interface{}{
+		new(event.EvtPeerIdentificationCompleted), new(event.EvtPeerIdentificationFailed)})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to identify events: %v", err)
+	}
+	cr := &crawler{
+		CrawlCmd:    c,
+		h:           h,
+		filter:      f,
+		queue:       make(chan *enode.Node, c.Parallel),
+		stats:       CrawlStats{Failed: make(map[string]uint64)},
+		seen:        make(map[peer.ID]time.Time),
+		identifying: make(map[peer.ID]chan error),
+	}
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	start := time.Now()
+
+	var identifyWg sync.WaitGroup
+	identifyWg.Add(1)
+	go func() {
+		defer identifyWg.Done()
+		for ev := range sub.Out() {
+			switch ev := ev.(type) {
+			case event.EvtPeerIdentificationCompleted:
+				cr.identified(ev.Peer, nil)
+			case event.EvtPeerIdentificationFailed:
+				cr.identified(ev.Peer, ev.Reason)
+			}
+		}
+	}()
+
+	var workersWg sync.WaitGroup
+	for i := 0; i < c.Parallel; i++ {
+		workersWg.Add(1)
+		go func() {
+			defer workersWg.Done()
+			for n := range cr.queue {
+				cr.crawl(bgCtx, n)
+			}
+		}()
+	}
+
+	var discoverWg sync.WaitGroup
+	randomNodes := c.Dv5State.Dv5Node.RandomNodes()
+	discoverWg.Add(1)
+	go func() {
+		defer discoverWg.Done()
+		for randomNodes.Next() {
+			cr.discovered(bgCtx, randomNodes.Node())
+		}
+	}()
+	if c.LookupInterval > 0 {
+		discoverWg.Add(1)
+		go func() {
+			defer discoverWg.Done()
+			ticker := time.NewTicker(c.LookupInterval)
+			defer ticker.Stop()
+			for {
+				var target enode.ID
+				_, _ = rand.Read(target[:])
+				for _, n := range c.Dv5State.Dv5Node.Lookup(target) {
+					cr.discovered(bgCtx, n)
+				}
+				select {
+				case <-ticker.C:
+				case <-bgCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		if c.Progress <= 0 {
+			return
+		}
+		ticker := time.NewTicker(c.Progress)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Log.WithField("stats", cr.getStats()).Info("crawl progress")
+			case <-bgCtx.Done():
+				return
+			}
+		}
+	}()
+	c.Log.WithField("parallel", c.Parallel).Info("Started crawling")
+
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		randomNodes.Close()
+		discoverWg.Wait()
+		close(cr.queue)
+		workersWg.Wait()
+		_ = sub.Close()
+		identifyWg.Wait()
+		<-progressDone
+		c.Log.WithField("stats", cr.getStats()).WithField("duration", time.Since(start).String()).
+			Info("Stopped crawling")
+		return nil
+	})
+	return nil
+}
+
+func (cr *crawler) getStats() CrawlStats {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	out := cr.stats
+	out.Failed = make(map[string]uint64, len(cr.stats.Failed))
+	for k, v := range cr.stats.Failed {
+		out.Failed[k] = v
+	}
+	return out
+}
+
+func (cr *crawler) identified(id peer.ID, err error) {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	if ch, ok := cr.identifying[id]; ok {
+		ch <- err
+		delete(cr.identifying, id)
+	}
+}
+
+// discovered adds the node to the peerstore, and queues it to be crawled.
+func (cr *crawler) discovered(ctx context.Context, n *enode.Node) {
+	if n == nil {
+		return
+	}
+	id := addrutil.PeerIDFromPubkey(n.Pubkey())
+	now := time.Now()
+	cr.lock.Lock()
+	cr.stats.Discovered += 1
+	if !cr.filter.Match(filter.ENRPeer(n)) {
+		cr.stats.Filtered += 1
+		cr.lock.Unlock()
+		return
+	}
+	last, seen := cr.seen[id]
+	if id == cr.h.ID() || (seen && (cr.Recrawl == 0 || now.Sub(last) < cr.Recrawl)) {
+		cr.lock.Unlock()
+		return
+	}
+	cr.seen[id] = now
+	cr.stats.Crawled += 1
+	cr.lock.Unlock()
+
+	if updated, err := cr.Store.UpdateENRMaybe(id, n); err != nil {
+		cr.Log.WithError(err).WithField("peer", id.Pretty()).Debug("failed to update ENR")
+	} else if updated && n.TCP() != 0 {
+		if addr, err := addrutil.EnodeToMultiAddr(n); err == nil {
+			// the swarm dials transport addresses, without the /p2p/ part
+			if transport, _ := peer.SplitAddr(addr); transport != nil {
+				cr.Store.SetAddr(id, transport, cr.TTL)
+			}
+		}
+	}
+	select {
+	case cr.queue <- n:
+	case <-ctx.Done():
+	}
+}
+
+func (cr *crawler) crawl(ctx context.Context, n *enode.Node) {
+	id := addrutil.PeerIDFromPubkey(n.Pubkey())
+	rec := &track.CrawlRecord{Time: time.Now(), Errors: make(map[string]string)}
+	fail := func(step string, err error) {
+		rec.Errors[step] = err.Error()
+	}
+	wasConnected := cr.h.Network().Connectedness(id) == network.Connected
+
+	identifyCh := make(chan error, 1)
+	if !wasConnected {
+		cr.lock.Lock()
+		cr.identifying[id] = identifyCh
+		cr.lock.Unlock()
+	}
+	if n.TCP() == 0 {
+		fail("dial", errors.New("no TCP port in ENR"))
+	} else if !wasConnected {
+		dialCtx, cancel := context.WithTimeout(ctx, cr.Timeout)
+		if err := cr.h.Connect(dialCtx, cr.Store.PeerInfo(id)); err != nil {
+			fail("dial", err)
+		}
+		cancel()
+	}
+	if _, failed := rec.Errors["dial"]; !failed {
+		if err := cr.identify(ctx, id, wasConnected, identifyCh); err != nil {
+			fail("identify", err)
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, cr.Timeout)
+		if err := cr.requestStatus(reqCtx, id); err != nil {
+			fail("status", err)
+		}
+		cancel()
+		reqCtx, cancel = context.WithTimeout(ctx, cr.Timeout)
+		if err := cr.requestMetadata(reqCtx, id); err != nil {
+			fail("metadata", err)
+		}
+		cancel()
+		if cr.Disconnect && !wasConnected {
+			cr.Store.SetDisconnectReason(id, "crawled")
+			_ = cr.h.Network().ClosePeer(id)
+		}
+	}
+	cr.lock.Lock()
+	delete(cr.identifying, id)
+	cr.lock.Unlock()
+	if ctx.Err() != nil {
+		// stopped while crawling, the errors are not the fault of the peer
+		return
+	}
+	rec.Duration = time.Since(rec.Time)
+	rec.Success = len(rec.Errors) == 0
+	if rec.Success {
+		rec.Errors = nil
+	}
+	cr.Store.RegisterCrawl(id, rec)
+
+	cr.lock.Lock()
+	if rec.Success {
+		cr.stats.Succeeded += 1
+	}
+	for step := range rec.Errors {
+		cr.stats.Failed[step] += 1
+	}
+	cr.lock.Unlock()
+	cr.Log.WithField("peer", id.Pretty()).WithField("errors", rec.Errors).Debug("crawled peer")
+}
+
+// identify waits for the identify protocol, which runs on every new connection.
+// Peers that were already connected should be identified already.
+func (cr *crawler) identify(ctx context.Context, id peer.ID, wasConnected bool, ch chan error) error {
+	if wasConnected {
+		if _, err := cr.Store.Get(id, "AgentVersion"); err != nil {
+			return errors.New("connected peer was not identified")
+		}
+		return nil
+	}
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(cr.Timeout):
+		return errors.New("timed out waiting for identify")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (cr *crawler) requestStatus(ctx context.Context, id peer.ID) error {
+	local := cr.PeerStatusState.Local()
+	res := errors.New("no response")
+	err := methods.StatusRPCv1.RunRequest(ctx, cr.h.NewStream, id, cr.Compression.Encoding,
+		reqresp.RequestSSZInput{Obj: &local}, 1,
+		func() error {
+			return nil
+		},
+		func(chunk reqresp.ChunkedResponseHandler) error {
+			if code := chunk.ResultCode(); code != reqresp.SuccessCode {
+				msg, err := chunk.ReadErrMsg()
+				if err != nil {
+					return err
+				}
+				res = fmt.Errorf("response code %d: %s", code, msg)
+				return nil
+			}
+			var stat methods.Status
+			if err := chunk.ReadObj(&stat); err != nil {
+				return err
+			}
+			cr.Store.RegisterStatus(id, stat)
+			res = nil
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	return res
+}
+
+func (cr *crawler) requestMetadata(ctx context.Context, id peer.ID) error {
+	res := errors.New("no response")
+	err := methods.MetaDataRPCv1.RunRequest(ctx, cr.h.NewStream, id, cr.Compression.Encoding,
+		reqresp.RequestSSZInput{Obj: nil}, 1,
+		func() error {
+			return nil
+		},
+		func(chunk reqresp.ChunkedResponseHandler) error {
+			if code := chunk.ResultCode(); code != reqresp.SuccessCode {
+				msg, err := chunk.ReadErrMsg()
+				if err != nil {
+					return err
+				}
+				res = fmt.Errorf("response code %d: %s", code, msg)
+				return nil
+			}
+			var meta methods.MetaData
+			if err := chunk.ReadObj(&meta); err != nil {
+				return err
+			}
+			cr.Store.RegisterMetadata(id, meta)
+			res = nil
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	return res
+}
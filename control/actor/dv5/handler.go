@@ -6,6 +6,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/protolambda/rumor/p2p/addrutil"
 	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/filter"
 	"github.com/protolambda/zrnt/eth2/beacon"
 	"github.com/sirupsen/logrus"
 	"time"
@@ -17,7 +18,20 @@ type HandleENR struct {
 	Add          bool              `ask:"--add" help:"Add the discovered nodes to the peerstore (requires peerstore to use)"`
 	FilterDigest beacon.ForkDigest `ask:"--filter-digest" help:"Only add peers with the given digest to the peerstore"`
 	TTL          time.Duration     `ask:"--ttl" help:"When adding the node, apply this TTL"`
+	Filter       []string          `ask:"--filter" help:"Only add nodes that match all of these filter conditions on their ENR data, comma-separated, see 'peer list'. E.g. 'attnets has 12'"`
 	Filtering    bool              `changed:"filter-digest"`
+
+	filter *filter.Filter
+}
+
+// parseFilter parses the filter conditions, before nodes are handled.
+func (c *HandleENR) parseFilter() error {
+	f, err := filter.Parse(c.Filter...)
+	if err != nil {
+		return err
+	}
+	c.filter = f
+	return nil
 }
 
 func (c *HandleENR) handle(log logrus.FieldLogger, res *enode.Node) error {
@@ -40,6 +54,10 @@ func (c *HandleENR) handle(log logrus.FieldLogger, res *enode.Node) error {
 				return fmt.Errorf("got ENR with other fork digest: %s", eth2Dat.ForkDigest.String())
 			}
 		}
+		if c.filter != nil && !c.filter.Match(filter.ENRPeer(res)) {
+			log.WithField("id", res.ID().String()).Debug("ENR does not match filter, not adding it")
+			return nil
+		}
 		updated, err := c.Store.UpdateENRMaybe(peerID, res)
 		if err != nil {
 			return fmt.Errorf("enr update error: %v", err)
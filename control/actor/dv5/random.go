@@ -27,6 +27,9 @@ func (c *Dv5RandomCmd) Run(ctx context.Context, args ...string) error {
 	if c.Dv5State.Dv5Node == nil {
 		return NoDv5Err
 	}
+	if err := c.HandleENR.parseFilter(); err != nil {
+		return err
+	}
 	randomNodes := c.Dv5State.Dv5Node.RandomNodes()
 	c.Log.Infof("Started looking for random nodes: %s", time.Now().String())
 
@@ -0,0 +1,29 @@
+package flags
+
+import (
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+)
+
+type GoodbyeFlag struct {
+	Reason methods.Goodbye
+}
+
+func (f *GoodbyeFlag) String() string {
+	if f == nil {
+		return "nil goodbye reason"
+	}
+	return f.Reason.Name()
+}
+
+func (f *GoodbyeFlag) Set(v string) error {
+	reason, err := methods.ParseGoodbye(v)
+	if err != nil {
+		return err
+	}
+	f.Reason = reason
+	return nil
+}
+
+func (f *GoodbyeFlag) Type() string {
+	return "goodbye reason"
+}
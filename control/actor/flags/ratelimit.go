@@ -0,0 +1,124 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type RateLimitAction string
+
+const (
+	// Respond with a server-error chunk
+	RateLimitError RateLimitAction = "error"
+	// Send a goodbye to the peer, and close the stream
+	RateLimitGoodbye RateLimitAction = "goodbye"
+	// Close the stream without response
+	RateLimitDrop RateLimitAction = "drop"
+)
+
+func (f *RateLimitAction) String() string {
+	if f == nil {
+		return "nil rate limit action"
+	}
+	return string(*f)
+}
+
+func (f *RateLimitAction) Set(v string) error {
+	switch a := RateLimitAction(v); a {
+	case RateLimitError, RateLimitGoodbye, RateLimitDrop:
+		*f = a
+		return nil
+	default:
+		return fmt.Errorf("unrecognized rate limit action: %s", v)
+	}
+}
+
+func (f *RateLimitAction) Type() string {
+	return "rate limit action"
+}
+
+// RateLimitFlags configures a per-peer rate limit for a served protocol.
+// Embed it in a command with `ask:"."`, to add the rate limit options to the command.
+type RateLimitFlags struct {
+	RateLimit   uint64          `ask:"--rate-limit" help:"Max requests per peer per rate period. 0 to disable rate limiting."`
+	RatePeriod  time.Duration   `ask:"--rate-period" help:"The period the rate limit applies to"`
+	RateBurst   uint64          `ask:"--rate-burst" help:"Max requests per peer in a burst. If 0, it defaults to the rate limit."`
+	RateAction  RateLimitAction `ask:"--rate-action" help:"What to do with requests over quota: 'error' (server-error chunk), 'goodbye' (send goodbye, close stream) or 'drop' (close stream)"`
+	RateTimeout time.Duration   `ask:"--rate-timeout" help:"Timeout for responding to a request over quota"`
+}
+
+// DefaultRateLimit is the default for rate limit flags: disabled, but with sane defaults when enabled.
+func DefaultRateLimit() RateLimitFlags {
+	return RateLimitFlags{
+		RateLimit:   0,
+		RatePeriod:  time.Second,
+		RateBurst:   0,
+		RateAction:  RateLimitError,
+		RateTimeout: 5 * time.Second,
+	}
+}
+
+// Limiter returns a new rate limiter, or nil if rate limiting is disabled.
+func (f *RateLimitFlags) Limiter() *reqresp.PeerRateLimiter {
+	if f.RateLimit == 0 || f.RatePeriod == 0 {
+		return nil
+	}
+	return reqresp.NewPeerRateLimiter(f.RateLimit, f.RatePeriod, f.RateBurst)
+}
+
+// LimitStreamHandlerFn wraps a stream handler of the given encoding with rate limiting.
+type LimitStreamHandlerFn func(enc *reqresp.Encoding, handler network.StreamHandler) network.StreamHandler
+
+// LimitStreamHandlers creates a new rate limiter, shared by all stream handlers that are wrapped with the returned function.
+// Handlers are returned as-is if rate limiting is disabled.
+// The encoding of the handler is used to respond to requests over quota.
+// The limiter forgets about idle peers periodically, until the context is done.
+func (f *RateLimitFlags) LimitStreamHandlers(ctx context.Context, log logrus.FieldLogger, newStreamFn reqresp.NewStreamFn) LimitStreamHandlerFn {
+	limiter := f.Limiter()
+	if limiter != nil {
+		go limiter.PruneEvery(ctx, time.Minute)
+	}
+	return func(enc *reqresp.Encoding, handler network.StreamHandler) network.StreamHandler {
+		if limiter == nil {
+			return handler
+		}
+		onLimit := func(peerId peer.ID, stream network.Stream) {
+			l := log.WithFields(logrus.Fields{
+				"from":     peerId.String(),
+				"protocol": stream.Protocol(),
+				"action":   f.RateAction,
+			})
+			switch f.RateAction {
+			case RateLimitError:
+				if err := reqresp.WriteRateLimitedErr(stream, enc.Compression, f.RateTimeout); err != nil {
+					l.WithError(err).Debug("failed to respond to rate limited request")
+				}
+			case RateLimitGoodbye:
+				ctx, cancel := context.WithCancel(ctx)
+				defer cancel()
+				if f.RateTimeout != 0 {
+					ctx, cancel = context.WithTimeout(ctx, f.RateTimeout)
+					defer cancel()
+				}
+				reason := methods.GoodbyeFaultError
+				// no response to a goodbye, only wait for the request to be written.
+				if err := methods.GoodbyeRPCv1.RunRequest(ctx, newStreamFn, peerId, enc,
+					reqresp.RequestSSZInput{Obj: &reason}, 0, func() error {
+						return nil
+					}, func(chunk reqresp.ChunkedResponseHandler) error {
+						return nil
+					}); err != nil {
+					l.WithError(err).Debug("failed to send goodbye to rate limited peer")
+				}
+			}
+			l.Warn("rate limited request")
+		}
+		return reqresp.LimitStreamHandler(limiter, onLimit, handler)
+	}
+}
@@ -3,34 +3,95 @@ package flags
 import (
 	"fmt"
 	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"strings"
 )
 
+// CompressionFlag selects the encoding of a request.
+// 'none' and 'snappy' are kept as aliases of 'ssz' and 'ssz_snappy'.
 type CompressionFlag struct {
-	Compression reqresp.Compression
+	Encoding *reqresp.Encoding
 }
 
 func (f *CompressionFlag) String() string {
 	if f == nil {
-		return "nil compression"
+		return "nil encoding"
 	}
-	if f.Compression == nil {
+	if f.Encoding == nil {
 		return "none"
 	}
-	return f.Compression.Name()
+	return f.Encoding.Name
+}
+
+func parseEncoding(v string) (*reqresp.Encoding, error) {
+	switch v {
+	case "snappy":
+		return reqresp.SSZSnappyEncoding, nil
+	case "none", "":
+		return reqresp.SSZEncoding, nil
+	}
+	if enc := reqresp.Encodings.Get(v); enc != nil {
+		return enc, nil
+	}
+	return nil, fmt.Errorf("unrecognized encoding: %s", v)
 }
 
 func (f *CompressionFlag) Set(v string) error {
-	if v == "snappy" {
-		f.Compression = reqresp.SnappyCompression{}
-		return nil
-	} else if v == "none" || v == "" {
-		f.Compression = nil
-		return nil
-	} else {
-		return fmt.Errorf("unrecognized compression: %s", v)
+	enc, err := parseEncoding(v)
+	if err != nil {
+		return err
 	}
+	f.Encoding = enc
+	return nil
 }
 
 func (f *CompressionFlag) Type() string {
-	return "RPC compression"
+	return "RPC encoding"
+}
+
+// EncodingsFlag selects the encodings to serve. Nil to serve all registered encodings.
+type EncodingsFlag struct {
+	Encodings []*reqresp.Encoding
+}
+
+// Selected returns the selected encodings, or all registered encodings if none were selected.
+func (f *EncodingsFlag) Selected() []*reqresp.Encoding {
+	if f.Encodings == nil {
+		return reqresp.Encodings.All()
+	}
+	return f.Encodings
+}
+
+func (f *EncodingsFlag) String() string {
+	if f == nil {
+		return "nil encodings"
+	}
+	if f.Encodings == nil {
+		return "all"
+	}
+	names := make([]string, 0, len(f.Encodings))
+	for _, enc := range f.Encodings {
+		names = append(names, enc.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (f *EncodingsFlag) Set(v string) error {
+	if v == "all" || v == "" {
+		f.Encodings = nil
+		return nil
+	}
+	var out []*reqresp.Encoding
+	for _, name := range strings.Split(v, ",") {
+		enc, err := parseEncoding(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		out = append(out, enc)
+	}
+	f.Encodings = out
+	return nil
+}
+
+func (f *EncodingsFlag) Type() string {
+	return "RPC encodings"
 }
@@ -0,0 +1,38 @@
+package gossip
+
+import (
+	"context"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/gossip"
+)
+
+type GossipDigestCmd struct {
+	*base.Base
+	base.WithEnrNode
+	GetChain gossip.ChainFn
+	Topic    string `ask:"[topic]" help:"Optionally, a short topic name to expand with the digest, e.g. 'attestation:5'"`
+}
+
+func (c *GossipDigestCmd) Help() string {
+	return "Show the current fork digest, computed from the current chain or taken from the ENR, and the next fork if any"
+}
+
+func (c *GossipDigestCmd) Run(ctx context.Context, args ...string) error {
+	digest, source, err := currentForkDigest(ctx, c.GetChain, c.WithEnrNode)
+	if err != nil {
+		return err
+	}
+	l := c.Log.WithField("fork_digest", digest).WithField("source", source)
+	if fork, err := nextFork(ctx, c.GetChain, c.WithEnrNode); err == nil {
+		l = l.WithField("next_fork_digest", fork.Digest).WithField("next_fork_epoch", fork.Epoch)
+	}
+	if c.Topic != "" {
+		topic, err := gossip.ExpandTopic(c.Topic, digest)
+		if err != nil {
+			return err
+		}
+		l = l.WithField("topic", topic.String())
+	}
+	l.Info("fork digest")
+	return nil
+}
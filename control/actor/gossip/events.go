@@ -21,11 +21,11 @@ func (c *GossipEventsCmd) Run(ctx context.Context, args ...string) error {
 	if c.GossipState.GsNode == nil {
 		return NoGossipErr
 	}
-	top, ok := c.GossipState.Topics.Load(c.TopicName)
+	_, top, ok := c.GossipState.lookup(c.TopicName)
 	if !ok {
 		return fmt.Errorf("not on gossip topic %s", c.TopicName)
 	}
-	evHandler, err := top.(*pubsub.Topic).EventHandler()
+	evHandler, err := top.EventHandler()
 	if err != nil {
 		return err
 	} else {
@@ -3,32 +3,169 @@ package gossip
 import (
 	"context"
 	"errors"
+	"fmt"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/protolambda/ask"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/addrutil"
 	"github.com/protolambda/rumor/p2p/gossip"
+	"github.com/protolambda/rumor/p2p/peering"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/types"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
 	"sync"
 )
 
 type GossipState struct {
 	GsNode  gossip.GossipSub
 	CloseGS context.CancelFunc
+	// Block propagation tracking, nil if not tracking
+	Blocks *gossip.BlockTracker
+	// Duplicate message tracking, nil if not tracking
+	Dups *gossip.DupTracker
+	// Attestation subnet subscriptions
+	Subnets SubnetsState
 	// string -> *pubsub.Topic
 	Topics sync.Map
+	// short topic name -> full topic name, for topics joined by short name
+	Aliases sync.Map
+	// full topic name -> *topicValidation
+	Validation sync.Map
+
+	subsLock sync.Mutex
+	// full topic name -> number of subscriptions opened by gossip commands
+	subCounts map[string]int
+}
+
+// subscribe opens a subscription on the topic, counted until it is cancelled with unsubscribe.
+func (s *GossipState) subscribe(name string, top *pubsub.Topic) (*pubsub.Subscription, error) {
+	sub, err := top.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+	s.subsLock.Lock()
+	defer s.subsLock.Unlock()
+	if s.subCounts == nil {
+		s.subCounts = make(map[string]int)
+	}
+	s.subCounts[name] += 1
+	return sub, nil
+}
+
+func (s *GossipState) unsubscribe(name string, sub *pubsub.Subscription) {
+	sub.Cancel()
+	s.subsLock.Lock()
+	defer s.subsLock.Unlock()
+	if s.subCounts[name] <= 1 {
+		delete(s.subCounts, name)
+	} else {
+		s.subCounts[name] -= 1
+	}
+}
+
+// subscriptions returns the number of open subscriptions on the topic.
+func (s *GossipState) subscriptions(name string) int {
+	s.subsLock.Lock()
+	defer s.subsLock.Unlock()
+	return s.subCounts[name]
+}
+
+// getOrJoin gets the topic if already joined, or joins it otherwise.
+func (s *GossipState) getOrJoin(name string) (top *pubsub.Topic, joined bool, err error) {
+	if t, ok := s.Topics.Load(name); ok {
+		return t.(*pubsub.Topic), false, nil
+	}
+	top, err = s.GsNode.Join(name)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to join topic %s: %v", name, err)
+	}
+	s.Topics.Store(name, top)
+	return top, true, nil
+}
+
+// lookup finds a joined topic by full name, or by the short name it was joined with.
+func (s *GossipState) lookup(name string) (fullName string, top *pubsub.Topic, ok bool) {
+	fullName = name
+	if full, ok := s.Aliases.Load(name); ok {
+		fullName = full.(string)
+	}
+	t, ok := s.Topics.Load(fullName)
+	if !ok {
+		return fullName, nil, false
+	}
+	return fullName, t.(*pubsub.Topic), true
+}
+
+// resolve finds the full name of the topic: the topic it was joined as if joined by short name,
+// or else a short eth2 topic name expanded with the current fork digest. Other names are returned as-is.
+func (s *GossipState) resolve(ctx context.Context, getChain gossip.ChainFn, enrNode base.WithEnrNode, name string) (string, error) {
+	if fullName, _, ok := s.lookup(name); ok || !gossip.IsShortTopic(name) {
+		return fullName, nil
+	}
+	digest, _, err := currentForkDigest(ctx, getChain, enrNode)
+	if err != nil {
+		return "", fmt.Errorf("cannot expand topic %s: %v", name, err)
+	}
+	topic, err := gossip.ExpandTopic(name, digest)
+	if err != nil {
+		return "", err
+	}
+	return topic.String(), nil
+}
+
+// forget removes a left topic, and any short names pointing to it.
+func (s *GossipState) forget(fullName string) {
+	s.Topics.Delete(fullName)
+	s.Aliases.Range(func(key, value interface{}) bool {
+		if value.(string) == fullName {
+			s.Aliases.Delete(key)
+		}
+		return true
+	})
+}
+
+// publish the uncompressed message data to the topic, joining it if not already.
+func (s *GossipState) publish(ctx context.Context, log logrus.FieldLogger, topic *gossip.Topic, data []byte) error {
+	name := topic.String()
+	top, joined, err := s.getOrJoin(name)
+	if joined {
+		log.Infof("joined topic %s", name)
+	}
+	if err != nil {
+		return err
+	}
+	if err := top.Publish(ctx, topic.Compress(data)); err != nil {
+		return fmt.Errorf("failed to publish message, err: %v", err)
+	}
+	log.WithField("topic", name).WithField("size", len(data)).Info("published message")
+	return nil
 }
 
 type GossipCmd struct {
 	*base.Base
 	*GossipState
+	base.WithEnrNode
+	GetChain   gossip.ChainFn
+	GetAttnets GetAttnetsFn
+	SetAttnets SetAttnetsFn
+	Blocks     bdb.DB
+	Scorer     *peering.Scorer
+	// Peerstore to persist gossip stats in, nil if there is none
+	Store track.GossipStatsBook
+	// Called with the result of every validated message, optional
+	OnValidation gossip.ValidationListener
 }
 
 func (c *GossipCmd) Cmd(route string) (cmd interface{}, err error) {
 	switch route {
 	case "start":
-		cmd = &GossipStartCmd{Base: c.Base, GossipState: c.GossipState}
+		cmd = &GossipStartCmd{Base: c.Base, GossipState: c.GossipState, Scorer: c.Scorer}
 	case "list":
 		cmd = &GossipListCmd{Base: c.Base, GossipState: c.GossipState}
 	case "join":
-		cmd = &GossipJoinCmd{Base: c.Base, GossipState: c.GossipState}
+		cmd = &GossipJoinCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode, GetChain: c.GetChain}
 	case "events":
 		cmd = &GossipEventsCmd{Base: c.Base, GossipState: c.GossipState}
 	case "list-peers":
@@ -41,6 +178,26 @@ func (c *GossipCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = &GossipLogCmd{Base: c.Base, GossipState: c.GossipState}
 	case "publish":
 		cmd = &GossipPublishCmd{Base: c.Base, GossipState: c.GossipState}
+	case "record":
+		cmd = &GossipRecordCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode, GetChain: c.GetChain}
+	case "replay":
+		cmd = &GossipReplayCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode, GetChain: c.GetChain}
+	case "stats":
+		cmd = &GossipStatsCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode, GetChain: c.GetChain,
+			Store: c.Store}
+	case "subnets":
+		cmd = &GossipSubnetsCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode,
+			GetChain: c.GetChain, GetAttnets: c.GetAttnets, SetAttnets: c.SetAttnets}
+	case "mesh":
+		cmd = &GossipMeshCmd{Base: c.Base, GossipState: c.GossipState, Scorer: c.Scorer}
+	case "publish-block":
+		cmd = &GossipPublishBlockCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode, GetChain: c.GetChain, Blocks: c.Blocks}
+	case "publish-object":
+		cmd = &GossipPublishObjectCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode, GetChain: c.GetChain}
+	case "digest":
+		cmd = &GossipDigestCmd{Base: c.Base, WithEnrNode: c.WithEnrNode, GetChain: c.GetChain}
+	case "validate":
+		cmd = &GossipValidateCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode, GetChain: c.GetChain, OnResult: c.OnValidation}
 	default:
 		return nil, ask.UnrecognizedErr
 	}
@@ -48,11 +205,73 @@ func (c *GossipCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *GossipCmd) Routes() []string {
-	return []string{"start", "list", "join", "events", "list-peers", "blacklist", "leave", "log", "publish"}
+	return []string{"start", "list", "join", "events", "list-peers", "blacklist", "leave", "log", "publish", "validate", "record", "replay", "stats", "subnets", "mesh", "publish-block", "publish-object", "digest"}
 }
 
 func (c *GossipCmd) Help() string {
 	return "Manage Libp2p GossipSub"
 }
 
+// chainHeadState gets the head state of the current chain.
+func chainHeadState(ctx context.Context, getChain gossip.ChainFn) (*beacon.BeaconStateView, error) {
+	ch, err := getChain()
+	if err != nil {
+		return nil, err
+	}
+	head, err := ch.Head()
+	if err != nil {
+		return nil, fmt.Errorf("no chain head: %v", err)
+	}
+	state, err := head.State(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no head state: %v", err)
+	}
+	return state, nil
+}
+
+// chainGenesisTime gets the genesis time from the head state of the current chain.
+func chainGenesisTime(ctx context.Context, getChain gossip.ChainFn) (beacon.Timestamp, error) {
+	state, err := chainHeadState(ctx, getChain)
+	if err != nil {
+		return 0, fmt.Errorf("no genesis time specified, and no chain to get it from: %v", err)
+	}
+	return state.GenesisTime()
+}
+
+// enrEth2Data gets the eth2 data from the local ENR.
+func enrEth2Data(enrNode base.WithEnrNode) (*types.Eth2Data, error) {
+	node, ok := enrNode.GetNode()
+	if !ok {
+		return nil, errors.New("no ENR, try 'enr make'")
+	}
+	dat, exists, err := addrutil.ParseEnrEth2Data(node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ENR eth2 data: %v", err)
+	}
+	if !exists {
+		return nil, errors.New("no eth2 data in ENR")
+	}
+	return dat, nil
+}
+
+// enrForkDigest gets the fork digest from the eth2 data in the local ENR.
+func enrForkDigest(enrNode base.WithEnrNode) (beacon.ForkDigest, error) {
+	dat, err := enrEth2Data(enrNode)
+	if err != nil {
+		return beacon.ForkDigest{}, fmt.Errorf("no fork digest specified, and cannot get it from the ENR: %v", err)
+	}
+	return dat.ForkDigest, nil
+}
+
+// currentForkDigest computes the fork digest from the head state of the current chain,
+// or takes it from the ENR if there is no chain. The source is "chain" or "enr".
+func currentForkDigest(ctx context.Context, getChain gossip.ChainFn, enrNode base.WithEnrNode) (digest beacon.ForkDigest, source string, err error) {
+	if state, chainErr := chainHeadState(ctx, getChain); chainErr == nil {
+		digest, _, err = gossip.StateForkDigest(state)
+		return digest, "chain", err
+	}
+	digest, err = enrForkDigest(enrNode)
+	return digest, "enr", err
+}
+
 var NoGossipErr = errors.New("Must start gossip-sub first. Try 'gossip start'")
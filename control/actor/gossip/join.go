@@ -2,33 +2,200 @@ package gossip
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/gossip"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"time"
 )
 
 type GossipJoinCmd struct {
 	*base.Base
 	*GossipState
-	TopicName string `ask:"<topic>" help:"The name of the topic to join"`
+	base.WithEnrNode
+	GetChain    gossip.ChainFn
+	TopicName   string            `ask:"<topic>" help:"The name of the topic to join. Either a full topic, or a short eth2 topic name, e.g. 'beacon_block' or 'attestation:5', expanded with the current fork digest"`
+	ForkDigest  beacon.ForkDigest `ask:"--fork-digest" help:"Fork digest to expand short topic names with. Computed from the current chain, or taken from the ENR, if not specified"`
+	FollowFork  bool              `ask:"--follow-fork" help:"Rejoin a short-named topic with the fork digest of the next fork, as announced in the ENR. Runs in the background until the command is stopped"`
+	ForkOverlap beacon.Epoch      `ask:"--fork-overlap" help:"Epochs before the fork to join the new topic, and epochs after the fork to leave the old topic"`
+
+	ForkDigestChanged bool `changed:"fork-digest"`
+}
+
+func (c *GossipJoinCmd) Default() {
+	c.ForkOverlap = 2
 }
 
 func (c *GossipJoinCmd) Help() string {
-	return "Join a gossip topic. This only sets up the topic, it does not actively find peers. See `gossip log start` and `gossip publish`."
+	return "Join a gossip topic. This only sets up the topic, it does not actively find peers. See `gossip log start` and `gossip publish`. " +
+		"Topics joined by short name can be referred to by the same short name in other gossip commands."
 }
 
 func (c *GossipJoinCmd) Run(ctx context.Context, args ...string) error {
 	if c.GossipState.GsNode == nil {
 		return NoGossipErr
 	}
-	_, ok := c.GossipState.Topics.Load(c.TopicName)
-	if ok {
+	if _, _, ok := c.GossipState.lookup(c.TopicName); ok {
 		return fmt.Errorf("already on gossip topic %s", c.TopicName)
 	}
-	top, err := c.GossipState.GsNode.Join(c.TopicName)
+	if !gossip.IsShortTopic(c.TopicName) {
+		if c.FollowFork {
+			return errors.New("can only follow forks for topics joined by short name")
+		}
+		return c.join(c.TopicName)
+	}
+	digest := c.ForkDigest
+	if !c.ForkDigestChanged {
+		var err error
+		if digest, _, err = currentForkDigest(ctx, c.GetChain, c.WithEnrNode); err != nil {
+			return err
+		}
+	}
+	topic, err := gossip.ExpandTopic(c.TopicName, digest)
 	if err != nil {
 		return err
 	}
-	c.GossipState.Topics.Store(c.TopicName, top)
-	c.Log.Infof("joined topic %s", c.TopicName)
+	var fork *forkSchedule
+	if c.FollowFork {
+		if fork, err = nextFork(ctx, c.GetChain, c.WithEnrNode); err != nil {
+			return fmt.Errorf("cannot follow fork: %v", err)
+		}
+	}
+	name := topic.String()
+	if err := c.join(name); err != nil {
+		return err
+	}
+	c.GossipState.Aliases.Store(c.TopicName, name)
+	if fork != nil {
+		c.followFork(topic, fork)
+	}
 	return nil
 }
+
+func (c *GossipJoinCmd) join(name string) error {
+	if _, ok := c.GossipState.Topics.Load(name); ok {
+		return fmt.Errorf("already on gossip topic %s", name)
+	}
+	top, err := c.GossipState.GsNode.Join(name)
+	if err != nil {
+		return err
+	}
+	c.GossipState.Topics.Store(name, top)
+	c.Log.Infof("joined topic %s", name)
+	return nil
+}
+
+// followFork joins the topic of the next fork in advance, and moves the short name and the validation to it.
+// Subscriptions of other commands stay on the old topic, a warning is logged for them.
+// The old topic is left after the fork, unless something still uses it.
+func (c *GossipJoinCmd) followFork(topic *gossip.Topic, fork *forkSchedule) {
+	oldName := topic.String()
+	newName := topic.WithDigest(fork.Digest).String()
+	joinAt := beacon.Epoch(0)
+	if fork.Epoch > c.ForkOverlap {
+		joinAt = fork.Epoch - c.ForkOverlap
+	}
+	leaveAt := fork.Epoch + c.ForkOverlap
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if !fork.waitForEpoch(bgCtx, joinAt) {
+			return
+		}
+		_, joined, err := c.GossipState.getOrJoin(newName)
+		if err != nil {
+			c.Log.WithError(err).Error("failed to join topic of next fork")
+			return
+		}
+		if joined {
+			c.Log.Infof("joined topic %s", newName)
+		}
+		c.GossipState.Aliases.Store(c.TopicName, newName)
+		c.Log.WithField("epoch", fork.Epoch).Infof("moved %s to topic of next fork: %s", c.TopicName, newName)
+		if v, ok := c.GossipState.Validation.Load(oldName); ok {
+			if _, exists := c.GossipState.Validation.Load(newName); !exists {
+				if err := v.(*topicValidation).register(newName); err != nil {
+					c.Log.WithError(err).Errorf("failed to validate topic of next fork: %s", newName)
+				}
+			}
+		}
+		if n := c.GossipState.subscriptions(oldName); n > 0 {
+			c.Log.Warnf("%d subscriptions (gossip log, record, stats or subnets) stay on the topic of the previous fork %s, "+
+				"restart them with %s to follow the fork", n, oldName, c.TopicName)
+		}
+		if !fork.waitForEpoch(bgCtx, leaveAt) {
+			return
+		}
+		if _, ok := c.GossipState.Validation.Load(oldName); ok {
+			if err := c.GossipState.GsNode.UnregisterTopicValidator(oldName); err != nil {
+				c.Log.WithError(err).Warnf("failed to remove validator of previous fork topic %s", oldName)
+			} else {
+				c.GossipState.Validation.Delete(oldName)
+			}
+		}
+		if old, ok := c.GossipState.Topics.Load(oldName); ok {
+			if err := old.(*pubsub.Topic).Close(); err != nil {
+				c.Log.WithError(err).Warnf("old topic %s is still in use, not leaving it", oldName)
+				return
+			}
+			c.GossipState.forget(oldName)
+			c.Log.Infof("left topic of previous fork: %s", oldName)
+		}
+	}()
+	c.Log.WithField("fork_epoch", fork.Epoch).WithField("next_topic", newName).Info("following fork")
+
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		<-done
+		return nil
+	})
+}
+
+type forkSchedule struct {
+	Epoch       beacon.Epoch
+	Digest      beacon.ForkDigest
+	GenesisTime beacon.Timestamp
+}
+
+// waitForEpoch waits for the start of the epoch. Returns false if the context is done first.
+func (f *forkSchedule) waitForEpoch(ctx context.Context, epoch beacon.Epoch) bool {
+	start := time.Unix(int64(f.GenesisTime+beacon.Timestamp(epoch.GetStartSlot())*beacon.SECONDS_PER_SLOT), 0)
+	select {
+	case <-time.After(time.Until(start)):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// nextFork gets the next fork from the ENR, and computes its digest with the current chain.
+func nextFork(ctx context.Context, getChain gossip.ChainFn, enrNode base.WithEnrNode) (*forkSchedule, error) {
+	dat, err := enrEth2Data(enrNode)
+	if err != nil {
+		return nil, err
+	}
+	if dat.NextForkEpoch == beacon.FAR_FUTURE_EPOCH {
+		return nil, errors.New("no next fork scheduled in ENR")
+	}
+	state, err := chainHeadState(ctx, getChain)
+	if err != nil {
+		return nil, err
+	}
+	_, genesisValRoot, err := gossip.StateForkDigest(state)
+	if err != nil {
+		return nil, err
+	}
+	genesis, err := state.GenesisTime()
+	if err != nil {
+		return nil, err
+	}
+	return &forkSchedule{
+		Epoch:       dat.NextForkEpoch,
+		Digest:      beacon.ComputeForkDigest(dat.NextForkVersion, genesisValRoot),
+		GenesisTime: genesis,
+	}, nil
+}
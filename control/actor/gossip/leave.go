@@ -3,7 +3,6 @@ package gossip
 import (
 	"context"
 	"fmt"
-	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/protolambda/rumor/control/actor/base"
 )
 
@@ -21,14 +20,14 @@ func (c *GossipLeaveCmd) Run(ctx context.Context, args ...string) error {
 	if c.GossipState.GsNode == nil {
 		return NoGossipErr
 	}
-	if top, ok := c.GossipState.Topics.Load(c.TopicName); !ok {
+	if name, top, ok := c.GossipState.lookup(c.TopicName); !ok {
 		return fmt.Errorf("not on gossip topic %s", c.TopicName)
 	} else {
-		err := top.(*pubsub.Topic).Close()
+		err := top.Close()
 		if err != nil {
 			return err
 		}
-		c.GossipState.Topics.Delete(c.TopicName)
+		c.GossipState.forget(name)
 		return nil
 	}
 }
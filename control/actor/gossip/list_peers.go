@@ -3,7 +3,6 @@ package gossip
 import (
 	"context"
 	"fmt"
-	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/protolambda/rumor/control/actor/base"
 )
 
@@ -21,11 +20,11 @@ func (c *GossipListPeersCmd) Run(ctx context.Context, args ...string) error {
 	if c.GossipState.GsNode == nil {
 		return NoGossipErr
 	}
-	if top, ok := c.GossipState.Topics.Load(c.TopicName); !ok {
+	if name, top, ok := c.GossipState.lookup(c.TopicName); !ok {
 		return fmt.Errorf("not on gossip topic %s", c.TopicName)
 	} else {
-		peers := top.(*pubsub.Topic).ListPeers()
-		c.Log.WithField("peers", peers).Infof("%d peers on topic %s", len(peers), c.TopicName)
+		peers := top.ListPeers()
+		c.Log.WithField("peers", peers).Infof("%d peers on topic %s", len(peers), name)
 		return nil
 	}
 }
@@ -5,8 +5,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"github.com/golang/snappy"
-	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/gossip"
 	"github.com/sirupsen/logrus"
 	"strings"
 )
@@ -15,24 +15,29 @@ type GossipLogCmd struct {
 	*base.Base
 	*GossipState
 	TopicName string `ask:"<topic>" help:"The name of the topic to log messages of"`
+	Raw       bool   `ask:"--raw" help:"Log the (uncompressed) message data as hex, instead of a summary of the decoded message"`
+	Full      bool   `ask:"--full" help:"Log the full decoded message, not just a summary"`
 }
 
 func (c *GossipLogCmd) Help() string {
-	return "Log the messages of a gossip topic. Messages are hex-encoded. Join a topic first."
+	return "Log the messages of a gossip topic. Messages on known eth2 topics are decoded and summarized, other messages are hex-encoded. Join a topic first."
 }
 
 func (c *GossipLogCmd) Run(ctx context.Context, args ...string) error {
 	if c.GossipState.GsNode == nil {
 		return NoGossipErr
 	}
-	if top, ok := c.GossipState.Topics.Load(c.TopicName); !ok {
+	if name, top, ok := c.GossipState.lookup(c.TopicName); !ok {
 		return fmt.Errorf("not on gossip topic %s", c.TopicName)
 	} else {
-		sub, err := top.(*pubsub.Topic).Subscribe()
+		c.TopicName = name
+		// Not an eth2 topic if it cannot be parsed, the message data is logged as-is.
+		topic, _ := gossip.ParseTopic(c.TopicName)
+		sub, err := c.GossipState.subscribe(name, top)
 		if err != nil {
 			return fmt.Errorf("Cannot open subscription on topic %s: %v", c.TopicName, err)
 		}
-		defer sub.Cancel()
+		defer c.GossipState.unsubscribe(name, sub)
 		for {
 			msg, err := sub.Next(ctx)
 			if err != nil {
@@ -40,24 +45,53 @@ func (c *GossipLogCmd) Run(ctx context.Context, args ...string) error {
 					break
 				}
 				return fmt.Errorf("Gossip subscription on %s encountered error: %v", c.TopicName, err)
-			} else {
-				var msgData []byte
-				if strings.HasSuffix(c.TopicName, "_snappy") {
-					msgData, err = snappy.Decode(nil, msg.Data)
-					if err != nil {
-						return fmt.Errorf("Cannot decode message on %s with snappy: %v", c.TopicName, err)
-					}
-				} else {
-					msgData = msg.Data
-				}
-				c.Log.WithFields(logrus.Fields{
-					"from":      msg.GetFrom().String(),
-					"data":      hex.EncodeToString(msgData),
-					"signature": hex.EncodeToString(msg.Signature),
-					"seq_no":    hex.EncodeToString(msg.Seqno),
-				}).Infof("new message on %s", c.TopicName)
 			}
+			f := logrus.Fields{
+				"from":      msg.GetFrom().String(),
+				"signature": hex.EncodeToString(msg.Signature),
+				"seq_no":    hex.EncodeToString(msg.Seqno),
+			}
+			c.logData(f, topic, msg.Data)
+			c.Log.WithFields(f).Infof("new message on %s", c.TopicName)
 		}
 		return nil
 	}
 }
+
+func (c *GossipLogCmd) logData(f logrus.Fields, topic *gossip.Topic, data []byte) {
+	if topic == nil {
+		if strings.HasSuffix(c.TopicName, "_snappy") {
+			if msgData, err := snappy.Decode(nil, data); err != nil {
+				f["decode_err"] = err.Error()
+			} else {
+				data = msgData
+			}
+		}
+		f["data"] = hex.EncodeToString(data)
+		return
+	}
+	msgData, err := topic.Uncompress(data)
+	if err != nil {
+		f["data"] = hex.EncodeToString(data)
+		f["decode_err"] = err.Error()
+		return
+	}
+	if c.Raw || topic.Type == nil {
+		f["data"] = hex.EncodeToString(msgData)
+		return
+	}
+	obj, err := topic.Type.Decode(msgData)
+	if err != nil {
+		f["data"] = hex.EncodeToString(msgData)
+		f["decode_err"] = err.Error()
+		return
+	}
+	f["type"] = topic.Type.Name
+	if c.Full {
+		f["msg"] = obj
+	} else {
+		for k, v := range topic.Type.Summary(obj) {
+			f[k] = v
+		}
+	}
+}
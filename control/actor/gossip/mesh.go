@@ -0,0 +1,73 @@
+package gossip
+
+import (
+	"context"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/gossip"
+	"github.com/protolambda/rumor/p2p/peering"
+)
+
+type GossipMeshCmd struct {
+	*base.Base
+	*GossipState
+	Scorer    *peering.Scorer
+	TopicName string `ask:"<topic>" help:"The name of the topic to show the mesh of"`
+}
+
+func (c *GossipMeshCmd) Help() string {
+	return "Show the mesh peers of the topic, the other known peers of the topic, and the topic counters. " +
+		"Every peer is listed with its rumor peer score and the gossip it sent us. " +
+		"Fanout peers and the scores of the pubsub router are not shown: the pubsub version of rumor does not expose them."
+}
+
+type meshPeer struct {
+	Peer  peer.ID `json:"peer"`
+	Mesh  bool    `json:"mesh"`
+	Score float64 `json:"score"`
+	// Ratio of duplicates in all messages received from the peer
+	DuplicateRatio float64             `json:"duplicate_ratio"`
+	Counters       gossip.PeerCounters `json:"counters"`
+}
+
+func (c *GossipMeshCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return NoGossipErr
+	}
+	name, top, ok := c.GossipState.lookup(c.TopicName)
+	if !ok {
+		return fmt.Errorf("not on gossip topic %s", c.TopicName)
+	}
+	mt := c.GossipState.GsNode.Mesh()
+	mesh := mt.Mesh(name)
+	inMesh := make(map[peer.ID]struct{}, len(mesh))
+	for _, id := range mesh {
+		inMesh[id] = struct{}{}
+	}
+	var others []peer.ID
+	for _, id := range top.ListPeers() {
+		if _, ok := inMesh[id]; !ok {
+			others = append(others, id)
+		}
+	}
+	describe := func(id peer.ID, isMesh bool) meshPeer {
+		mp := meshPeer{Peer: id, Mesh: isMesh, Counters: mt.Peer(id)}
+		mp.DuplicateRatio = mp.Counters.DuplicateRatio()
+		if c.Scorer != nil {
+			mp.Score = c.Scorer.Score(id)
+		}
+		return mp
+	}
+	peers := make([]meshPeer, 0, len(mesh)+len(others))
+	for _, id := range mesh {
+		peers = append(peers, describe(id, true))
+	}
+	for _, id := range others {
+		peers = append(peers, describe(id, false))
+	}
+	c.Log.WithField("peers", peers).
+		WithField("counters", mt.Topic(name)).
+		Infof("%d mesh peers, %d other peers on topic %s", len(mesh), len(others), name)
+	return nil
+}
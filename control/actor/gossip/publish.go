@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/golang/snappy"
-	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/protolambda/rumor/control/actor/base"
 	"strings"
 )
@@ -24,14 +23,14 @@ func (c *GossipPublishCmd) Run(ctx context.Context, args ...string) error {
 	if c.GossipState.GsNode == nil {
 		return NoGossipErr
 	}
-	if top, ok := c.GossipState.Topics.Load(c.TopicName); !ok {
+	if name, top, ok := c.GossipState.lookup(c.TopicName); !ok {
 		return fmt.Errorf("not on gossip topic %s", c.TopicName)
 	} else {
 		data := c.Message
-		if strings.HasSuffix(c.TopicName, "_snappy") {
+		if strings.HasSuffix(name, "_snappy") {
 			data = snappy.Encode(nil, data)
 		}
-		if err := top.(*pubsub.Topic).Publish(ctx, data); err != nil {
+		if err := top.Publish(ctx, data); err != nil {
 			return fmt.Errorf("failed to publish message, err: %v", err)
 		}
 		return nil
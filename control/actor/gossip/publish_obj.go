@@ -0,0 +1,136 @@
+package gossip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/gossip"
+	"github.com/protolambda/rumor/p2p/peering"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"io/ioutil"
+)
+
+type GossipPublishBlockCmd struct {
+	*base.Base
+	*GossipState
+	base.WithEnrNode
+	GetChain gossip.ChainFn
+	Blocks   bdb.DB
+
+	Root       beacon.Root       `ask:"<root>" help:"Root of the block to publish, the block must be in the blocks DB"`
+	ForkDigest beacon.ForkDigest `ask:"--fork-digest" help:"Fork digest of the topic. Computed from the current chain, or taken from the ENR, if not specified"`
+
+	ForkDigestChanged bool `changed:"fork-digest"`
+}
+
+func (c *GossipPublishBlockCmd) Help() string {
+	return "Publish a block from the blocks DB to the beacon block topic, SSZ encoded and snappy compressed. The topic is joined if not already."
+}
+
+func (c *GossipPublishBlockCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return NoGossipErr
+	}
+	var buf bytes.Buffer
+	exists, err := c.Blocks.Export(c.Root, &buf)
+	if err != nil {
+		return fmt.Errorf("failed to load block %s: %v", c.Root, err)
+	}
+	if !exists {
+		return fmt.Errorf("block %s not found", c.Root)
+	}
+	digest := c.ForkDigest
+	if !c.ForkDigestChanged {
+		if digest, _, err = currentForkDigest(ctx, c.GetChain, c.WithEnrNode); err != nil {
+			return err
+		}
+	}
+	topic := &gossip.Topic{
+		Digest:   digest,
+		Name:     gossip.BeaconBlockTopic.Name,
+		Encoding: gossip.SSZSnappyEncoding,
+		Type:     gossip.BeaconBlockTopic,
+	}
+	return c.GossipState.publish(ctx, c.Log, topic, buf.Bytes())
+}
+
+type GossipPublishObjectCmd struct {
+	*base.Base
+	*GossipState
+	base.WithEnrNode
+	GetChain gossip.ChainFn
+
+	TypeName   string            `ask:"<type>" help:"Topic type of the object: beacon_block, beacon_aggregate_and_proof, beacon_attestation, voluntary_exit, proposer_slashing or attester_slashing"`
+	Input      string            `ask:"--input" help:"A file path to read the object from as ssz file."`
+	Data       []byte            `ask:"--data" help:"Alternative to file input, read the object from hex-encoded SSZ bytes."`
+	Subnet     uint64            `ask:"--subnet" help:"Subnet to publish an attestation on. Computed with the current chain if not specified"`
+	ForkDigest beacon.ForkDigest `ask:"--fork-digest" help:"Fork digest of the topic. Computed from the current chain, or taken from the ENR, if not specified"`
+
+	SubnetChanged     bool `changed:"subnet"`
+	ForkDigestChanged bool `changed:"fork-digest"`
+}
+
+func (c *GossipPublishObjectCmd) Help() string {
+	return "Publish an SSZ object to the topic of its type, SSZ encoded and snappy compressed. The object is decoded before publishing, to check it. " +
+		"The topic is joined if not already."
+}
+
+func (c *GossipPublishObjectCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return NoGossipErr
+	}
+	typ, _, hasSubnet := gossip.TopicTypeByName(c.TypeName)
+	if typ == nil || hasSubnet {
+		return fmt.Errorf("unknown object type: %s", c.TypeName)
+	}
+	data := c.Data
+	if c.Input != "" {
+		var err error
+		if data, err = ioutil.ReadFile(c.Input); err != nil {
+			return fmt.Errorf("failed to read %s: %v", c.Input, err)
+		}
+	} else if len(data) == 0 {
+		return errors.New("no input data. Try --input or --data to read the object from")
+	}
+	obj, err := typ.Decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", typ.Name, err)
+	}
+	digest := c.ForkDigest
+	if !c.ForkDigestChanged {
+		if digest, _, err = currentForkDigest(ctx, c.GetChain, c.WithEnrNode); err != nil {
+			return err
+		}
+	}
+	topic := &gossip.Topic{
+		Digest:   digest,
+		Name:     typ.Name,
+		Encoding: gossip.SSZSnappyEncoding,
+		Type:     typ,
+	}
+	if typ.Subnets {
+		subnet := c.Subnet
+		if c.SubnetChanged {
+			if err := peering.CheckSubnets([]uint64{subnet}); err != nil {
+				return err
+			}
+		} else {
+			att, ok := obj.(*beacon.Attestation)
+			if !ok {
+				return fmt.Errorf("no subnet specified for %s", typ.Name)
+			}
+			ch, err := c.GetChain()
+			if err != nil {
+				return fmt.Errorf("no subnet specified, and no chain to compute it with: %v", err)
+			}
+			if subnet, err = gossip.AttestationSubnet(ctx, ch, att); err != nil {
+				return fmt.Errorf("failed to compute attestation subnet: %v", err)
+			}
+		}
+		topic = gossip.AttestationSubnetTopic(digest, subnet)
+	}
+	return c.GossipState.publish(ctx, c.Log, topic, data)
+}
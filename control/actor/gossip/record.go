@@ -0,0 +1,125 @@
+package gossip
+
+import (
+	"context"
+	"fmt"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/gossip"
+	"os"
+	"sync"
+	"time"
+)
+
+type GossipRecordCmd struct {
+	*base.Base
+	*GossipState
+	base.WithEnrNode
+	GetChain   gossip.ChainFn
+	TopicNames []string `ask:"<topics>" help:"The names of the topics to record messages of, comma-separated or as separate arguments. Topics are joined if not already. Short eth2 topic names are expanded with the current fork digest if not joined by short name"`
+	Out        string   `ask:"--out" help:"Path of the capture file to write to"`
+	Append     bool     `ask:"--append" help:"Append to the capture file, instead of truncating it"`
+}
+
+func (c *GossipRecordCmd) Help() string {
+	return "Record every received message on the given topics to a capture file, with topic, sender, receive time, message ID and raw data. " +
+		"Runs in the background until the command is stopped. See 'gossip replay'."
+}
+
+func (c *GossipRecordCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return NoGossipErr
+	}
+	if c.Out == "" {
+		return fmt.Errorf("no capture output file specified, use --out")
+	}
+	c.TopicNames = append(c.TopicNames, args...)
+	if len(c.TopicNames) == 0 {
+		return fmt.Errorf("no topics to record")
+	}
+	// the capture stores the full topic names
+	for i, name := range c.TopicNames {
+		fullName, err := c.GossipState.resolve(ctx, c.GetChain, c.WithEnrNode, name)
+		if err != nil {
+			return err
+		}
+		c.TopicNames[i] = fullName
+	}
+	flags := os.O_CREATE | os.O_WRONLY
+	if c.Append {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(c.Out, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %v", err)
+	}
+	subs := make([]*pubsub.Subscription, 0, len(c.TopicNames))
+	cancelSubs := func() {
+		for i, sub := range subs {
+			c.GossipState.unsubscribe(c.TopicNames[i], sub)
+		}
+	}
+	for _, name := range c.TopicNames {
+		top, joined, err := c.GossipState.getOrJoin(name)
+		if joined {
+			c.Log.Infof("joined topic %s", name)
+		}
+		if err != nil {
+			cancelSubs()
+			_ = f.Close()
+			return err
+		}
+		sub, err := c.GossipState.subscribe(name, top)
+		if err != nil {
+			cancelSubs()
+			_ = f.Close()
+			return fmt.Errorf("cannot open subscription on topic %s: %v", name, err)
+		}
+		subs = append(subs, sub)
+	}
+
+	w := gossip.NewCaptureWriter(f)
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	var countLock sync.Mutex
+	count := uint64(0)
+	for i, sub := range subs {
+		wg.Add(1)
+		go func(name string, sub *pubsub.Subscription) {
+			defer wg.Done()
+			for {
+				msg, err := sub.Next(bgCtx)
+				if err != nil {
+					if err != bgCtx.Err() {
+						c.Log.WithError(err).Errorf("gossip subscription on %s encountered error", name)
+					}
+					return
+				}
+				if err := w.Write(gossip.NewCaptureRecord(time.Now(), name, c.GossipState.GsNode.MsgID(msg.Message), msg)); err != nil {
+					c.Log.WithError(err).Error("failed to write message to capture")
+					continue
+				}
+				countLock.Lock()
+				count += 1
+				countLock.Unlock()
+			}
+		}(c.TopicNames[i], sub)
+	}
+	c.Log.WithField("topics", c.TopicNames).WithField("out", c.Out).Info("Started recording gossip")
+
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		cancelSubs()
+		wg.Wait()
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to close capture file: %v", err)
+		}
+		countLock.Lock()
+		defer countLock.Unlock()
+		c.Log.WithField("messages", count).Info("Stopped recording gossip")
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,117 @@
+package gossip
+
+import (
+	"context"
+	"fmt"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/gossip"
+	"io"
+	"os"
+	"time"
+)
+
+type GossipReplayCmd struct {
+	*base.Base
+	*GossipState
+	base.WithEnrNode
+	GetChain gossip.ChainFn
+	Path     string  `ask:"<capture>" help:"Path of the capture file to replay, see 'gossip record'"`
+	Speed    float64 `ask:"--speed" help:"Replay speed relative to the original timing, e.g. 2 to replay twice as fast. 0 to publish as fast as possible"`
+	Skip     bool    `ask:"--skip-errors" help:"Continue with the next message if publishing a message fails"`
+}
+
+func (c *GossipReplayCmd) Default() {
+	c.Speed = 1
+}
+
+func (c *GossipReplayCmd) Help() string {
+	return "Publish the messages of a capture back onto their topics, at original or accelerated timing. " +
+		"Topics are joined if not already. Short eth2 topic names in older captures are resolved like in other gossip commands. Runs in the background until the capture is done or the command is stopped."
+}
+
+func (c *GossipReplayCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return NoGossipErr
+	}
+	if c.Speed < 0 {
+		return fmt.Errorf("invalid replay speed: %f", c.Speed)
+	}
+	f, err := os.Open(c.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %v", err)
+	}
+	r := gossip.NewCaptureReader(f)
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer f.Close()
+		count := uint64(0)
+		var first time.Time
+		start := time.Now()
+		// capture topic -> full topic name
+		resolved := make(map[string]string)
+		for i := 0; ; i++ {
+			rec, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				c.Log.WithError(err).Error("failed to read capture")
+				break
+			}
+			if i == 0 {
+				first = rec.Time
+			}
+			if c.Speed != 0 {
+				offset := time.Duration(float64(rec.Time.Sub(first)) / c.Speed)
+				if wait := time.Until(start.Add(offset)); wait > 0 {
+					select {
+					case <-time.After(wait):
+					case <-bgCtx.Done():
+					}
+				}
+			}
+			if bgCtx.Err() != nil {
+				break
+			}
+			name, ok := resolved[rec.Topic]
+			if !ok {
+				if name, err = c.GossipState.resolve(bgCtx, c.GetChain, c.WithEnrNode, rec.Topic); err == nil {
+					resolved[rec.Topic] = name
+				}
+			}
+			if err == nil {
+				var top *pubsub.Topic
+				var joined bool
+				top, joined, err = c.GossipState.getOrJoin(name)
+				if joined {
+					c.Log.Infof("joined topic %s", name)
+				}
+				if err == nil {
+					err = top.Publish(bgCtx, rec.Data)
+				}
+			}
+			if err != nil {
+				c.Log.WithError(err).WithField("topic", rec.Topic).WithField("msg_id", rec.MsgID).Error("failed to publish message")
+				if c.Skip {
+					continue
+				}
+				break
+			}
+			count += 1
+			c.Log.WithField("topic", name).WithField("msg_id", rec.MsgID).Debug("published message")
+		}
+		c.Log.WithField("messages", count).Info("Finished gossip replay")
+	}()
+	c.Log.WithField("capture", c.Path).WithField("speed", c.Speed).Info("Started gossip replay")
+
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		<-done
+		return nil
+	})
+	return nil
+}
@@ -5,11 +5,20 @@ import (
 	"errors"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/p2p/gossip"
+	"github.com/protolambda/rumor/p2p/peering"
 )
 
 type GossipStartCmd struct {
 	*base.Base
 	*GossipState
+	// Penalizes peers for rejected messages, may be nil
+	Scorer *peering.Scorer
+
+	MsgID gossip.MsgIDMode `ask:"--msg-id" help:"Message ID function: 'data' to hash the raw data, 'uncompressed' to hash the snappy-decompressed data, or 'domain' for the domain-prefixed hash of the decompressed data"`
+}
+
+func (c *GossipStartCmd) Default() {
+	c.MsgID = gossip.MsgIDData
 }
 
 func (c *GossipStartCmd) Help() string {
@@ -24,10 +33,13 @@ func (c *GossipStartCmd) Run(ctx context.Context, args ...string) error {
 	if c.GossipState.GsNode != nil {
 		return errors.New("Already started GossipSub")
 	}
-	c.GossipState.GsNode, err = gossip.NewGossipSub(c.ActorContext, h)
+	c.GossipState.GsNode, err = gossip.NewGossipSub(c.ActorContext, h, c.MsgID)
 	if err != nil {
 		return err
 	}
-	c.Log.Info("Started GossipSub")
+	if c.Scorer != nil {
+		c.GossipState.GsNode.Tracer().AddListener("scores", c.Scorer.OnTrace)
+	}
+	c.Log.WithField("msg_id", c.MsgID).Info("Started GossipSub")
 	return nil
 }
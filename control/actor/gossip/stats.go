@@ -0,0 +1,416 @@
+package gossip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/gossip"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/dstrack"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"sort"
+	"sync"
+	"time"
+)
+
+type GossipStatsCmd struct {
+	*base.Base
+	*GossipState
+	base.WithEnrNode
+	GetChain gossip.ChainFn
+	Store    track.GossipStatsBook
+}
+
+func (c *GossipStatsCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "track-blocks":
+		cmd = &GossipStatsTrackBlocksCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode, GetChain: c.GetChain}
+	case "blocks":
+		cmd = &GossipStatsBlocksCmd{Base: c.Base, GossipState: c.GossipState}
+	case "control":
+		cmd = &GossipStatsControlCmd{Base: c.Base, GossipState: c.GossipState}
+	case "track-dups":
+		cmd = &GossipStatsTrackDupsCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode, GetChain: c.GetChain,
+			Store: c.Store}
+	case "dups":
+		cmd = &GossipStatsDupsCmd{Base: c.Base, GossipState: c.GossipState}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *GossipStatsCmd) Routes() []string {
+	return []string{"track-blocks", "blocks", "control", "track-dups", "dups"}
+}
+
+func (c *GossipStatsCmd) Help() string {
+	return "Track and summarize gossip statistics"
+}
+
+type GossipStatsTrackBlocksCmd struct {
+	*base.Base
+	*GossipState
+	base.WithEnrNode
+	GetChain    gossip.ChainFn
+	TopicName   string           `ask:"<topic>" help:"The name of the eth2 beacon block topic to track, e.g. 'beacon_block', expanded with the current fork digest if not joined by short name. The topic is joined if not already."`
+	GenesisTime beacon.Timestamp `ask:"--genesis-time" help:"Genesis time to compute slot start times with. If 0, the genesis time of the current chain is used."`
+	MaxBlocks   int              `ask:"--max-blocks" help:"Max number of blocks to keep stats of, older blocks are dropped first. 0 to keep all."`
+}
+
+func (c *GossipStatsTrackBlocksCmd) Default() {
+	c.MaxBlocks = 1000
+}
+
+func (c *GossipStatsTrackBlocksCmd) Help() string {
+	return "Track block propagation: the delay between the slot start and the first arrival of each block, " +
+		"which peer delivered it first, and how many duplicates arrived. Runs in the background until the command is stopped. See 'gossip stats blocks'."
+}
+
+func (c *GossipStatsTrackBlocksCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return NoGossipErr
+	}
+	name, err := c.GossipState.resolve(ctx, c.GetChain, c.WithEnrNode, c.TopicName)
+	if err != nil {
+		return err
+	}
+	c.TopicName = name
+	topic, err := gossip.ParseTopic(c.TopicName)
+	if err != nil {
+		return err
+	}
+	if topic.Type != gossip.BeaconBlockTopic {
+		return fmt.Errorf("not a beacon block topic: %s", c.TopicName)
+	}
+	genesis := c.GenesisTime
+	if genesis == 0 {
+		if genesis, err = chainGenesisTime(ctx, c.GetChain); err != nil {
+			return err
+		}
+	}
+	tracker := gossip.NewBlockTracker(genesis, c.MaxBlocks)
+	tracer := c.GossipState.GsNode.Tracer()
+	if !tracer.AddListener("blocks", tracker.OnTrace) {
+		return errors.New("already tracking blocks")
+	}
+	top, joined, err := c.GossipState.getOrJoin(c.TopicName)
+	if joined {
+		c.Log.Infof("joined topic %s", c.TopicName)
+	}
+	if err != nil {
+		tracer.RemoveListener("blocks")
+		return err
+	}
+	sub, err := c.GossipState.subscribe(c.TopicName, top)
+	if err != nil {
+		tracer.RemoveListener("blocks")
+		return fmt.Errorf("cannot open subscription on topic %s: %v", c.TopicName, err)
+	}
+	c.GossipState.Blocks = tracker
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			msg, err := sub.Next(bgCtx)
+			if err != nil {
+				if err != bgCtx.Err() {
+					c.Log.WithError(err).Errorf("gossip subscription on %s encountered error", c.TopicName)
+				}
+				return
+			}
+			obj, err := topic.Decode(msg.Data)
+			if err != nil {
+				c.Log.WithError(err).WithField("from", msg.ReceivedFrom.String()).Debug("failed to decode block")
+				continue
+			}
+			tracker.OnBlock(c.GossipState.GsNode.MsgID(msg.Message), obj.(*beacon.SignedBeaconBlock))
+		}
+	}()
+	c.Log.WithField("genesis_time", genesis).Infof("Started tracking blocks on %s", c.TopicName)
+
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		tracer.RemoveListener("blocks")
+		bgCancel()
+		c.GossipState.unsubscribe(c.TopicName, sub)
+		<-done
+		c.Log.Infof("Stopped tracking blocks on %s", c.TopicName)
+		return nil
+	})
+	return nil
+}
+
+type GossipStatsBlocksCmd struct {
+	*base.Base
+	*GossipState
+	TopPeers int  `ask:"--top-peers" help:"Number of peers to list that delivered blocks first most often"`
+	List     int  `ask:"--list" help:"Also list the last N tracked blocks"`
+	Reset    bool `ask:"--reset" help:"Reset the stats after showing them"`
+}
+
+func (c *GossipStatsBlocksCmd) Default() {
+	c.TopPeers = 10
+}
+
+func (c *GossipStatsBlocksCmd) Help() string {
+	return "Summarize block propagation: delay and duplicates percentiles, and the peers that delivered blocks first. " +
+		"Delays are measured from the slot start to the first arrival, before validation. See 'gossip stats track-blocks'."
+}
+
+func (c *GossipStatsBlocksCmd) Run(ctx context.Context, args ...string) error {
+	tracker := c.GossipState.Blocks
+	if tracker == nil {
+		return errors.New("not tracking blocks, try 'gossip stats track-blocks'")
+	}
+	summary := tracker.Summary(c.TopPeers)
+	delays := make(map[string]string, len(summary.Delay))
+	for k, v := range summary.Delay {
+		delays[k] = v.String()
+	}
+	l := c.Log.WithField("blocks", summary.Blocks).
+		WithField("decoded", summary.Decoded).
+		WithField("delay", delays).
+		WithField("duplicates", summary.Duplicates).
+		WithField("first_peers", summary.FirstPeers)
+	if c.List > 0 {
+		arrivals := tracker.Arrivals()
+		if len(arrivals) > c.List {
+			arrivals = arrivals[len(arrivals)-c.List:]
+		}
+		l = l.WithField("recent", arrivals)
+	}
+	l.Info("block propagation stats")
+	if c.Reset {
+		tracker.Reset()
+	}
+	return nil
+}
+
+type GossipStatsControlCmd struct {
+	*base.Base
+	*GossipState
+	Topics bool `ask:"--topics" help:"Also show the counters per topic"`
+	Peers  int  `ask:"--peers" help:"Also show the counters of the N peers with the highest duplicate ratio"`
+	Reset  bool `ask:"--reset" help:"Reset the counters after showing them"`
+}
+
+func (c *GossipStatsControlCmd) Help() string {
+	return "Show GRAFT/PRUNE/IHAVE/IWANT counters and duplicate message ratios, since gossip start or the last reset. " +
+		"Control messages sent to peers are only counted in total and per topic."
+}
+
+func (c *GossipStatsControlCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return NoGossipErr
+	}
+	mt := c.GossipState.GsNode.Mesh()
+	total := mt.Total()
+	l := c.Log.WithField("total", total).WithField("duplicate_ratio", total.DuplicateRatio())
+	if c.Topics {
+		l = l.WithField("topics", mt.Topics())
+	}
+	if c.Peers > 0 {
+		type entry struct {
+			Peer string `json:"peer"`
+			gossip.PeerCounters
+			DuplicateRatio float64 `json:"duplicate_ratio"`
+		}
+		var entries []entry
+		for id, pc := range mt.Peers() {
+			entries = append(entries, entry{Peer: id.String(), PeerCounters: pc, DuplicateRatio: pc.DuplicateRatio()})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].DuplicateRatio > entries[j].DuplicateRatio
+		})
+		if len(entries) > c.Peers {
+			entries = entries[:c.Peers]
+		}
+		l = l.WithField("peers", entries)
+	}
+	l.Info("gossip control stats")
+	if c.Reset {
+		mt.ResetCounters()
+	}
+	return nil
+}
+
+type GossipStatsTrackDupsCmd struct {
+	*base.Base
+	*GossipState
+	base.WithEnrNode
+	GetChain   gossip.ChainFn
+	Store      track.GossipStatsBook
+	MaxEntries int  `ask:"--max-entries" help:"Max number of message IDs and contents to keep, older entries are dropped first. 0 to keep all."`
+	Persist    bool `ask:"--persist" help:"Continue the stats stored in the peerstore, and store them every minute and when stopped. Ignored if there is no peerstore."`
+}
+
+func (c *GossipStatsTrackDupsCmd) Default() {
+	c.MaxEntries = 10000
+	c.Persist = true
+}
+
+// dupStatsKey is the name the duplicate stats are persisted under
+const dupStatsKey = "dups"
+
+func (c *GossipStatsTrackDupsCmd) Help() string {
+	return "Track duplicate messages on the given topics (all joined topics if none): how often a message arrived from different peers, " +
+		"and how often the same content arrived under different message IDs. Topics are joined and subscribed to, to see delivered contents. " +
+		"Short eth2 topic names, e.g. 'beacon_block', are expanded with the current fork digest if not joined by short name. " +
+		"Runs in the background until the command is stopped. See 'gossip stats dups'."
+}
+
+func (c *GossipStatsTrackDupsCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return NoGossipErr
+	}
+	var names []string
+	if len(args) == 0 {
+		c.GossipState.Topics.Range(func(key, value interface{}) bool {
+			names = append(names, key.(string))
+			return true
+		})
+		if len(names) == 0 {
+			return errors.New("no joined topics to track, specify the topics to join")
+		}
+		sort.Strings(names)
+	}
+	for _, arg := range args {
+		name, err := c.GossipState.resolve(ctx, c.GetChain, c.WithEnrNode, arg)
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	tracker := gossip.NewDupTracker(names, c.MaxEntries)
+	store := c.Store
+	if !c.Persist {
+		store = nil
+	}
+	if store != nil {
+		var prev gossip.DupStats
+		if ok, err := store.LoadGossipStats(dupStatsKey, &prev); err != nil {
+			return err
+		} else if ok {
+			tracker.Restore(prev)
+			c.Log.WithField("stats", prev).Info("continuing stored duplicate stats")
+		}
+	}
+	tracer := c.GossipState.GsNode.Tracer()
+	if !tracer.AddListener("dups", tracker.OnTrace) {
+		return errors.New("already tracking duplicates")
+	}
+	var subs []*pubsub.Subscription
+	cancelSubs := func() {
+		for i, sub := range subs {
+			c.GossipState.unsubscribe(names[i], sub)
+		}
+	}
+	for _, name := range names {
+		top, joined, err := c.GossipState.getOrJoin(name)
+		if joined {
+			c.Log.Infof("joined topic %s", name)
+		}
+		if err != nil {
+			tracer.RemoveListener("dups")
+			cancelSubs()
+			return err
+		}
+		sub, err := c.GossipState.subscribe(name, top)
+		if err != nil {
+			tracer.RemoveListener("dups")
+			cancelSubs()
+			return fmt.Errorf("cannot open subscription on topic %s: %v", name, err)
+		}
+		subs = append(subs, sub)
+	}
+	c.GossipState.Dups = tracker
+
+	storeStats := func() {
+		if err := store.StoreGossipStats(dupStatsKey, tracker.Stats()); err != nil {
+			c.Log.WithError(err).Warn("failed to store duplicate stats")
+		}
+	}
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	if store != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(dstrack.FlushInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					storeStats()
+				case <-bgCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+	for i, sub := range subs {
+		wg.Add(1)
+		go func(name string, sub *pubsub.Subscription) {
+			defer wg.Done()
+			for {
+				msg, err := sub.Next(bgCtx)
+				if err != nil {
+					if err != bgCtx.Err() {
+						c.Log.WithError(err).Errorf("gossip subscription on %s encountered error", name)
+					}
+					return
+				}
+				tracker.OnDeliver(c.GossipState.GsNode.MsgID(msg.Message), msg.Data)
+			}
+		}(names[i], sub)
+	}
+	c.Log.WithField("topics", names).Info("Started tracking duplicates")
+
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		tracer.RemoveListener("dups")
+		bgCancel()
+		cancelSubs()
+		wg.Wait()
+		if store != nil {
+			storeStats()
+		}
+		c.Log.Info("Stopped tracking duplicates")
+		return nil
+	})
+	return nil
+}
+
+type GossipStatsDupsCmd struct {
+	*base.Base
+	*GossipState
+	Examples int  `ask:"--examples" help:"Also list the last N contents that arrived under different message IDs"`
+	Reset    bool `ask:"--reset" help:"Reset the stats after showing them"`
+}
+
+func (c *GossipStatsDupsCmd) Help() string {
+	return "Show duplicate message stats: copies per message, messages from multiple peers, " +
+		"and contents that arrived under multiple message IDs. See 'gossip stats track-dups'."
+}
+
+func (c *GossipStatsDupsCmd) Run(ctx context.Context, args ...string) error {
+	tracker := c.GossipState.Dups
+	if tracker == nil {
+		return errors.New("not tracking duplicates, try 'gossip stats track-dups'")
+	}
+	stats := tracker.Stats()
+	l := c.Log.WithField("stats", stats).WithField("avg_copies", stats.AvgCopies())
+	if c.Examples > 0 {
+		l = l.WithField("multi_id", tracker.MultiIDContents(c.Examples))
+	}
+	l.Info("duplicate message stats")
+	if c.Reset {
+		tracker.Reset()
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package gossip
+
+import (
+	"context"
+	"errors"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/gossip"
+	"github.com/protolambda/rumor/p2p/types"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"sync"
+	"time"
+)
+
+// GetAttnetsFn returns the attnets the actor advertises, in its ENR and metadata.
+type GetAttnetsFn func() (enrAttnets types.AttnetBits, metaAttnets types.AttnetBits)
+
+// SetAttnetsFn updates the attnets the actor advertises, in its ENR and metadata. Nil attnets are left unchanged.
+type SetAttnetsFn func(enrAttnets *types.AttnetBits, metaAttnets *types.AttnetBits)
+
+type SubnetsState struct {
+	lock     sync.Mutex
+	Schedule *gossip.SubnetSchedule
+	Digest   beacon.ForkDigest
+	// subnet -> subscription to the subnet topic
+	subs map[uint64]*pubsub.Subscription
+	// subnets of which the topic was joined by the schedule, and is left by it
+	ownTopics map[uint64]bool
+}
+
+type GossipSubnetsCmd struct {
+	*base.Base
+	*GossipState
+	base.WithEnrNode
+	GetChain   gossip.ChainFn
+	GetAttnets GetAttnetsFn
+	SetAttnets SetAttnetsFn
+}
+
+func (c *GossipSubnetsCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "start":
+		cmd = &GossipSubnetsStartCmd{Base: c.Base, GossipState: c.GossipState, WithEnrNode: c.WithEnrNode,
+			GetChain: c.GetChain, GetAttnets: c.GetAttnets, SetAttnets: c.SetAttnets}
+	case "list":
+		cmd = &GossipSubnetsListCmd{Base: c.Base, GossipState: c.GossipState}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *GossipSubnetsCmd) Routes() []string {
+	return []string{"start", "list"}
+}
+
+func (c *GossipSubnetsCmd) Help() string {
+	return "Manage attestation subnet subscriptions, kept consistent with the ENR and metadata attnets"
+}
+
+type GossipSubnetsStartCmd struct {
+	*base.Base
+	*GossipState
+	base.WithEnrNode
+	GetChain   gossip.ChainFn
+	GetAttnets GetAttnetsFn
+	SetAttnets SetAttnetsFn
+
+	Fixed          []uint            `ask:"--fixed" help:"Subnets to always subscribe to, comma-separated"`
+	Random         uint64            `ask:"--random" help:"Number of random subnets to subscribe to, rotated like a validator client does"`
+	RotationEpochs beacon.Epoch      `ask:"--rotation-epochs" help:"Minimum epochs to stay on a random subnet, the actual time is random, up to twice as long"`
+	Seed           int64             `ask:"--seed" help:"Seed to pick random subnets with. If 0, the current time is used"`
+	ForkDigest     beacon.ForkDigest `ask:"--fork-digest" help:"Fork digest of the subnet topics. Computed from the current chain, or taken from the ENR, if not specified"`
+	GenesisTime    beacon.Timestamp  `ask:"--genesis-time" help:"Genesis time to compute epochs with. If 0, the genesis time of the current chain is used."`
+
+	ForkDigestChanged bool `changed:"fork-digest"`
+}
+
+func (c *GossipSubnetsStartCmd) Default() {
+	c.Random = gossip.RandomSubnetsPerValidator
+	c.RotationEpochs = gossip.EpochsPerRandomSubnetSubscription
+}
+
+func (c *GossipSubnetsStartCmd) Help() string {
+	return "Subscribe to attestation subnets: fixed subnets, and random subnets that are rotated on an epoch schedule. " +
+		"The ENR and metadata attnets are updated on every change. Runs in the background until the command is stopped, " +
+		"then the subnet topics joined by the schedule are left, and the previous attnets are restored."
+}
+
+func (c *GossipSubnetsStartCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return NoGossipErr
+	}
+	st := &c.GossipState.Subnets
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.Schedule != nil {
+		return errors.New("already managing subnets")
+	}
+	digest := c.ForkDigest
+	if !c.ForkDigestChanged {
+		var err error
+		if digest, _, err = currentForkDigest(ctx, c.GetChain, c.WithEnrNode); err != nil {
+			return err
+		}
+	}
+	genesis := c.GenesisTime
+	if genesis == 0 {
+		var err error
+		if genesis, err = chainGenesisTime(ctx, c.GetChain); err != nil {
+			return err
+		}
+	}
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fixed := make([]uint64, len(c.Fixed))
+	for i, s := range c.Fixed {
+		fixed[i] = uint64(s)
+	}
+	schedule, err := gossip.NewSubnetSchedule(fixed, c.Random, c.RotationEpochs, seed)
+	if err != nil {
+		return err
+	}
+	st.Schedule = schedule
+	st.Digest = digest
+	st.subs = make(map[uint64]*pubsub.Subscription)
+	st.ownTopics = make(map[uint64]bool)
+	prevEnrAttnets, prevMetaAttnets := c.GetAttnets()
+
+	epochDuration := time.Duration(uint64(beacon.SECONDS_PER_SLOT)*uint64(beacon.SLOTS_PER_EPOCH)) * time.Second
+	currentEpoch := func() beacon.Epoch {
+		now := beacon.Timestamp(time.Now().Unix())
+		if now < genesis {
+			return 0
+		}
+		return now.ToSlot(genesis).ToEpoch()
+	}
+	update := func(epoch beacon.Epoch) {
+		st.lock.Lock()
+		defer st.lock.Unlock()
+		join, leave := st.Schedule.Update(epoch)
+		for _, subnet := range leave {
+			c.leaveSubnet(subnet)
+		}
+		for _, subnet := range join {
+			c.joinSubnet(subnet)
+		}
+		if len(join) > 0 || len(leave) > 0 {
+			attnets := st.Schedule.Attnets()
+			c.SetAttnets(&attnets, &attnets)
+			c.Log.WithField("epoch", epoch).WithField("joined", join).WithField("left", leave).
+				WithField("attnets", attnets).Info("updated subnet subscriptions")
+		}
+	}
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			epoch := currentEpoch()
+			update(epoch)
+			nextEpochStart := time.Unix(int64(genesis), 0).Add(time.Duration(epoch+1) * epochDuration)
+			select {
+			case <-time.After(time.Until(nextEpochStart)):
+			case <-bgCtx.Done():
+				return
+			}
+		}
+	}()
+	c.Log.WithField("fork_digest", digest).WithField("fixed", fixed).WithField("random", c.Random).
+		Info("Started managing subnets")
+
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		<-done
+		st.lock.Lock()
+		defer st.lock.Unlock()
+		for subnet := range st.subs {
+			c.leaveSubnet(subnet)
+		}
+		st.Schedule = nil
+		st.subs = nil
+		st.ownTopics = nil
+		c.SetAttnets(&prevEnrAttnets, &prevMetaAttnets)
+		c.Log.Info("Stopped managing subnets")
+		return nil
+	})
+	return nil
+}
+
+// joinSubnet joins and subscribes to the subnet topic. Messages are consumed, to stay in the mesh.
+// The subnets lock must be held.
+func (c *GossipSubnetsStartCmd) joinSubnet(subnet uint64) {
+	st := &c.GossipState.Subnets
+	name := gossip.AttestationSubnetTopic(st.Digest, subnet).String()
+	top, joined, err := c.GossipState.getOrJoin(name)
+	if err != nil {
+		c.Log.WithError(err).Errorf("failed to join subnet %d", subnet)
+		return
+	}
+	st.ownTopics[subnet] = joined
+	sub, err := c.GossipState.subscribe(name, top)
+	if err != nil {
+		c.Log.WithError(err).Errorf("failed to subscribe to subnet %d", subnet)
+		return
+	}
+	st.subs[subnet] = sub
+	go func() {
+		for {
+			if _, err := sub.Next(context.Background()); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// leaveSubnet cancels the subnet subscription. The topic is left if the schedule joined it,
+// and nothing else is using it. The subnets lock must be held.
+func (c *GossipSubnetsStartCmd) leaveSubnet(subnet uint64) {
+	st := &c.GossipState.Subnets
+	sub, ok := st.subs[subnet]
+	if !ok {
+		return
+	}
+	name := gossip.AttestationSubnetTopic(st.Digest, subnet).String()
+	c.GossipState.unsubscribe(name, sub)
+	delete(st.subs, subnet)
+	own := st.ownTopics[subnet]
+	delete(st.ownTopics, subnet)
+	if !own {
+		return
+	}
+	if top, ok := c.GossipState.Topics.Load(name); ok {
+		if err := top.(*pubsub.Topic).Close(); err != nil {
+			c.Log.WithError(err).Debugf("topic of subnet %d is still in use, not leaving it", subnet)
+		} else {
+			c.GossipState.forget(name)
+		}
+	}
+}
+
+type GossipSubnetsListCmd struct {
+	*base.Base
+	*GossipState
+}
+
+func (c *GossipSubnetsListCmd) Help() string {
+	return "List the current subnet subscriptions"
+}
+
+func (c *GossipSubnetsListCmd) Run(ctx context.Context, args ...string) error {
+	st := &c.GossipState.Subnets
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.Schedule == nil {
+		return errors.New("not managing subnets, try 'gossip subnets start'")
+	}
+	c.Log.WithField("fork_digest", st.Digest).
+		WithField("subscriptions", st.Schedule.Subscriptions()).
+		WithField("attnets", st.Schedule.Attnets()).Info("subnet subscriptions")
+	return nil
+}
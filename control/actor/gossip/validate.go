@@ -0,0 +1,117 @@
+package gossip
+
+import (
+	"context"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/gossip"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// topicValidation is the validation of a topic, kept to register the same validation on the topic of the next fork.
+type topicValidation struct {
+	Mode     gossip.ValidationMode
+	register func(name string) error
+}
+
+type GossipValidateCmd struct {
+	*base.Base
+	*GossipState
+	base.WithEnrNode
+	GetChain gossip.ChainFn
+	// Called with every validation result, optional.
+	// Pubsub only knows if a message passes, the listener can tell ignored and rejected messages apart.
+	OnResult  gossip.ValidationListener
+	TopicName string                `ask:"<topic>" help:"The name of the eth2 topic to validate messages of. Short names, e.g. 'beacon_block', are expanded with the current fork digest if not joined by short name"`
+	Mode      gossip.ValidationMode `ask:"[mode]" help:"'honest' to only deliver and relay accepted messages, 'observe' to report results but pass all messages, 'off' to disable validation"`
+	Timeout   time.Duration         `ask:"--timeout" help:"Timeout for validating a single message. Messages are dropped on timeout. 0 to disable"`
+}
+
+func (c *GossipValidateCmd) Default() {
+	c.Mode = gossip.ValidationHonest
+	c.Timeout = 5 * time.Second
+}
+
+func (c *GossipValidateCmd) Help() string {
+	return "Validate messages on an eth2 topic against the current chain, following the p2p spec. " +
+		"Messages that are not accepted are logged with their result: ignore or reject."
+}
+
+func (c *GossipValidateCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return NoGossipErr
+	}
+	if !c.Mode.Valid() {
+		return fmt.Errorf("invalid validation mode: %s", c.Mode)
+	}
+	name, err := c.GossipState.resolve(ctx, c.GetChain, c.WithEnrNode, c.TopicName)
+	if err != nil {
+		return err
+	}
+	c.TopicName = name
+	if topic, err := gossip.ParseTopic(c.TopicName); err != nil {
+		return err
+	} else if topic.Type == nil {
+		return fmt.Errorf("no validation available for topic %s", c.TopicName)
+	}
+	if _, ok := c.GossipState.Validation.Load(c.TopicName); ok {
+		if err := c.GossipState.GsNode.UnregisterTopicValidator(c.TopicName); err != nil {
+			return fmt.Errorf("failed to remove previous validator: %v", err)
+		}
+		c.GossipState.Validation.Delete(c.TopicName)
+	}
+	if c.Mode == gossip.ValidationOff {
+		c.Log.WithField("topic", c.TopicName).Info("disabled validation")
+		return nil
+	}
+	return c.register(c.TopicName)
+}
+
+// register the validator on the topic, with the mode and timeout of the command.
+func (c *GossipValidateCmd) register(name string) error {
+	topic, err := gossip.ParseTopic(name)
+	if err != nil {
+		return err
+	}
+	if topic.Type == nil {
+		return fmt.Errorf("no validation available for topic %s", name)
+	}
+	mode := c.Mode
+	v := &gossip.Eth2Validator{GetChain: c.GetChain}
+	validator := func(ctx context.Context, from peer.ID, msg *pubsub.Message) bool {
+		var res gossip.ValidationResult
+		var reason string
+		obj, err := topic.Decode(msg.Data)
+		if err != nil {
+			res, reason = gossip.ValidationReject, fmt.Sprintf("cannot decode message: %v", err)
+		} else {
+			res, reason = v.Validate(ctx, topic, obj)
+		}
+		if c.OnResult != nil {
+			c.OnResult(from, name, res, reason)
+		}
+		if res != gossip.ValidationAccept {
+			c.Log.WithFields(logrus.Fields{
+				"from":   from.String(),
+				"topic":  name,
+				"result": res.String(),
+				"reason": reason,
+				"pass":   mode.Pass(res),
+			}).Info("message was not accepted")
+		}
+		return mode.Pass(res)
+	}
+	var opts []pubsub.ValidatorOpt
+	if c.Timeout != 0 {
+		opts = append(opts, pubsub.WithValidatorTimeout(c.Timeout))
+	}
+	if err := c.GossipState.GsNode.RegisterTopicValidator(name, validator, opts...); err != nil {
+		return err
+	}
+	c.GossipState.Validation.Store(name, &topicValidation{Mode: mode, register: c.register})
+	c.Log.WithFields(logrus.Fields{"topic": name, "mode": mode}).Info("enabled validation")
+	return nil
+}
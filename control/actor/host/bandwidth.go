@@ -0,0 +1,139 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"github.com/libp2p/go-libp2p-core/metrics"
+	"github.com/protolambda/rumor/control/actor/base"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BandwidthState keeps the bandwidth counter of the last started host.
+type BandwidthState struct {
+	lock    sync.Mutex
+	counter *metrics.BandwidthCounter
+}
+
+// Counter returns the bandwidth counter, nil if no host was started yet.
+func (s *BandwidthState) Counter() *metrics.BandwidthCounter {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.counter
+}
+
+// newCounter starts a new count, for a new host.
+func (s *BandwidthState) newCounter() *metrics.BandwidthCounter {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.counter = metrics.NewBandwidthCounter()
+	return s.counter
+}
+
+type BandwidthStats struct {
+	TotalIn  int64   `json:"total_in"`
+	TotalOut int64   `json:"total_out"`
+	RateIn   float64 `json:"rate_in"`
+	RateOut  float64 `json:"rate_out"`
+}
+
+func bandwidthStats(st metrics.Stats) BandwidthStats {
+	return BandwidthStats{TotalIn: st.TotalIn, TotalOut: st.TotalOut, RateIn: st.RateIn, RateOut: st.RateOut}
+}
+
+type HostBandwidthCmd struct {
+	*base.Base
+	*BandwidthState
+
+	Peers     bool          `ask:"--peers" help:"Show the usage per peer"`
+	Protocols bool          `ask:"--protocols" help:"Show the usage per protocol ID"`
+	Top       int           `ask:"--top" help:"Only show the peers and protocols with the most traffic. 0 to show all."`
+	Interval  time.Duration `ask:"--interval" help:"Keep logging the usage at this interval, until the command is stopped. 0 to log once."`
+	Reset     bool          `ask:"--reset" help:"Reset the counters after showing them"`
+}
+
+func (c *HostBandwidthCmd) Default() {
+	c.Protocols = true
+}
+
+func (c *HostBandwidthCmd) Help() string {
+	return "Show the bandwidth usage of the host: totals, and optionally per peer and per protocol ID. " +
+		"Totals are in bytes, rates in bytes per second. Only stream data is counted, not the security and muxer overhead. " +
+		"Stream data before protocol negotiation has an empty protocol ID."
+}
+
+func (c *HostBandwidthCmd) Run(ctx context.Context, args ...string) error {
+	bwc := c.BandwidthState.Counter()
+	if bwc == nil {
+		return errors.New("no bandwidth counter, start a host first")
+	}
+	if c.Interval <= 0 {
+		c.log(bwc)
+		return nil
+	}
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.log(bwc)
+			case <-bgCtx.Done():
+				return
+			}
+		}
+	}()
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		<-done
+		return nil
+	})
+	return nil
+}
+
+type bandwidthEntry struct {
+	key   string
+	stats metrics.Stats
+}
+
+// top sorts the entries by total traffic, and keeps the first n, or all if n is 0.
+func top(entries []bandwidthEntry, n int) []bandwidthEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].stats.TotalIn+entries[i].stats.TotalOut > entries[j].stats.TotalIn+entries[j].stats.TotalOut
+	})
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+func (c *HostBandwidthCmd) log(bwc *metrics.BandwidthCounter) {
+	if c.Peers {
+		byPeer := bwc.GetBandwidthByPeer()
+		entries := make([]bandwidthEntry, 0, len(byPeer))
+		for id, st := range byPeer {
+			entries = append(entries, bandwidthEntry{key: id.Pretty(), stats: st})
+		}
+		for _, e := range top(entries, c.Top) {
+			c.Log.WithField("peer", e.key).WithField("bandwidth", bandwidthStats(e.stats)).Info("peer bandwidth")
+		}
+	}
+	if c.Protocols {
+		byProtocol := bwc.GetBandwidthByProtocol()
+		entries := make([]bandwidthEntry, 0, len(byProtocol))
+		for id, st := range byProtocol {
+			entries = append(entries, bandwidthEntry{key: string(id), stats: st})
+		}
+		for _, e := range top(entries, c.Top) {
+			c.Log.WithField("protocol", e.key).WithField("bandwidth", bandwidthStats(e.stats)).Info("protocol bandwidth")
+		}
+	}
+	c.Log.WithField("bandwidth", bandwidthStats(bwc.GetBandwidthTotals())).Info("total bandwidth")
+	if c.Reset {
+		bwc.Reset()
+	}
+}
@@ -0,0 +1,159 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/peering"
+	"github.com/protolambda/rumor/p2p/track"
+)
+
+type HostGateCmd struct {
+	*base.Base
+	Rules            *peering.GateRules
+	CurrentPeerstore track.DynamicPeerstore
+}
+
+func (c *HostGateCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "list":
+		cmd = &HostGateListCmd{Base: c.Base, Rules: c.Rules}
+	case "allow":
+		cmd = &HostGateAddCmd{Base: c.Base, Rules: c.Rules, CurrentPeerstore: c.CurrentPeerstore, allow: true}
+	case "deny":
+		cmd = &HostGateAddCmd{Base: c.Base, Rules: c.Rules, CurrentPeerstore: c.CurrentPeerstore, allow: false}
+	case "remove":
+		cmd = &HostGateRemoveCmd{Base: c.Base, Rules: c.Rules}
+	case "clear":
+		cmd = &HostGateClearCmd{Base: c.Base, Rules: c.Rules}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *HostGateCmd) Routes() []string {
+	return []string{"list", "allow", "deny", "remove", "clear"}
+}
+
+func (c *HostGateCmd) Help() string {
+	return "Allow and deny connections by peer ID, IP/CIDR and multiaddr protocol, for inbound and outbound connections. " +
+		"Deny rules take precedence. Once there is an allow rule of a kind, only connections matching an allow rule of that kind are allowed. " +
+		"Denied connections are closed by the transport before the host sees them, with no connection events."
+}
+
+type HostGateListCmd struct {
+	*base.Base
+	Rules *peering.GateRules
+}
+
+func (c *HostGateListCmd) Help() string {
+	return "List the gate rules"
+}
+
+func (c *HostGateListCmd) Run(ctx context.Context, args ...string) error {
+	rules := c.Rules.Rules()
+	for _, r := range rules {
+		c.Log.WithField("allow", r.Allow).WithField("kind", r.Kind).WithField("value", r.Value).Info("gate rule")
+	}
+	c.Log.WithField("count", len(rules)).Info("listed gate rules")
+	return nil
+}
+
+type HostGateAddCmd struct {
+	*base.Base
+	Rules            *peering.GateRules
+	CurrentPeerstore track.DynamicPeerstore
+	allow            bool
+
+	Kind  string `ask:"<kind>" help:"What the rule matches on: 'peer' for a peer ID, 'ip' for an IP or CIDR range, 'proto' for a multiaddr protocol, e.g. 'ws'"`
+	Value string `ask:"<value>" help:"The peer ID, IP, CIDR range or protocol name"`
+	Apply bool   `ask:"--apply" help:"Close the current connections that are denied after the change"`
+}
+
+func (c *HostGateAddCmd) Default() {
+	c.Apply = true
+}
+
+func (c *HostGateAddCmd) Help() string {
+	return "Add a gate rule"
+}
+
+func (c *HostGateAddCmd) Run(ctx context.Context, args ...string) error {
+	rule, err := c.Rules.Add(peering.GateRule{Allow: c.allow, Kind: peering.RuleKind(c.Kind), Value: c.Value})
+	if err != nil {
+		return err
+	}
+	c.Log.WithField("rule", rule.String()).Info("added gate rule")
+	if !c.Apply {
+		return nil
+	}
+	h, err := c.Host()
+	if err != nil {
+		// no host, no connections to apply the rule to
+		return nil
+	}
+	closed := 0
+	for _, conn := range h.Network().Conns() {
+		id := conn.RemotePeer()
+		if err := c.Rules.Check(id, conn.RemoteMultiaddr()); err != nil {
+			if c.CurrentPeerstore.Initialized() {
+				c.CurrentPeerstore.SetDisconnectReason(id, fmt.Sprintf("denied by gate rule: %v", err))
+			}
+			_ = conn.Close()
+			closed += 1
+			c.Log.WithField("peer", id.Pretty()).WithField("addr", conn.RemoteMultiaddr().String()).
+				WithError(err).Debug("closed denied connection")
+		}
+	}
+	if closed > 0 {
+		c.Log.WithField("closed", closed).Info("closed denied connections")
+	}
+	return nil
+}
+
+type HostGateRemoveCmd struct {
+	*base.Base
+	Rules *peering.GateRules
+
+	List  string `ask:"<list>" help:"The list to remove the rule from, 'allow' or 'deny'"`
+	Kind  string `ask:"<kind>" help:"Kind of the rule: 'peer', 'ip' or 'proto'"`
+	Value string `ask:"<value>" help:"The peer ID, IP, CIDR range or protocol name of the rule"`
+}
+
+func (c *HostGateRemoveCmd) Help() string {
+	return "Remove a gate rule"
+}
+
+func (c *HostGateRemoveCmd) Run(ctx context.Context, args ...string) error {
+	if c.List != "allow" && c.List != "deny" {
+		return errors.New("list must be 'allow' or 'deny'")
+	}
+	rule := peering.GateRule{Allow: c.List == "allow", Kind: peering.RuleKind(c.Kind), Value: c.Value}
+	ok, err := c.Rules.Remove(rule)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no such gate rule: %s", rule)
+	}
+	c.Log.WithField("rule", rule.String()).Info("removed gate rule")
+	return nil
+}
+
+type HostGateClearCmd struct {
+	*base.Base
+	Rules *peering.GateRules
+}
+
+func (c *HostGateClearCmd) Help() string {
+	return "Remove all gate rules"
+}
+
+func (c *HostGateClearCmd) Run(ctx context.Context, args ...string) error {
+	c.Rules.Clear()
+	c.Log.Info("cleared gate rules")
+	return nil
+}
@@ -4,6 +4,9 @@ import (
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/protolambda/ask"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/nettrace"
+	"github.com/protolambda/rumor/p2p/peering"
+	"github.com/protolambda/rumor/p2p/rpc/stats"
 	"github.com/protolambda/rumor/p2p/track"
 )
 
@@ -21,6 +24,15 @@ type HostCmd struct {
 	GlobalPeerstores track.Peerstores
 	CurrentPeerstore track.DynamicPeerstore
 
+	RPCStats  *stats.Registry
+	Bandwidth *BandwidthState
+
+	Gater     peering.ConnectionGater
+	GateRules *peering.GateRules
+	Scorer    *peering.Scorer
+
+	NetTracer *nettrace.Tracer
+
 	WithSetHost
 	WithCloseHost
 	base.PrivSettings
@@ -31,7 +43,8 @@ func (c *HostCmd) Cmd(route string) (cmd interface{}, err error) {
 	switch route {
 	case "start":
 		cmd = &HostStartCmd{Base: c.Base, WithSetHost: c.WithSetHost, PrivSettings: c.PrivSettings,
-			GlobalPeerstores: c.GlobalPeerstores, CurrentPeerstore: c.CurrentPeerstore}
+			GlobalPeerstores: c.GlobalPeerstores, CurrentPeerstore: c.CurrentPeerstore, RPCStats: c.RPCStats,
+			Bandwidth: c.Bandwidth, Gater: c.Gater, Scorer: c.Scorer, NetTracer: c.NetTracer}
 	case "stop":
 		cmd = &HostStopCmd{Base: c.Base, WithCloseHost: c.WithCloseHost}
 	case "view":
@@ -40,6 +53,12 @@ func (c *HostCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = &HostListenCmd{Base: c.Base, WithEnrNode: c.WithEnrNode}
 	case "notify":
 		cmd = &HostNotifyCmd{c.Base}
+	case "bandwidth":
+		cmd = &HostBandwidthCmd{Base: c.Base, BandwidthState: c.Bandwidth}
+	case "gate":
+		cmd = &HostGateCmd{Base: c.Base, Rules: c.GateRules, CurrentPeerstore: c.CurrentPeerstore}
+	case "trace":
+		cmd = &HostTraceCmd{Base: c.Base, Tracer: c.NetTracer}
 	default:
 		return nil, ask.UnrecognizedErr
 	}
@@ -47,7 +66,7 @@ func (c *HostCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *HostCmd) Routes() []string {
-	return []string{"start", "stop", "view", "listen", "event"}
+	return []string{"start", "stop", "view", "listen", "event", "bandwidth", "gate", "trace"}
 }
 
 func (c *HostCmd) Help() string {
@@ -9,16 +9,23 @@ import (
 	"github.com/libp2p/go-libp2p"
 	connmgr "github.com/libp2p/go-libp2p-connmgr"
 	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/transport"
 	mplex "github.com/libp2p/go-libp2p-mplex"
 	noise "github.com/libp2p/go-libp2p-noise"
 	secio "github.com/libp2p/go-libp2p-secio"
 	tls "github.com/libp2p/go-libp2p-tls"
+	tptu "github.com/libp2p/go-libp2p-transport-upgrader"
 	yamux "github.com/libp2p/go-libp2p-yamux"
 	"github.com/libp2p/go-tcp-transport"
 	ws "github.com/libp2p/go-ws-transport"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/flags"
 	"github.com/protolambda/rumor/control/actor/peerstore"
+	"github.com/protolambda/rumor/p2p/nettrace"
+	"github.com/protolambda/rumor/p2p/peering"
+	"github.com/protolambda/rumor/p2p/rpc/stats"
 	"github.com/protolambda/rumor/p2p/track"
 	"strings"
 	"time"
@@ -32,6 +39,17 @@ type HostStartCmd struct {
 	GlobalPeerstores track.Peerstores
 	CurrentPeerstore track.DynamicPeerstore
 
+	RPCStats *stats.Registry
+	// Bandwidth usage of the host, per peer and per protocol
+	Bandwidth *BandwidthState
+
+	// Connections not allowed by the gater are closed, and dials to them are denied
+	Gater peering.ConnectionGater
+	// Scores peers based on their RPC behavior and goodbyes
+	Scorer *peering.Scorer
+	// Traces connections, protocol negotiation, identify results and stream resets
+	NetTracer *nettrace.Tracer
+
 	PrivKey          flags.P2pPrivKeyFlag `ask:"--priv" help:"hex-encoded private key for libp2p host. Random if none is specified."`
 	TransportsStrArr []string             `ask:"--transport" help:"Transports to use. Options: tcp, ws"`
 	MuxStrArr        []string             `ask:"--mux" help:"Multiplexers to use"`
@@ -99,13 +117,22 @@ func (c *HostStartCmd) Run(ctx context.Context, args ...string) error {
 	}
 	hostOptions := make([]libp2p.Option, 0)
 
+	// Connections denied by the gater, e.g. with banned peers, are closed before the host sees them
+	onDeny := func(conn network.ConnMultiaddrs, dir network.Direction, p peer.ID) {
+		c.Log.WithField("peer", p.Pretty()).WithField("addr", conn.RemoteMultiaddr().String()).
+			WithField("direction", fmtDirection(dir)).Debug("closing connection denied by gater")
+	}
 	for _, v := range c.TransportsStrArr {
 		v = strings.ToLower(strings.TrimSpace(v))
 		switch v {
 		case "tcp":
-			hostOptions = append(hostOptions, libp2p.Transport(tcp.NewTCPTransport))
+			hostOptions = append(hostOptions, libp2p.Transport(peering.GatedTransport(func(u *tptu.Upgrader) transport.Transport {
+				return tcp.NewTCPTransport(u)
+			}, c.Gater, onDeny)))
 		case "ws":
-			hostOptions = append(hostOptions, libp2p.Transport(ws.New))
+			hostOptions = append(hostOptions, libp2p.Transport(peering.GatedTransport(func(u *tptu.Upgrader) transport.Transport {
+				return ws.New(u)
+			}, c.Gater, onDeny)))
 		default:
 			return fmt.Errorf("could not recognize transport %s", v)
 		}
@@ -164,11 +191,45 @@ func (c *HostStartCmd) Run(ctx context.Context, args ...string) error {
 		libp2p.Peerstore(store),
 		libp2p.ConnectionManager(connmgr.NewConnManager(c.LoPeers, c.HiPeers, c.GracePeriod)),
 		libp2p.UserAgent(c.UserAgent),
+		libp2p.BandwidthReporter(c.Bandwidth.newCounter()),
 	)
 	// Not the command ctx, we want the host to stay open after the command.
 	h, err := libp2p.New(c.ActorContext, hostOptions...)
 	if err != nil {
 		return err
 	}
+	// Track the connection history of peers in the peerstore
+	h.Network().Notify(track.ConnectionNotifiee(store, store))
+	if c.RelayEnabled {
+		// The relay transport is not gated, close its connections that the gater denies after they are set up
+		h.Network().Notify(peering.GaterNotifiee(c.Gater, func(conn network.Conn) {
+			store.SetDisconnectReason(conn.RemotePeer(), "denied by connection gater")
+			onDeny(conn, conn.Stat().Direction, conn.RemotePeer())
+		}))
+	}
+	h.Network().Notify(c.Scorer.GoodbyeNotifiee(store))
+	net := h.Network()
+	c.Scorer.OnBan(func(ban peering.Ban) {
+		store.SetDisconnectReason(ban.Peer, "banned: "+ban.Reason)
+		_ = net.ClosePeer(ban.Peer)
+		c.Log.WithField("peer", ban.Peer.Pretty()).WithField("reason", ban.Reason).Info("banned peer")
+	})
+	h.Network().Notify(c.NetTracer.Notifiee())
+	identifySub, err := c.NetTracer.WatchIdentify(h)
+	if err != nil {
+		_ = h.Close()
+		return err
+	}
+	go func() {
+		<-c.ActorContext.Done()
+		_ = identifySub.Close()
+	}()
+	h = c.NetTracer.WrapHost(h)
+	// Track the requests made and served through the host, in the actor registry and the peerstore.
+	h = stats.WrapHost(h, func(rec *track.RPCRecord) {
+		c.RPCStats.RegisterRPC(rec)
+		store.RegisterRPC(rec)
+		c.Scorer.OnRPC(rec)
+	})
 	return c.SetHost(h)
 }
@@ -0,0 +1,64 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/nettrace"
+)
+
+var traceTypes = []nettrace.EventType{
+	nettrace.Connected, nettrace.Disconnected,
+	nettrace.Negotiated, nettrace.NegotiationFailed, nettrace.StreamReset,
+	nettrace.Identified, nettrace.IdentifyFailed,
+}
+
+type HostTraceCmd struct {
+	*base.Base
+	Tracer *nettrace.Tracer
+
+	Types []string `ask:"--types" help:"Event types to log. Options: connected, disconnected, negotiated, negotiation_failed, stream_reset, identified, identify_failed. All if empty."`
+}
+
+func (c *HostTraceCmd) Help() string {
+	return "Trace the network of the host, until the command is stopped: connections with their security and muxer, " +
+		"protocol negotiation of streams (proposed, rejected and selected protocols), identify results, and stream resets. " +
+		"Rejected proposals are only visible for outbound streams. Stream resets do not carry error codes in this libp2p version."
+}
+
+func (c *HostTraceCmd) Run(ctx context.Context, args ...string) error {
+	if _, err := c.Host(); err != nil {
+		return err
+	}
+	types := make(map[nettrace.EventType]struct{})
+	for _, t := range c.Types {
+		typ := nettrace.EventType(t)
+		known := false
+		for _, k := range traceTypes {
+			if typ == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown trace event type: %s", t)
+		}
+		types[typ] = struct{}{}
+	}
+	if !c.Tracer.AddListener("trace", func(evt *nettrace.Event) {
+		if len(types) > 0 {
+			if _, ok := types[evt.Type]; !ok {
+				return
+			}
+		}
+		c.Log.WithField("trace", *evt).Info(string(evt.Type))
+	}) {
+		return errors.New("already tracing")
+	}
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		c.Tracer.RemoveListener("trace")
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,116 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/flags"
+	"github.com/protolambda/rumor/p2p/peering"
+	"github.com/protolambda/rumor/p2p/track"
+	"time"
+)
+
+type PeerBansCmd struct {
+	*base.Base
+	Bans  *peering.BanList
+	Store track.ExtendedPeerstore
+}
+
+func (c *PeerBansCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "list":
+		cmd = &PeerBansListCmd{Base: c.Base, Bans: c.Bans}
+	case "add":
+		cmd = &PeerBansAddCmd{Base: c.Base, Bans: c.Bans, Store: c.Store}
+	case "remove":
+		cmd = &PeerBansRemoveCmd{Base: c.Base, Bans: c.Bans}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *PeerBansCmd) Routes() []string {
+	return []string{"list", "add", "remove"}
+}
+
+func (c *PeerBansCmd) Help() string {
+	return "Manage banned peers. Banned peers are disconnected, and cannot connect or be dialed until the ban expires."
+}
+
+type PeerBansListCmd struct {
+	*base.Base
+	Bans *peering.BanList
+}
+
+func (c *PeerBansListCmd) Help() string {
+	return "List the banned peers, expired bans are not included"
+}
+
+func (c *PeerBansListCmd) Run(ctx context.Context, args ...string) error {
+	bans := c.Bans.List()
+	for _, b := range bans {
+		l := c.Log.WithField("peer", b.Peer.Pretty()).WithField("reason", b.Reason).WithField("since", b.Since)
+		if !b.Until.IsZero() {
+			l = l.WithField("until", b.Until)
+		}
+		l.Info("banned peer")
+	}
+	c.Log.WithField("count", len(bans)).Info("listed banned peers")
+	return nil
+}
+
+type PeerBansAddCmd struct {
+	*base.Base
+	Bans  *peering.BanList
+	Store track.ExtendedPeerstore
+
+	PeerID   flags.PeerIDFlag `ask:"<peer-id>" help:"The peer to ban"`
+	Reason   string           `ask:"--reason" help:"Reason of the ban"`
+	Duration time.Duration    `ask:"--duration" help:"How long to ban the peer for, 0 to ban without expiry"`
+}
+
+func (c *PeerBansAddCmd) Default() {
+	c.Reason = "ban command"
+	c.Duration = time.Hour
+}
+
+func (c *PeerBansAddCmd) Help() string {
+	return "Ban a peer, and disconnect it if connected. An existing ban of the peer is replaced."
+}
+
+func (c *PeerBansAddCmd) Run(ctx context.Context, args ...string) error {
+	id := c.PeerID.PeerID
+	b := c.Bans.Add(id, c.Reason, c.Duration)
+	if h, err := c.Host(); err == nil && len(h.Network().ConnsToPeer(id)) > 0 {
+		c.Store.SetDisconnectReason(id, fmt.Sprintf("banned: %s", c.Reason))
+		closePeer(c.Log, h, id)
+	}
+	l := c.Log.WithField("peer", id.Pretty()).WithField("reason", b.Reason)
+	if !b.Until.IsZero() {
+		l = l.WithField("until", b.Until)
+	}
+	l.Info("banned peer")
+	return nil
+}
+
+type PeerBansRemoveCmd struct {
+	*base.Base
+	Bans *peering.BanList
+
+	PeerID flags.PeerIDFlag `ask:"<peer-id>" help:"The peer to unban"`
+}
+
+func (c *PeerBansRemoveCmd) Help() string {
+	return "Remove the ban of a peer"
+}
+
+func (c *PeerBansRemoveCmd) Run(ctx context.Context, args ...string) error {
+	id := c.PeerID.PeerID
+	if !c.Bans.Remove(id) {
+		return fmt.Errorf("peer %s is not banned", id.Pretty())
+	}
+	c.Log.WithField("peer", id.Pretty()).Info("removed ban of peer")
+	return nil
+}
@@ -0,0 +1,86 @@
+package peer
+
+import (
+	"context"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/filter"
+	"sync"
+	"time"
+)
+
+type PeerDialCmd struct {
+	*base.Base
+	Store    track.ExtendedPeerstore
+	Sort     string        `ask:"--sort" help:"Peer data field to sort by, to dial the first peers"`
+	Desc     bool          `ask:"--desc" help:"Sort in descending order"`
+	Limit    int           `ask:"--limit" help:"Max number of peers to dial, 0 for no limit"`
+	Parallel int           `ask:"--parallel" help:"Max number of dials at the same time"`
+	Timeout  time.Duration `ask:"--timeout" help:"Timeout of each dial, 0 to disable"`
+}
+
+func (c *PeerDialCmd) Default() {
+	c.Limit = 10
+	c.Parallel = 8
+	c.Timeout = 10 * time.Second
+}
+
+func (c *PeerDialCmd) Help() string {
+	return "Connect to peers from the peerstore that are not connected yet. Arguments are filter conditions on the peer data, " +
+		"all must match, see 'peer list'. E.g. 'peer dial fork_digest=e7a75d5a attnets has 12 --limit=5'."
+}
+
+func (c *PeerDialCmd) Run(ctx context.Context, args ...string) error {
+	h, err := c.Host()
+	if err != nil {
+		return err
+	}
+	f, err := filter.Parse(args...)
+	if err != nil {
+		return err
+	}
+	var candidates []peer.ID
+	for _, id := range c.Store.Peers() {
+		if id != h.ID() && h.Network().Connectedness(id) != network.Connected && len(c.Store.Addrs(id)) > 0 {
+			candidates = append(candidates, id)
+		}
+	}
+	q := filter.Query{Filter: f, SortBy: c.Sort, Desc: c.Desc, Limit: c.Limit}
+	selected := q.Select(c.Store, candidates, nil)
+
+	parallel := c.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var connected []peer.ID
+	for _, p := range selected {
+		id := p.Data.PeerID
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			dialCtx := ctx
+			if c.Timeout != 0 {
+				var cancel context.CancelFunc
+				dialCtx, cancel = context.WithTimeout(ctx, c.Timeout)
+				defer cancel()
+			}
+			if err := h.Connect(dialCtx, c.Store.PeerInfo(id)); err != nil {
+				c.Log.WithError(err).WithField("peer_id", id.Pretty()).Debug("failed to dial peer")
+				return
+			}
+			lock.Lock()
+			connected = append(connected, id)
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+	c.Log.WithField("connected", connected).Infof("connected to %d of %d dialed peers", len(connected), len(selected))
+	return nil
+}
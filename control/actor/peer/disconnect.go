@@ -4,15 +4,32 @@ import (
 	"context"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/flags"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/sirupsen/logrus"
+	"time"
 )
 
 type PeerDisconnectCmd struct {
 	*base.Base
-	PeerID flags.PeerIDFlag `ask:"<peer-id>" help:"The peer to close all connections of"`
+	Book        track.GoodbyeBook
+	Conns       track.ConnectionBook
+	PeerID      flags.PeerIDFlag      `ask:"<peer-id>" help:"The peer to close all connections of"`
+	Goodbye     bool                  `ask:"--goodbye" help:"Send a goodbye before disconnecting"`
+	Reason      flags.GoodbyeFlag     `ask:"--reason" help:"The goodbye reason, by name or number"`
+	Timeout     time.Duration         `ask:"--timeout" help:"Timeout for sending the goodbye. 0 to disable"`
+	Compression flags.CompressionFlag `ask:"--compression" help:"Encoding of the goodbye. 'ssz' (or 'none') for uncompressed, 'ssz_snappy' (or 'snappy') for streaming-snappy, or any other registered encoding"`
+}
+
+func (c *PeerDisconnectCmd) Default() {
+	c.Reason.Reason = methods.GoodbyeClientShutdown
+	c.Timeout = 5 * time.Second
+	c.Compression.Encoding = reqresp.SSZSnappyEncoding
 }
 
 func (c *PeerDisconnectCmd) Help() string {
-	return "Close all open connections with the given peer"
+	return "Close all open connections with the given peer, optionally after a goodbye"
 }
 
 func (c *PeerDisconnectCmd) Run(ctx context.Context, args ...string) error {
@@ -20,13 +37,28 @@ func (c *PeerDisconnectCmd) Run(ctx context.Context, args ...string) error {
 	if err != nil {
 		return err
 	}
-	conns := h.Network().ConnsToPeer(c.PeerID.PeerID)
-	for _, conn := range conns {
-		if err := conn.Close(); err != nil {
-			c.Log.Infof("error during disconnect of peer %s (%s)",
-				c.PeerID.PeerID.Pretty(), conn.RemoteMultiaddr().String())
+	peerID := c.PeerID.PeerID
+	if c.Goodbye {
+		reqCtx := ctx
+		if c.Timeout != 0 {
+			var cancel context.CancelFunc
+			reqCtx, cancel = context.WithTimeout(reqCtx, c.Timeout)
+			defer cancel()
+		}
+		f := logrus.Fields{
+			"peer":   peerID.String(),
+			"code":   uint64(c.Reason.Reason),
+			"reason": c.Reason.Reason.Name(),
+		}
+		// disconnect regardless, the peer may not be responsive.
+		if err := sendGoodbye(reqCtx, h, c.Book, peerID, c.Compression.Encoding, c.Reason.Reason); err != nil {
+			c.Log.WithFields(f).WithError(err).Warn("failed to send goodbye before disconnect")
+		} else {
+			c.Log.WithFields(f).Info("sent goodbye")
 		}
 	}
-	c.Log.Infof("disconnected peer %s", c.PeerID.PeerID.Pretty())
+	c.Conns.SetDisconnectReason(peerID, "disconnect command")
+	closePeer(c.Log, h, peerID)
+	c.Log.Infof("disconnected peer %s", peerID.Pretty())
 	return nil
 }
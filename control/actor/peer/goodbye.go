@@ -0,0 +1,191 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/flags"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+type PeerGoodbyeCmd struct {
+	*base.Base
+	Book track.GoodbyeBook
+}
+
+func (c *PeerGoodbyeCmd) Help() string {
+	return fmt.Sprintf("Send and serve goodbye messages. Known reasons: %s",
+		strings.Join(methods.GoodbyeNames(), ", "))
+}
+
+func (c *PeerGoodbyeCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "send":
+		cmd = &PeerGoodbyeSendCmd{Base: c.Base, Book: c.Book}
+	case "serve":
+		cmd = &PeerGoodbyeServeCmd{Base: c.Base, Book: c.Book}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *PeerGoodbyeCmd) Routes() []string {
+	return []string{"send", "serve"}
+}
+
+// sendGoodbye sends the goodbye to the peer, and records it in the book if it was sent successfully.
+func sendGoodbye(ctx context.Context, h host.Host, book track.GoodbyeBook,
+	peerID peer.ID, enc *reqresp.Encoding, reason methods.Goodbye) error {
+	// no response to a goodbye, only wait for the request to be written.
+	err := methods.GoodbyeRPCv1.RunRequest(ctx, h.NewStream, peerID, enc,
+		reqresp.RequestSSZInput{Obj: &reason}, 0,
+		func() error {
+			return nil
+		},
+		func(chunk reqresp.ChunkedResponseHandler) error {
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	book.RegisterGoodbye(peerID, reason, false)
+	return nil
+}
+
+// closePeer closes all connections with the peer
+func closePeer(log logrus.FieldLogger, h host.Host, peerID peer.ID) {
+	for _, conn := range h.Network().ConnsToPeer(peerID) {
+		if err := conn.Close(); err != nil {
+			log.Infof("error during disconnect of peer %s (%s)",
+				peerID.Pretty(), conn.RemoteMultiaddr().String())
+		}
+	}
+}
+
+type PeerGoodbyeSendCmd struct {
+	*base.Base
+	Book        track.GoodbyeBook
+	PeerID      flags.PeerIDFlag      `ask:"<peer-id>" help:"The peer to say goodbye to"`
+	Reason      flags.GoodbyeFlag     `ask:"[reason]" help:"The reason, by name or number"`
+	Timeout     time.Duration         `ask:"--timeout" help:"Timeout for sending the goodbye. 0 to disable"`
+	Compression flags.CompressionFlag `ask:"--compression" help:"Encoding. 'ssz' (or 'none') for uncompressed, 'ssz_snappy' (or 'snappy') for streaming-snappy, or any other registered encoding"`
+	Disconnect  bool                  `ask:"--disconnect" help:"Close all connections with the peer after sending the goodbye"`
+}
+
+func (c *PeerGoodbyeSendCmd) Default() {
+	c.Reason.Reason = methods.GoodbyeClientShutdown
+	c.Timeout = 10 * time.Second
+	c.Compression.Encoding = reqresp.SSZSnappyEncoding
+}
+
+func (c *PeerGoodbyeSendCmd) Help() string {
+	return "Send a goodbye to a peer"
+}
+
+func (c *PeerGoodbyeSendCmd) Run(ctx context.Context, args ...string) error {
+	h, err := c.Host()
+	if err != nil {
+		return err
+	}
+	reqCtx := ctx
+	if c.Timeout != 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(reqCtx, c.Timeout)
+		defer cancel()
+	}
+	peerID := c.PeerID.PeerID
+	f := logrus.Fields{
+		"peer":   peerID.String(),
+		"code":   uint64(c.Reason.Reason),
+		"reason": c.Reason.Reason.Name(),
+	}
+	sendErr := sendGoodbye(reqCtx, h, c.Book, peerID, c.Compression.Encoding, c.Reason.Reason)
+	if sendErr != nil {
+		c.Log.WithFields(f).WithError(sendErr).Warn("failed to send goodbye")
+	} else {
+		c.Log.WithFields(f).Info("sent goodbye")
+	}
+	if c.Disconnect {
+		closePeer(c.Log, h, peerID)
+		c.Log.WithFields(f).Info("disconnected peer after goodbye")
+	}
+	return sendErr
+}
+
+type PeerGoodbyeServeCmd struct {
+	*base.Base
+	Book       track.GoodbyeBook
+	Timeout    time.Duration       `ask:"--timeout" help:"Apply timeout of n milliseconds to each stream (complete request <> response time). 0 to Disable timeout"`
+	Encodings  flags.EncodingsFlag `ask:"--encodings" help:"Comma-separated encodings to serve, e.g. 'ssz,ssz_snappy'. 'all' for all registered encodings"`
+	Disconnect bool                `ask:"--disconnect" help:"Close all connections with a peer after receiving a goodbye from it"`
+}
+
+func (c *PeerGoodbyeServeCmd) Default() {
+	c.Timeout = 10 * time.Second
+}
+
+func (c *PeerGoodbyeServeCmd) Help() string {
+	return "Serve incoming goodbyes: log and record them, and optionally disconnect"
+}
+
+func (c *PeerGoodbyeServeCmd) Run(ctx context.Context, args ...string) error {
+	h, err := c.Host()
+	if err != nil {
+		return err
+	}
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	listenReq := func(ctx context.Context, peerId peer.ID, handler reqresp.ChunkedRequestHandler) {
+		f := logrus.Fields{
+			"from": peerId.String(),
+		}
+		var reason methods.Goodbye
+		if err := handler.ReadRequest(&reason); err != nil {
+			f["input_err"] = err.Error()
+			c.Log.WithFields(f).Warnf("failed to read goodbye: %v", err)
+			return
+		}
+		f["code"] = uint64(reason)
+		f["reason"] = reason.Name()
+		c.Book.RegisterGoodbye(peerId, reason, true)
+		c.Log.WithFields(f).Info("received goodbye")
+		if c.Disconnect {
+			// the goodbye has no response, the stream is closed after this handler returns.
+			go func() {
+				closePeer(c.Log, h, peerId)
+				c.Log.WithFields(f).Info("disconnected peer after goodbye")
+			}()
+		}
+	}
+	m := methods.GoodbyeRPCv1
+	protocols := m.SetStreamHandlers(h.SetStreamHandler, c.Encodings.Selected(), func(enc *reqresp.Encoding) network.StreamHandler {
+		return func(stream network.Stream) {
+			reqCtx, reqCancel := bgCtx, context.CancelFunc(func() {})
+			if c.Timeout != 0 {
+				reqCtx, reqCancel = context.WithTimeout(bgCtx, c.Timeout)
+			}
+			defer reqCancel()
+			m.MakeStreamHandler(func() context.Context { return reqCtx }, enc, listenReq)(stream)
+		}
+	})
+	c.Log.WithField("started", true).WithField("protocols", protocols).Info("Started serving goodbyes")
+
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		for _, prot := range protocols {
+			h.RemoveStreamHandler(prot)
+		}
+		c.Log.Infof("Stopped serving goodbyes")
+		return nil
+	})
+	return nil
+}
@@ -66,6 +66,15 @@ func (c *PeerInfoCmd) Run(ctx context.Context, args ...string) error {
 	if info.ENR != nil {
 		f["enr"] = info.ENR
 	}
+	if info.RPCStats != nil {
+		f["rpc_stats"] = info.RPCStats
+	}
+	if info.Goodbye != nil {
+		f["goodbye"] = info.Goodbye
+	}
+	if info.Connection != nil {
+		f["connection"] = info.Connection
+	}
 	c.Log.WithFields(f).Infof("peer info")
 	return nil
 }
@@ -2,22 +2,27 @@ package peer
 
 import (
 	"context"
-	"fmt"
+	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/filter"
 )
 
 type PeerListCmd struct {
 	*base.Base
 	Store track.ExtendedPeerstore
 
-	Which   string `ask:"[which]" help:"Which peers to list, possible values: 'all', 'connected'."`
+	Which   string `ask:"[which]" help:"Which peers to list, possible values: 'all', 'connected'. Any other value is the first filter condition, on all peers."`
 	Details bool   `ask:"--details" help:"List detailed data of each peer"`
+	Sort    string `ask:"--sort" help:"Peer data field to sort by, e.g. 'status.head_slot'"`
+	Desc    bool   `ask:"--desc" help:"Sort in descending order"`
+	Limit   int    `ask:"--limit" help:"Max number of peers to list, 0 for no limit"`
 }
 
 func (c *PeerListCmd) Help() string {
-	return "List peers."
+	return "List peers. Remaining arguments are filter conditions on the peer data, all must match, e.g. " +
+		"'fork_digest=e7a75d5a', 'status.head_slot>1000', 'user_agent~Lighthouse', 'attnets has 12', 'connected' or '!connected'."
 }
 
 func (c *PeerListCmd) Default() {
@@ -36,16 +41,29 @@ func (c *PeerListCmd) Run(ctx context.Context, args ...string) error {
 		}
 		peers = h.Network().Peers()
 	default:
-		return fmt.Errorf("invalid peer selection type: %s", c.Which)
+		args = append([]string{c.Which}, args...)
+		peers = c.Store.Peers()
+	}
+	f, err := filter.Parse(args...)
+	if err != nil {
+		return err
+	}
+	var connected func(id peer.ID) bool
+	if hostErr == nil {
+		connected = func(id peer.ID) bool {
+			return h.Network().Connectedness(id) == network.Connected
+		}
 	}
+	q := filter.Query{Filter: f, SortBy: c.Sort, Desc: c.Desc, Limit: c.Limit}
+	selected := q.Select(c.Store, peers, connected)
 	if c.Details {
 		peerData := make(map[string]*track.PeerAllData)
-		for _, p := range peers {
-			peerData[p.String()] = c.Store.GetAllData(p)
+		for _, p := range selected {
+			peerData[p.Data.PeerID.String()] = p.Data
 		}
-		c.Log.WithField("peers", peerData).Infof("%d peers", len(peers))
+		c.Log.WithField("peers", peerData).Infof("%d peers", len(selected))
 	} else {
-		c.Log.WithField("peers", peers).Infof("%d peers", len(peers))
+		c.Log.WithField("peers", filter.IDs(selected)).Infof("%d peers", len(selected))
 	}
 	return nil
 }
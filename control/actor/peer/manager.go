@@ -0,0 +1,472 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/dv5"
+	"github.com/protolambda/rumor/control/actor/flags"
+	"github.com/protolambda/rumor/control/actor/peer/status"
+	"github.com/protolambda/rumor/p2p/addrutil"
+	"github.com/protolambda/rumor/p2p/peering"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/filter"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"sort"
+	"sync"
+	"time"
+)
+
+type PeerManagerStats struct {
+	Connected int `json:"connected"`
+	// Nodes found with discv5, added to the peerstore as candidates
+	Discovered uint64 `json:"discovered"`
+	Dialed     uint64 `json:"dialed"`
+	DialFailed uint64 `json:"dial_failed"`
+	// Status requests to connected peers
+	StatusRequested uint64 `json:"status_requested"`
+	StatusFailed    uint64 `json:"status_failed"`
+	// Irrelevant peers that were disconnected
+	Irrelevant uint64 `json:"irrelevant"`
+	// Peers that were disconnected to get back under the high water mark
+	Pruned uint64 `json:"pruned"`
+	// Peers covering each needed subnet
+	Coverage map[uint64]int `json:"coverage,omitempty"`
+	LastTick time.Time      `json:"last_tick"`
+}
+
+type PeerManagerState struct {
+	lock    sync.Mutex
+	running bool
+	stats   PeerManagerStats
+	// peer -> time of the last failed dial
+	backoff map[peer.ID]time.Time
+	// connected peer -> time of the last status request
+	statusTimes map[peer.ID]time.Time
+	// true while below the target, to discover more candidates
+	needPeers bool
+}
+
+type PeerManagerCmd struct {
+	*base.Base
+	*PeerManagerState
+	*status.PeerStatusState
+	*dv5.Dv5State
+	Store track.ExtendedPeerstore
+}
+
+func (c *PeerManagerCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "start":
+		cmd = &PeerManagerStartCmd{Base: c.Base, PeerManagerState: c.PeerManagerState,
+			PeerStatusState: c.PeerStatusState, Dv5State: c.Dv5State, Store: c.Store}
+	case "status":
+		cmd = &PeerManagerStatusCmd{Base: c.Base, PeerManagerState: c.PeerManagerState}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *PeerManagerCmd) Routes() []string {
+	return []string{"start", "status"}
+}
+
+func (c *PeerManagerCmd) Help() string {
+	return "Automatically manage peer connections: dial until a target peer count, and disconnect irrelevant peers"
+}
+
+type PeerManagerStartCmd struct {
+	*base.Base
+	*PeerManagerState
+	*status.PeerStatusState
+	*dv5.Dv5State
+	Store track.ExtendedPeerstore
+
+	Target         int               `ask:"--target" help:"Target number of connected peers, candidates are dialed while below"`
+	High           int               `ask:"--high" help:"Max number of connected peers, the peers covering the fewest needed subnets are disconnected while above. 0 to disable."`
+	Interval       time.Duration     `ask:"--interval" help:"Interval between checks of the connected peers"`
+	DialTimeout    time.Duration     `ask:"--dial-timeout" help:"Timeout of each dial"`
+	Parallel       int               `ask:"--parallel" help:"Max number of dials at the same time"`
+	Backoff        time.Duration     `ask:"--backoff" help:"Time to wait before dialing a peer again after a failed dial"`
+	StatusInterval time.Duration     `ask:"--status-interval" help:"Interval to request the status of connected peers at, before checking them. New peers are requested on the first check. 0 to only use statuses in the peerstore."`
+	StatusTimeout  time.Duration     `ask:"--status-timeout" help:"Timeout of each status request"`
+	ForkDigest     beacon.ForkDigest `ask:"--fork-digest" help:"Fork digest peers must be on. The fork digest of our status is used if not specified."`
+	MaxBehind      beacon.Slot       `ask:"--max-behind" help:"Max number of slots the head of a peer may be behind ours. 0 to disable."`
+	Subnets        []uint            `ask:"--subnets" help:"Attestation subnets we need peers for, comma-separated. Peers covering these are dialed first and kept longest."`
+	SubnetPeers    int               `ask:"--subnet-peers" help:"Wanted number of peers per needed subnet, subnets with fewer peers are preferred"`
+	Filter         []string          `ask:"--filter" help:"Only dial candidates that match all of these filter conditions, comma-separated, see 'peer list'"`
+	Goodbye        bool              `ask:"--goodbye" help:"Send a goodbye before disconnecting a peer"`
+	Discover       bool              `ask:"--discover" help:"Add random discv5 nodes to the peerstore as candidates while below the target, if discv5 is running"`
+	TTL            time.Duration     `ask:"--ttl" help:"TTL of the addresses of discovered nodes in the peerstore"`
+
+	Compression flags.CompressionFlag `ask:"--compression" help:"Encoding of goodbyes and status requests"`
+
+	ForkDigestChanged bool `changed:"fork-digest"`
+}
+
+func (c *PeerManagerStartCmd) Default() {
+	c.Target = 30
+	c.High = 50
+	c.Interval = 10 * time.Second
+	c.DialTimeout = 10 * time.Second
+	c.Parallel = 8
+	c.Backoff = time.Minute
+	c.StatusInterval = 5 * time.Minute
+	c.StatusTimeout = 10 * time.Second
+	c.MaxBehind = 8 * beacon.SLOTS_PER_EPOCH
+	c.SubnetPeers = 2
+	c.Goodbye = true
+	c.Discover = true
+	c.TTL = time.Hour * 24 * 30 * 3
+	c.Compression.Encoding = reqresp.SSZSnappyEncoding
+}
+
+func (c *PeerManagerStartCmd) Help() string {
+	return "Start managing peers in the background, until the command is stopped. " +
+		"Candidates are dialed from the peerstore. If discv5 is running when the manager starts, " +
+		"random discv5 nodes are added to the peerstore as candidates while below the target, see '--discover'. " +
+		"Peers on a wrong fork digest, with a finalized checkpoint conflicting with ours, or a head too far behind ours, are disconnected. " +
+		"The status of new peers, and of peers with a status older than the status interval, is requested before checking them. " +
+		"Status requests need a local status, see 'peer status set' and 'peer status follow'."
+}
+
+func (c *PeerManagerStartCmd) Run(ctx context.Context, args ...string) error {
+	h, err := c.Host()
+	if err != nil {
+		return err
+	}
+	if c.High != 0 && c.High < c.Target {
+		return errors.New("high water mark must not be lower than the target")
+	}
+	needed := make([]uint64, len(c.Subnets))
+	for i, s := range c.Subnets {
+		needed[i] = uint64(s)
+	}
+	if err := peering.CheckSubnets(needed); err != nil {
+		return err
+	}
+	f, err := filter.Parse(c.Filter...)
+	if err != nil {
+		return err
+	}
+	st := c.PeerManagerState
+	st.lock.Lock()
+	if st.running {
+		st.lock.Unlock()
+		return errors.New("already managing peers")
+	}
+	st.running = true
+	st.stats = PeerManagerStats{}
+	st.backoff = make(map[peer.ID]time.Time)
+	st.statusTimes = make(map[peer.ID]time.Time)
+	st.needPeers = false
+	st.lock.Unlock()
+
+	var randomNodes enode.Iterator
+	if c.Discover && c.Dv5State.Dv5Node != nil {
+		randomNodes = c.Dv5State.Dv5Node.RandomNodes()
+	}
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	discoverDone := make(chan struct{})
+	go func() {
+		defer close(discoverDone)
+		if randomNodes != nil {
+			c.discover(bgCtx, h, randomNodes)
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+		for {
+			c.tick(bgCtx, h, f, needed)
+			select {
+			case <-ticker.C:
+			case <-bgCtx.Done():
+				return
+			}
+		}
+	}()
+	c.Log.WithField("target", c.Target).WithField("high", c.High).WithField("subnets", needed).
+		WithField("discover", randomNodes != nil).Info("Started managing peers")
+
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		if randomNodes != nil {
+			randomNodes.Close()
+		}
+		<-discoverDone
+		<-done
+		st.lock.Lock()
+		st.running = false
+		st.lock.Unlock()
+		c.Log.Info("Stopped managing peers")
+		return nil
+	})
+	return nil
+}
+
+func (c *PeerManagerStartCmd) rules() *peering.RelevanceRules {
+	rules := &peering.RelevanceRules{MaxBehind: c.MaxBehind}
+	if local := c.PeerStatusState.Local(); local != (methods.Status{}) {
+		rules.Local = &local
+	}
+	if c.ForkDigestChanged {
+		rules.ForkDigest = &c.ForkDigest
+	}
+	return rules
+}
+
+// discover adds random discv5 nodes to the peerstore, while below the target.
+func (c *PeerManagerStartCmd) discover(ctx context.Context, h host.Host, randomNodes enode.Iterator) {
+	st := c.PeerManagerState
+	for {
+		st.lock.Lock()
+		needPeers := st.needPeers
+		st.lock.Unlock()
+		if !needPeers {
+			select {
+			case <-time.After(c.Interval):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if !randomNodes.Next() {
+			return
+		}
+		n := randomNodes.Node()
+		id := addrutil.PeerIDFromPubkey(n.Pubkey())
+		if id == h.ID() || n.TCP() == 0 {
+			continue
+		}
+		if updated, err := c.Store.UpdateENRMaybe(id, n); err != nil {
+			c.Log.WithError(err).WithField("peer", id.Pretty()).Debug("failed to update ENR")
+			continue
+		} else if updated {
+			addr, err := addrutil.EnodeToMultiAddr(n)
+			if err != nil {
+				continue
+			}
+			// the swarm dials transport addresses, without the /p2p/ part
+			if transport, _ := peer.SplitAddr(addr); transport != nil {
+				c.Store.SetAddr(id, transport, c.TTL)
+			}
+		}
+		st.lock.Lock()
+		st.stats.Discovered += 1
+		st.lock.Unlock()
+	}
+}
+
+// disconnect closes the connections with the peer, optionally after a goodbye.
+func (c *PeerManagerStartCmd) disconnect(ctx context.Context, h host.Host, id peer.ID, reason string, goodbye methods.Goodbye) {
+	if c.Goodbye {
+		reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := sendGoodbye(reqCtx, h, c.Store, id, c.Compression.Encoding, goodbye); err != nil {
+			c.Log.WithError(err).WithField("peer", id.Pretty()).Debug("failed to send goodbye")
+		}
+		cancel()
+	}
+	c.Store.SetDisconnectReason(id, "peer manager: "+reason)
+	closePeer(c.Log, h, id)
+	c.Log.WithField("peer", id.Pretty()).WithField("reason", reason).Info("peer manager disconnected peer")
+}
+
+// requestStatuses requests the status of connected peers that are new, or of which the status is stale.
+func (c *PeerManagerStartCmd) requestStatuses(ctx context.Context, h host.Host) {
+	if c.StatusInterval == 0 || c.PeerStatusState.Local() == (methods.Status{}) {
+		return
+	}
+	st := c.PeerManagerState
+	now := time.Now()
+	var outdated []peer.ID
+	st.lock.Lock()
+	peers := h.Network().Peers()
+	connected := make(map[peer.ID]struct{}, len(peers))
+	for _, id := range peers {
+		connected[id] = struct{}{}
+		if t, ok := st.statusTimes[id]; !ok || now.Sub(t) >= c.StatusInterval {
+			st.statusTimes[id] = now
+			outdated = append(outdated, id)
+		}
+	}
+	for id := range st.statusTimes {
+		if _, ok := connected[id]; !ok {
+			delete(st.statusTimes, id)
+		}
+	}
+	st.lock.Unlock()
+
+	parallel := c.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for _, id := range outdated {
+		id := id
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			reqCtx, cancel := context.WithTimeout(ctx, c.StatusTimeout)
+			defer cancel()
+			code, msg, _, err := c.PeerStatusState.Fetch(c.Store, h.NewStream, reqCtx, id, c.Compression.Encoding)
+			st.lock.Lock()
+			defer st.lock.Unlock()
+			st.stats.StatusRequested += 1
+			if err != nil || code != reqresp.SuccessCode {
+				st.stats.StatusFailed += 1
+				c.Log.WithError(err).WithField("peer", id.Pretty()).WithField("code", code).WithField("msg", msg).
+					Debug("peer manager failed to request status")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (c *PeerManagerStartCmd) tick(ctx context.Context, h host.Host, f *filter.Filter, needed []uint64) {
+	c.requestStatuses(ctx, h)
+	rules := c.rules()
+	st := c.PeerManagerState
+
+	// disconnect irrelevant peers
+	var connected []*track.PeerAllData
+	for _, id := range h.Network().Peers() {
+		dat := c.Store.GetAllData(id)
+		if reason := rules.Check(dat); reason != "" {
+			c.disconnect(ctx, h, id, reason, methods.GoodbyeIrrelevantNetwork)
+			st.lock.Lock()
+			st.stats.Irrelevant += 1
+			st.lock.Unlock()
+			continue
+		}
+		connected = append(connected, dat)
+	}
+
+	// prune the peers covering the fewest needed subnets, while above the high water mark
+	if c.High != 0 && len(connected) > c.High {
+		coverage := peering.Coverage(connected, needed)
+		sort.SliceStable(connected, func(i, j int) bool {
+			return peering.SubnetScore(connected[i], needed, coverage, c.SubnetPeers) <
+				peering.SubnetScore(connected[j], needed, coverage, c.SubnetPeers)
+		})
+		excess := len(connected) - c.High
+		for _, dat := range connected[:excess] {
+			c.disconnect(ctx, h, dat.PeerID, "too many peers", methods.GoodbyeTooManyPeers)
+		}
+		connected = connected[excess:]
+		st.lock.Lock()
+		st.stats.Pruned += uint64(excess)
+		st.lock.Unlock()
+	}
+	coverage := peering.Coverage(connected, needed)
+
+	// dial the best candidates, while below the target
+	missing := c.Target - len(connected)
+	st.lock.Lock()
+	st.needPeers = missing > 0
+	st.lock.Unlock()
+	if missing > 0 {
+		now := time.Now()
+		st.lock.Lock()
+		for id, t := range st.backoff {
+			if now.Sub(t) >= c.Backoff {
+				delete(st.backoff, id)
+			}
+		}
+		var candidates []*track.PeerAllData
+		for _, id := range c.Store.Peers() {
+			if id == h.ID() || h.Network().Connectedness(id) == network.Connected || len(c.Store.Addrs(id)) == 0 {
+				continue
+			}
+			if t, ok := st.backoff[id]; ok && now.Sub(t) < c.Backoff {
+				continue
+			}
+			dat := c.Store.GetAllData(id)
+			if rules.Check(dat) != "" || !f.Match(&filter.Peer{Data: dat}) {
+				continue
+			}
+			candidates = append(candidates, dat)
+		}
+		st.lock.Unlock()
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return peering.SubnetScore(candidates[i], needed, coverage, c.SubnetPeers) >
+				peering.SubnetScore(candidates[j], needed, coverage, c.SubnetPeers)
+		})
+		if len(candidates) > missing {
+			candidates = candidates[:missing]
+		}
+		c.dial(ctx, h, candidates)
+	}
+
+	st.lock.Lock()
+	st.stats.Connected = len(h.Network().Peers())
+	st.stats.Coverage = peering.Coverage(connected, needed)
+	st.stats.LastTick = time.Now()
+	st.lock.Unlock()
+}
+
+func (c *PeerManagerStartCmd) dial(ctx context.Context, h host.Host, candidates []*track.PeerAllData) {
+	st := c.PeerManagerState
+	parallel := c.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for _, dat := range candidates {
+		id := dat.PeerID
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			dialCtx, cancel := context.WithTimeout(ctx, c.DialTimeout)
+			defer cancel()
+			err := h.Connect(dialCtx, c.Store.PeerInfo(id))
+			st.lock.Lock()
+			defer st.lock.Unlock()
+			st.stats.Dialed += 1
+			if err != nil {
+				st.stats.DialFailed += 1
+				st.backoff[id] = time.Now()
+				c.Log.WithError(err).WithField("peer", id.Pretty()).Debug("peer manager failed to dial peer")
+			} else {
+				delete(st.backoff, id)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+type PeerManagerStatusCmd struct {
+	*base.Base
+	*PeerManagerState
+}
+
+func (c *PeerManagerStatusCmd) Help() string {
+	return "Show the peer manager stats"
+}
+
+func (c *PeerManagerStatusCmd) Run(ctx context.Context, args ...string) error {
+	st := c.PeerManagerState
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if !st.running {
+		return errors.New("not managing peers, try 'peer manager start'")
+	}
+	c.Log.WithField("stats", st.stats).Info("peer manager stats")
+	return nil
+}
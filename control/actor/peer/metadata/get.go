@@ -16,9 +16,10 @@ func (c *PeerMetadataGetCmd) Help() string {
 }
 
 func (c *PeerMetadataGetCmd) Run(ctx context.Context, args ...string) error {
+	local := c.PeerMetadataState.Local()
 	c.Log.WithFields(logrus.Fields{
 		"following": c.PeerMetadataState.Following,
-		"metadata":  c.PeerMetadataState.Local.Data(),
+		"metadata":  local.Data(),
 	}).Info("Metadata settings")
 	return nil
 }
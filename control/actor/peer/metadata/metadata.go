@@ -8,11 +8,41 @@ import (
 	"github.com/protolambda/rumor/p2p/rpc/methods"
 	"github.com/protolambda/rumor/p2p/rpc/reqresp"
 	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/types"
+	"sync"
 )
 
 type PeerMetadataState struct {
 	Following bool
-	Local     methods.MetaData
+
+	// the local metadata may be updated while it is served, e.g. on subnet changes
+	lock  sync.RWMutex
+	local methods.MetaData
+}
+
+// Local returns a copy of the local metadata
+func (s *PeerMetadataState) Local() methods.MetaData {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.local
+}
+
+func (s *PeerMetadataState) SetLocal(md methods.MetaData) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.local = md
+}
+
+// SetAttnets updates the attnets of the local metadata, and increments the seq number if they changed.
+func (s *PeerMetadataState) SetAttnets(attnets types.AttnetBits) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.local.Attnets != attnets {
+		s.local = methods.MetaData{
+			SeqNumber: s.local.SeqNumber + 1,
+			Attnets:   attnets,
+		}
+	}
 }
 
 type PeerMetadataCmd struct {
@@ -53,10 +83,10 @@ func (c *PeerMetadataCmd) Routes() []string {
 	return []string{"ping", "pong", "get", "set", "req", "poll", "serve", "follow"}
 }
 
-func (c *PeerMetadataState) fetch(book track.MetadataBook, sFn reqresp.NewStreamFn, ctx context.Context, peerID peer.ID, comp reqresp.Compression) (
+func (c *PeerMetadataState) fetch(book track.MetadataBook, sFn reqresp.NewStreamFn, ctx context.Context, peerID peer.ID, enc *reqresp.Encoding) (
 	resCode reqresp.ResponseCode, errMsg string, data *methods.MetaData, err error) {
 
-	err = methods.MetaDataRPCv1.RunRequest(ctx, sFn, peerID, comp, reqresp.RequestSSZInput{Obj: nil}, 1,
+	err = methods.MetaDataRPCv1.RunRequest(ctx, sFn, peerID, enc, reqresp.RequestSSZInput{Obj: nil}, 1,
 		func() error {
 			// TODO
 			return nil
@@ -83,11 +113,11 @@ func (c *PeerMetadataState) fetch(book track.MetadataBook, sFn reqresp.NewStream
 	return
 }
 
-func (c *PeerMetadataState) ping(sFn reqresp.NewStreamFn, ctx context.Context, peerID peer.ID, comp reqresp.Compression) (
+func (c *PeerMetadataState) ping(sFn reqresp.NewStreamFn, ctx context.Context, peerID peer.ID, enc *reqresp.Encoding) (
 	resCode reqresp.ResponseCode, errMsg string, data methods.Pong, err error) {
 
-	p := methods.Ping(c.Local.SeqNumber)
-	err = methods.PingRPCv1.RunRequest(ctx, sFn, peerID, comp, reqresp.RequestSSZInput{Obj: &p}, 1,
+	p := methods.Ping(c.Local().SeqNumber)
+	err = methods.PingRPCv1.RunRequest(ctx, sFn, peerID, enc, reqresp.RequestSSZInput{Obj: &p}, 1,
 		func() error {
 			return nil
 		},
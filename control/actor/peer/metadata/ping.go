@@ -20,7 +20,7 @@ type PeerMetadataPingCmd struct {
 	*PeerMetadataState
 	Store         track.ExtendedPeerstore
 	Timeout       time.Duration         `ask:"--timeout" help:"request timeout for ping, 0 to disable"`
-	Compression   flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
+	Compression   flags.CompressionFlag `ask:"--compression" help:"Encoding. 'ssz' (or 'none') for uncompressed, 'ssz_snappy' (or 'snappy') for streaming-snappy, or any other registered encoding"`
 	Update        bool                  `ask:"--update" help:"If the seq nr pong is higher than known, request metadata"`
 	ForceUpdate   bool                  `ask:"--force-update" help:"Force a metadata request, even if the ping results in an already known pong seq nr"`
 	UpdateTimeout time.Duration         `ask:"--update-timeout" help:"If updating, use this timeout for the update request, 0 to disable."`
@@ -35,7 +35,7 @@ func (c *PeerMetadataPingCmd) Help() string {
 func (c *PeerMetadataPingCmd) Default() {
 	c.Timeout = 10 * time.Second
 	c.UpdateTimeout = 10 * time.Second
-	c.Compression = flags.CompressionFlag{Compression: reqresp.SnappyCompression{}}
+	c.Compression = flags.CompressionFlag{Encoding: reqresp.SSZSnappyEncoding}
 	c.Update = true
 }
 
@@ -55,7 +55,7 @@ func (c *PeerMetadataPingCmd) Run(ctx context.Context, args ...string) error {
 		startTime = time.Now()
 		return h.NewStream(ctx, peerId, protocolId...)
 	})
-	code, msg, pong, err := c.ping(newStream, reqCtx, peerID, c.Compression.Compression)
+	code, msg, pong, err := c.ping(newStream, reqCtx, peerID, c.Compression.Encoding)
 	if err != nil {
 		return fmt.Errorf("failed to ping: %v", err)
 	} else {
@@ -87,7 +87,7 @@ func (c *PeerMetadataPingCmd) Run(ctx context.Context, args ...string) error {
 		if c.UpdateTimeout != 0 {
 			updateCtx, _ = context.WithTimeout(updateCtx, c.UpdateTimeout)
 		}
-		code, msg, metadata, err := c.fetch(c.Store, h.NewStream, updateCtx, peerID, c.Compression.Compression)
+		code, msg, metadata, err := c.fetch(c.Store, h.NewStream, updateCtx, peerID, c.Compression.Encoding)
 		if err != nil {
 			return fmt.Errorf("failed to fetch metadata upon pong: %v", err)
 		} else {
@@ -20,14 +20,14 @@ type PeerMetadataPollCmd struct {
 	Update        bool                  `ask:"--update" help:"If the seq nr pong is higher than known, request metadata"`
 	ForceUpdate   bool                  `ask:"--force-update" help:"Force a metadata request, even if the ping results in an already known pong seq nr"`
 	UpdateTimeout time.Duration         `ask:"--update-timeout" help:"If updating, use this timeout for the update request, 0 to disable."`
-	Compression   flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
+	Compression   flags.CompressionFlag `ask:"--compression" help:"Encoding. 'ssz' (or 'none') for uncompressed, 'ssz_snappy' (or 'snappy') for streaming-snappy, or any other registered encoding"`
 }
 
 func (c *PeerMetadataPollCmd) Default() {
 	c.Timeout = 10 * time.Second
 	c.UpdateTimeout = 10 * time.Second
 	c.Interval = 20 * time.Second
-	c.Compression = flags.CompressionFlag{Compression: reqresp.SnappyCompression{}}
+	c.Compression = flags.CompressionFlag{Encoding: reqresp.SSZSnappyEncoding}
 	c.Update = true
 }
 
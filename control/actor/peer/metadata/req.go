@@ -16,7 +16,7 @@ type PeerMetadataReqCmd struct {
 	*PeerMetadataState
 	Book        track.MetadataBook
 	Timeout     time.Duration         `ask:"--timeout" help:"request timeout, 0 to disable"`
-	Compression flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
+	Compression flags.CompressionFlag `ask:"--compression" help:"Encoding. 'ssz' (or 'none') for uncompressed, 'ssz_snappy' (or 'snappy') for streaming-snappy, or any other registered encoding"`
 	PeerID      flags.PeerIDFlag      `ask:"<peer-id>" help:"Peer to fetch metadata from."`
 }
 
@@ -26,7 +26,7 @@ func (c *PeerMetadataReqCmd) Help() string {
 
 func (c *PeerMetadataReqCmd) Default() {
 	c.Timeout = 10 * time.Second
-	c.Compression = flags.CompressionFlag{Compression: reqresp.SnappyCompression{}}
+	c.Compression = flags.CompressionFlag{Encoding: reqresp.SSZSnappyEncoding}
 }
 
 func (c *PeerMetadataReqCmd) Run(ctx context.Context, args ...string) error {
@@ -38,7 +38,7 @@ func (c *PeerMetadataReqCmd) Run(ctx context.Context, args ...string) error {
 	if c.Timeout != 0 {
 		reqCtx, _ = context.WithTimeout(reqCtx, c.Timeout)
 	}
-	code, msg, metadata, err := c.fetch(c.Book, h.NewStream, reqCtx, c.PeerID.PeerID, c.Compression.Compression)
+	code, msg, metadata, err := c.fetch(c.Book, h.NewStream, reqCtx, c.PeerID.PeerID, c.Compression.Encoding)
 	if err != nil {
 		return fmt.Errorf("failed to fetch metadata: %v", err)
 	} else {
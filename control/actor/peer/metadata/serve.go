@@ -2,8 +2,8 @@ package metadata
 
 import (
 	"context"
+	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
-	"github.com/libp2p/go-libp2p-core/protocol"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/flags"
 	"github.com/protolambda/rumor/p2p/rpc/methods"
@@ -14,8 +14,8 @@ import (
 type PeerMetadataServeCmd struct {
 	*base.Base
 	*PeerMetadataState
-	Timeout     time.Duration         `ask:"--timeout" help:"Apply timeout of n milliseconds to each stream (complete request <> response time). 0 to Disable timeout"`
-	Compression flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
+	Timeout   time.Duration       `ask:"--timeout" help:"Apply timeout of n milliseconds to each stream (complete request <> response time). 0 to Disable timeout"`
+	Encodings flags.EncodingsFlag `ask:"--encodings" help:"Comma-separated encodings to serve, e.g. 'ssz,ssz_snappy'. 'all' for all registered encodings"`
 }
 
 func (c *PeerMetadataServeCmd) Help() string {
@@ -24,7 +24,6 @@ func (c *PeerMetadataServeCmd) Help() string {
 
 func (c *PeerMetadataServeCmd) Default() {
 	c.Timeout = 10 * time.Second
-	c.Compression = flags.CompressionFlag{Compression: reqresp.SnappyCompression{}}
 }
 
 func (c *PeerMetadataServeCmd) Run(ctx context.Context, args ...string) error {
@@ -40,7 +39,6 @@ func (c *PeerMetadataServeCmd) Run(ctx context.Context, args ...string) error {
 		reqCtx, _ := context.WithTimeout(bgCtx, c.Timeout)
 		return reqCtx
 	}
-	comp := c.Compression.Compression
 	listenReq := func(ctx context.Context, peerId peer.ID, handler reqresp.ChunkedRequestHandler) {
 		f := map[string]interface{}{
 			"from": peerId.String(),
@@ -52,7 +50,8 @@ func (c *PeerMetadataServeCmd) Run(ctx context.Context, args ...string) error {
 			_ = handler.WriteErrorChunk(reqresp.InvalidReqCode, "could not parse metadata request")
 			c.Log.WithFields(f).Warnf("failed to read metadata request: %v", err)
 		} else {
-			if err := handler.WriteResponseChunk(reqresp.SuccessCode, &c.PeerMetadataState.Local); err != nil {
+			local := c.PeerMetadataState.Local()
+			if err := handler.WriteResponseChunk(reqresp.SuccessCode, &local); err != nil {
 				c.Log.WithFields(f).Warnf("failed to respond to metadata request: %v", err)
 			} else {
 				c.Log.WithFields(f).Info("handled metadata request")
@@ -60,17 +59,16 @@ func (c *PeerMetadataServeCmd) Run(ctx context.Context, args ...string) error {
 		}
 	}
 	m := methods.MetaDataRPCv1
-	streamHandler := m.MakeStreamHandler(sCtxFn, comp, listenReq)
-	prot := m.Protocol
-	if comp != nil {
-		prot += protocol.ID("_" + comp.Name())
-	}
-	h.SetStreamHandler(prot, streamHandler)
-	c.Log.WithField("started", true).Info("Started serving metadata")
+	protocols := m.SetStreamHandlers(h.SetStreamHandler, c.Encodings.Selected(), func(enc *reqresp.Encoding) network.StreamHandler {
+		return m.MakeStreamHandler(sCtxFn, enc, listenReq)
+	})
+	c.Log.WithField("started", true).WithField("protocols", protocols).Info("Started serving metadata")
 
 	c.Control.RegisterStop(func(ctx context.Context) error {
 		bgCancel()
-		h.RemoveStreamHandler(prot)
+		for _, prot := range protocols {
+			h.RemoveStreamHandler(prot)
+		}
 		c.Log.Infof("Stopped serving metadata")
 		return nil
 	})
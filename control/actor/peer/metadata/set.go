@@ -27,7 +27,7 @@ func (c *PeerMetadataSetCmd) Help() string {
 func (c *PeerMetadataSetCmd) Run(ctx context.Context, args ...string) error {
 	st := methods.MetaData{}
 	if c.Merge {
-		st = c.PeerMetadataState.Local
+		st = c.PeerMetadataState.Local()
 	}
 	if !c.Merge || c.Attnets != (types.AttnetBits{}) {
 		st.Attnets = c.Attnets
@@ -35,12 +35,12 @@ func (c *PeerMetadataSetCmd) Run(ctx context.Context, args ...string) error {
 	if !c.Merge || c.SeqNumber != 0 {
 		st.SeqNumber = c.SeqNumber
 	}
-	// mutate full metadata at once (TODO: do we need a lock?)
-	c.PeerMetadataState.Local = st
+	// mutate full metadata at once
+	c.PeerMetadataState.SetLocal(st)
 
 	c.Log.WithFields(logrus.Fields{
 		"following": c.PeerMetadataState.Following,
-		"metadata":  c.PeerMetadataState.Local.Data(),
+		"metadata":  st.Data(),
 	}).Info("Metadata settings")
 	return nil
 }
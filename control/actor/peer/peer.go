@@ -4,8 +4,10 @@ import (
 	"errors"
 	"github.com/protolambda/ask"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/dv5"
 	"github.com/protolambda/rumor/control/actor/peer/metadata"
 	"github.com/protolambda/rumor/control/actor/peer/status"
+	"github.com/protolambda/rumor/p2p/peering"
 	"github.com/protolambda/rumor/p2p/track"
 )
 
@@ -13,7 +15,11 @@ type PeerCmd struct {
 	*base.Base
 	*status.PeerStatusState
 	*metadata.PeerMetadataState
-	Store track.ExtendedPeerstore
+	*PeerManagerState
+	*dv5.Dv5State
+	Bans   *peering.BanList
+	Scorer *peering.Scorer
+	Store  track.ExtendedPeerstore
 }
 
 func (c *PeerCmd) Cmd(route string) (cmd interface{}, err error) {
@@ -23,8 +29,17 @@ func (c *PeerCmd) Cmd(route string) (cmd interface{}, err error) {
 	switch route {
 	case "connect":
 		cmd = &PeerConnectCmd{Base: c.Base, Store: c.Store}
+	case "dial":
+		cmd = &PeerDialCmd{Base: c.Base, Store: c.Store}
 	case "disconnect":
-		cmd = &PeerDisconnectCmd{Base: c.Base}
+		cmd = &PeerDisconnectCmd{Base: c.Base, Book: c.Store, Conns: c.Store}
+	case "manager":
+		cmd = &PeerManagerCmd{Base: c.Base, PeerManagerState: c.PeerManagerState,
+			PeerStatusState: c.PeerStatusState, Dv5State: c.Dv5State, Store: c.Store}
+	case "bans":
+		cmd = &PeerBansCmd{Base: c.Base, Bans: c.Bans, Store: c.Store}
+	case "scores":
+		cmd = &PeerScoresCmd{Base: c.Base, Scorer: c.Scorer}
 	case "protect":
 		cmd = &PeerProtectCmd{Base: c.Base}
 	case "unprotect":
@@ -32,7 +47,7 @@ func (c *PeerCmd) Cmd(route string) (cmd interface{}, err error) {
 	case "add":
 		cmd = &PeerAddCmd{Base: c.Base, Store: c.Store}
 	case "trim":
-		cmd = &PeerTrimCmd{Base: c.Base}
+		cmd = &PeerTrimCmd{Base: c.Base, Store: c.Store}
 	case "list":
 		cmd = &PeerListCmd{Base: c.Base, Store: c.Store}
 	case "info":
@@ -41,6 +56,8 @@ func (c *PeerCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = &PeerAddrsCmd{Base: c.Base}
 	case "status":
 		cmd = &status.PeerStatusCmd{Base: c.Base, PeerStatusState: c.PeerStatusState, Book: c.Store}
+	case "goodbye":
+		cmd = &PeerGoodbyeCmd{Base: c.Base, Book: c.Store}
 	case "metadata":
 		cmd = &metadata.PeerMetadataCmd{Base: c.Base, PeerMetadataState: c.PeerMetadataState, Store: c.Store}
 	default:
@@ -50,8 +67,8 @@ func (c *PeerCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *PeerCmd) Routes() []string {
-	return []string{"connect", "disconnect", "protect", "unprotect", "add", "trim",
-		"list", "info", "addrs", "status", "metadata"}
+	return []string{"connect", "dial", "disconnect", "protect", "unprotect", "add", "trim",
+		"list", "info", "addrs", "status", "metadata", "goodbye", "manager", "bans", "scores"}
 }
 
 func (c *PeerCmd) Help() string {
@@ -0,0 +1,102 @@
+package peer
+
+import (
+	"context"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/peering"
+	"time"
+)
+
+type PeerScoresCmd struct {
+	*base.Base
+	Scorer *peering.Scorer
+}
+
+func (c *PeerScoresCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "list":
+		cmd = &PeerScoresListCmd{Base: c.Base, Scorer: c.Scorer}
+	case "params":
+		cmd = &PeerScoresParamsCmd{Base: c.Base, Scorer: c.Scorer}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *PeerScoresCmd) Routes() []string {
+	return []string{"list", "params"}
+}
+
+func (c *PeerScoresCmd) Help() string {
+	return "Peer scores, lowered by RPC errors and timeouts, rejected gossip and received goodbyes. " +
+		"Scores decay back to zero over time, and peers are banned when their score drops below the threshold."
+}
+
+type PeerScoresListCmd struct {
+	*base.Base
+	Scorer *peering.Scorer
+}
+
+func (c *PeerScoresListCmd) Help() string {
+	return "List the scores of penalized peers, lowest first"
+}
+
+func (c *PeerScoresListCmd) Run(ctx context.Context, args ...string) error {
+	scores := c.Scorer.Scores()
+	for _, s := range scores {
+		c.Log.WithField("peer", s.Peer.Pretty()).WithField("score", s.Score).
+			WithField("penalties", s.Penalties).Info("peer score")
+	}
+	c.Log.WithField("count", len(scores)).Info("listed peer scores")
+	return nil
+}
+
+type PeerScoresParamsCmd struct {
+	*base.Base
+	Scorer *peering.Scorer
+
+	RPCError      float64       `ask:"--rpc-error" help:"Penalty for a failed request or invalid response"`
+	RPCTimeout    float64       `ask:"--rpc-timeout" help:"Penalty for a request that timed out"`
+	InvalidGossip float64       `ask:"--invalid-gossip" help:"Penalty for propagating a gossip message that was rejected"`
+	GoodbyeFault  float64       `ask:"--goodbye-fault" help:"Penalty for a received goodbye with the fault_error reason"`
+	GoodbyeOther  float64       `ask:"--goodbye-other" help:"Penalty for a received goodbye with another reason"`
+	HalfLife      time.Duration `ask:"--half-life" help:"Time for a score to decay halfway back to zero"`
+	BanThreshold  float64       `ask:"--ban-threshold" help:"Peers with a score below this negative threshold are banned"`
+	BanDuration   time.Duration `ask:"--ban-duration" help:"How long to ban peers for, 0 to ban without expiry"`
+}
+
+func (c *PeerScoresParamsCmd) Default() {
+	p := c.Scorer.Params()
+	c.RPCError = p.RPCError
+	c.RPCTimeout = p.RPCTimeout
+	c.InvalidGossip = p.InvalidGossip
+	c.GoodbyeFault = p.GoodbyeFault
+	c.GoodbyeOther = p.GoodbyeOther
+	c.HalfLife = p.HalfLife
+	c.BanThreshold = p.BanThreshold
+	c.BanDuration = p.BanDuration
+}
+
+func (c *PeerScoresParamsCmd) Help() string {
+	return "Change the scoring params, and show the resulting params. Params that are not specified are kept."
+}
+
+func (c *PeerScoresParamsCmd) Run(ctx context.Context, args ...string) error {
+	params := peering.ScoreParams{
+		RPCError:      c.RPCError,
+		RPCTimeout:    c.RPCTimeout,
+		InvalidGossip: c.InvalidGossip,
+		GoodbyeFault:  c.GoodbyeFault,
+		GoodbyeOther:  c.GoodbyeOther,
+		HalfLife:      c.HalfLife,
+		BanThreshold:  c.BanThreshold,
+		BanDuration:   c.BanDuration,
+	}
+	if err := c.Scorer.SetParams(params); err != nil {
+		return err
+	}
+	c.Log.WithField("params", params).Info("peer score params")
+	return nil
+}
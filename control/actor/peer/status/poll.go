@@ -17,7 +17,7 @@ type PeerStatusPollCmd struct {
 	Book        track.StatusBook
 	Timeout     time.Duration         `ask:"--timeout" help:"request timeout, 0 to disable."`
 	Interval    time.Duration         `ask:"--interval" help:"interval to request status of peers on, applied as timeout to a round of work"`
-	Compression flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
+	Compression flags.CompressionFlag `ask:"--compression" help:"Encoding. 'ssz' (or 'none') for uncompressed, 'ssz_snappy' (or 'snappy') for streaming-snappy, or any other registered encoding"`
 }
 
 func (c *PeerStatusPollCmd) Help() string {
@@ -27,7 +27,7 @@ func (c *PeerStatusPollCmd) Help() string {
 func (c *PeerStatusPollCmd) Default() {
 	c.Timeout = 5 * time.Second
 	c.Interval = 12 * time.Second
-	c.Compression = flags.CompressionFlag{Compression: reqresp.SnappyCompression{}}
+	c.Compression = flags.CompressionFlag{Encoding: reqresp.SSZSnappyEncoding}
 }
 
 func (c *PeerStatusPollCmd) Run(ctx context.Context, args ...string) error {
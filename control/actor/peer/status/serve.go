@@ -2,8 +2,8 @@ package status
 
 import (
 	"context"
+	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
-	"github.com/libp2p/go-libp2p-core/protocol"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/flags"
 	"github.com/protolambda/rumor/p2p/rpc/methods"
@@ -15,9 +15,9 @@ import (
 type PeerStatusServeCmd struct {
 	*base.Base
 	*PeerStatusState
-	Book        track.StatusBook
-	Timeout     time.Duration         `ask:"--timeout" help:"Apply timeout of n milliseconds to each stream (complete request <> response time). 0 to Disable timeout"`
-	Compression flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
+	Book      track.StatusBook
+	Timeout   time.Duration       `ask:"--timeout" help:"Apply timeout of n milliseconds to each stream (complete request <> response time). 0 to Disable timeout"`
+	Encodings flags.EncodingsFlag `ask:"--encodings" help:"Comma-separated encodings to serve, e.g. 'ssz,ssz_snappy'. 'all' for all registered encodings"`
 }
 
 func (c *PeerStatusServeCmd) Help() string {
@@ -26,7 +26,6 @@ func (c *PeerStatusServeCmd) Help() string {
 
 func (c *PeerStatusServeCmd) Default() {
 	c.Timeout = 10 * time.Second
-	c.Compression = flags.CompressionFlag{Compression: reqresp.SnappyCompression{}}
 }
 
 func (c *PeerStatusServeCmd) Run(ctx context.Context, args ...string) error {
@@ -42,7 +41,6 @@ func (c *PeerStatusServeCmd) Run(ctx context.Context, args ...string) error {
 		reqCtx, _ := context.WithTimeout(bgCtx, c.Timeout)
 		return reqCtx
 	}
-	comp := c.Compression.Compression
 	listenReq := func(ctx context.Context, peerId peer.ID, handler reqresp.ChunkedRequestHandler) {
 		f := map[string]interface{}{
 			"from": peerId.String(),
@@ -57,7 +55,8 @@ func (c *PeerStatusServeCmd) Run(ctx context.Context, args ...string) error {
 			f["data"] = reqStatus
 			c.Book.RegisterStatus(peerId, reqStatus)
 
-			if err := handler.WriteResponseChunk(reqresp.SuccessCode, &c.PeerStatusState.Local); err != nil {
+			local := c.PeerStatusState.Local()
+			if err := handler.WriteResponseChunk(reqresp.SuccessCode, &local); err != nil {
 				c.Log.WithFields(f).Warnf("failed to respond to status request: %v", err)
 			} else {
 				c.Log.WithFields(f).Info("handled status request")
@@ -65,17 +64,16 @@ func (c *PeerStatusServeCmd) Run(ctx context.Context, args ...string) error {
 		}
 	}
 	m := methods.StatusRPCv1
-	streamHandler := m.MakeStreamHandler(sCtxFn, comp, listenReq)
-	prot := m.Protocol
-	if comp != nil {
-		prot += protocol.ID("_" + comp.Name())
-	}
-	h.SetStreamHandler(prot, streamHandler)
-	c.Log.WithField("started", true).Info("Started serving status")
+	protocols := m.SetStreamHandlers(h.SetStreamHandler, c.Encodings.Selected(), func(enc *reqresp.Encoding) network.StreamHandler {
+		return m.MakeStreamHandler(sCtxFn, enc, listenReq)
+	})
+	c.Log.WithField("started", true).WithField("protocols", protocols).Info("Started serving status")
 
 	c.Control.RegisterStop(func(ctx context.Context) error {
 		bgCancel()
-		h.RemoveStreamHandler(prot)
+		for _, prot := range protocols {
+			h.RemoveStreamHandler(prot)
+		}
 		c.Log.Infof("Stopped serving status")
 		return nil
 	})
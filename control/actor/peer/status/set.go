@@ -30,7 +30,7 @@ func (c *PeerStatusSetCmd) Help() string {
 func (c *PeerStatusSetCmd) Run(ctx context.Context, args ...string) error {
 	st := methods.Status{}
 	if c.Merge {
-		st = c.PeerStatusState.Local
+		st = c.PeerStatusState.Local()
 	}
 	if !c.Merge || c.ForkDigest != (beacon.ForkDigest{}) {
 		st.ForkDigest = c.ForkDigest
@@ -47,12 +47,12 @@ func (c *PeerStatusSetCmd) Run(ctx context.Context, args ...string) error {
 	if !c.Merge || c.FinalizedRoot != (beacon.Root{}) {
 		st.FinalizedRoot = c.FinalizedRoot
 	}
-	// mutate full status at once (TODO: do we need a lock?)
-	c.PeerStatusState.Local = st
+	// mutate full status at once
+	c.PeerStatusState.SetLocal(st)
 
 	c.Log.WithFields(logrus.Fields{
 		"following": c.PeerStatusState.Following,
-		"status":    c.PeerStatusState.Local.Data(),
+		"status":    st.Data(),
 	}).Info("Status settings")
 	return nil
 }
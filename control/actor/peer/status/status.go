@@ -8,11 +8,28 @@ import (
 	"github.com/protolambda/rumor/p2p/rpc/methods"
 	"github.com/protolambda/rumor/p2p/rpc/reqresp"
 	"github.com/protolambda/rumor/p2p/track"
+	"sync"
 )
 
 type PeerStatusState struct {
 	Following bool
-	Local     methods.Status
+
+	// the local status is read by background tasks, e.g. the peer manager, while it may be set
+	lock  sync.RWMutex
+	local methods.Status
+}
+
+// Local returns a copy of the local status
+func (s *PeerStatusState) Local() methods.Status {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.local
+}
+
+func (s *PeerStatusState) SetLocal(st methods.Status) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.local = st
 }
 
 type PeerStatusCmd struct {
@@ -49,11 +66,13 @@ func (c *PeerStatusState) Routes() []string {
 	return []string{"get", "set", "req", "poll", "serve", "follow"}
 }
 
-func (c *PeerStatusState) fetch(book track.StatusBook, sFn reqresp.NewStreamFn, ctx context.Context, peerID peer.ID, comp reqresp.Compression) (
+// Fetch requests the status of the peer, with our local status, and registers the response in the book.
+func (c *PeerStatusState) Fetch(book track.StatusBook, sFn reqresp.NewStreamFn, ctx context.Context, peerID peer.ID, enc *reqresp.Encoding) (
 	resCode reqresp.ResponseCode, errMsg string, data *methods.Status, err error) {
+	local := c.Local()
 
-	err = methods.StatusRPCv1.RunRequest(ctx, sFn, peerID, comp,
-		reqresp.RequestSSZInput{Obj: &c.Local}, 1,
+	err = methods.StatusRPCv1.RunRequest(ctx, sFn, peerID, enc,
+		reqresp.RequestSSZInput{Obj: &local}, 1,
 		func() error {
 			return nil
 		},
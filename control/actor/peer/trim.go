@@ -2,17 +2,26 @@ package peer
 
 import (
 	"context"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/filter"
 	"time"
 )
 
 type PeerTrimCmd struct {
 	*base.Base
+	Store   track.ExtendedPeerstore
 	Timeout time.Duration `ask:"[timeout]" help:"Timeout for trimming."`
+	Filter  []string      `ask:"--filter" help:"Instead of trimming with the connection manager, disconnect the connected peers that match all of these filter conditions, comma-separated. Connection manager protections do not apply."`
+	Sort    string        `ask:"--sort" help:"Peer data field to sort the filtered peers by, to disconnect the first peers"`
+	Desc    bool          `ask:"--desc" help:"Sort in descending order"`
+	Limit   int           `ask:"--limit" help:"Max number of filtered peers to disconnect, 0 for no limit"`
 }
 
 func (c *PeerTrimCmd) Help() string {
-	return "Trim peers, with timeout."
+	return "Trim peers, with timeout. Or disconnect peers that match a filter, see 'peer list' for the filter conditions."
 }
 
 func (c *PeerTrimCmd) Default() {
@@ -24,6 +33,21 @@ func (c *PeerTrimCmd) Run(ctx context.Context, args ...string) error {
 	if err != nil {
 		return err
 	}
+	if len(c.Filter) > 0 {
+		f, err := filter.Parse(c.Filter...)
+		if err != nil {
+			return err
+		}
+		q := filter.Query{Filter: f, SortBy: c.Sort, Desc: c.Desc, Limit: c.Limit}
+		selected := q.Select(c.Store, h.Network().Peers(), func(id peer.ID) bool {
+			return h.Network().Connectedness(id) == network.Connected
+		})
+		for _, p := range selected {
+			closePeer(c.Log, h, p.Data.PeerID)
+		}
+		c.Log.WithField("peers", filter.IDs(selected)).Infof("Disconnected %d filtered peers", len(selected))
+		return nil
+	}
 	trimCtx, _ := context.WithTimeout(ctx, c.Timeout)
 	c.Log.Info("trimming peers")
 	h.ConnManager().TrimOpenConns(trimCtx)
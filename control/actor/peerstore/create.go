@@ -7,6 +7,7 @@ import (
 	"fmt"
 	ds "github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/sync"
+	leveldb "github.com/ipfs/go-ds-leveldb"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-peerstore/pstoreds"
 	"github.com/protolambda/rumor/control/actor/base"
@@ -22,6 +23,7 @@ type CreateCmd struct {
 
 	ID     track.PeerstoreID `ask:"[id]" help:"ID of the peerstore, random otherwise"`
 	Switch bool              `ask:"--switch" help:"If the host should immediately switch to the newly created peerstore"`
+	Path   string            `ask:"--path" help:"Directory of an on-disk leveldb datastore to persist the peerstore in, opened if it already exists. In-memory if empty."`
 }
 
 func (c *CreateCmd) Default() {
@@ -29,34 +31,83 @@ func (c *CreateCmd) Default() {
 }
 
 func (c *CreateCmd) Help() string {
-	return "Create and activate a peerstore"
+	return "Create and activate a peerstore. With --path, the peerstore is persisted on disk, and can be reopened by later sessions."
 }
 
 func (c *CreateCmd) Run(ctx context.Context, args ...string) error {
-	// TODO: default map is not safe, and mutex wrapper is slow
-	st := sync.MutexWrap(ds.NewMapDatastore())
+	id, ep, err := c.create()
+	if err != nil {
+		return err
+	}
+	if c.Switch {
+		switchTo(c.Base, c.CurrentPeerstore, id, ep)
+	}
+	return nil
+}
+
+// create builds the peerstore and shares it in the global peerstores, without switching to it.
+func (c *CreateCmd) create() (track.PeerstoreID, track.ExtendedPeerstore, error) {
+	var st ds.Batching
+	if c.Path != "" {
+		ldb, err := leveldb.NewDatastore(c.Path, nil)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to open leveldb datastore at %s: %v", c.Path, err)
+		}
+		st = ldb
+	} else {
+		// TODO: default map is not safe, and mutex wrapper is slow
+		st = sync.MutexWrap(ds.NewMapDatastore())
+	}
 	ep, err := dstrack.NewExtendedPeerstore(c.GlobalContext, st, pstoreds.DefaultOpts())
 	if err != nil {
-		return fmt.Errorf("failed to build datastore-backed peerstore named: %v", err)
+		_ = st.Close()
+		return "", nil, fmt.Errorf("failed to build datastore-backed peerstore named: %v", err)
 	}
 	id := c.ID
 	if id == "" {
 		var dat [24]byte
 		if _, err := rand.Read(dat[:]); err != nil {
-			return fmt.Errorf("failed to get random peerstore ID: %v", err)
+			_ = ep.Close()
+			_ = st.Close()
+			return "", nil, fmt.Errorf("failed to get random peerstore ID: %v", err)
 		}
 		id = track.PeerstoreID(hex.EncodeToString(dat[:]))
 	}
+	if c.Path != "" {
+		// the on-disk datastore is released when the peerstore is closed, e.g. when rumor shuts down
+		ep = &persistentPeerstore{ExtendedPeerstore: ep, store: st}
+	}
 	if err := c.GlobalPeerstores.Create(id, ep); err != nil {
-		return fmt.Errorf("failed to share peerstore: %v", err)
+		// also closes the datastore if persistent, the in-memory datastore has nothing to release
+		_ = ep.Close()
+		return "", nil, fmt.Errorf("failed to share peerstore: %v", err)
+	}
+	if c.Path != "" {
+		c.Log.WithField("id", id).WithField("path", c.Path).Info("Opened persistent peerstore")
 	}
-	h, err := c.Host()
+	return id, ep, nil
+}
+
+// persistentPeerstore closes the on-disk datastore after closing the peerstore.
+type persistentPeerstore struct {
+	track.ExtendedPeerstore
+	store ds.Datastore
+}
+
+func (p *persistentPeerstore) Close() error {
+	psErr := p.ExtendedPeerstore.Close()
+	if err := p.store.Close(); err != nil {
+		return fmt.Errorf("failed to close peerstore datastore: %v", err)
+	}
+	return psErr
+}
+
+// switchTo makes the peerstore the current peerstore, retaining the host identity if there is a host.
+func switchTo(b *base.Base, current track.DynamicPeerstore, id track.PeerstoreID, ep track.ExtendedPeerstore) {
+	h, err := b.Host()
 	var retainId peer.ID
 	if err == nil {
 		retainId = h.ID()
 	}
-	if c.Switch {
-		c.CurrentPeerstore.Switch(retainId, id, ep)
-	}
-	return nil
+	current.Switch(retainId, id, ep)
 }
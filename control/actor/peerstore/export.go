@@ -0,0 +1,217 @@
+package peerstore
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/track"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const timeFormat = time.RFC3339Nano
+
+type ExportCmd struct {
+	*base.Base
+
+	GlobalPeerstores track.Peerstores
+	CurrentPeerstore track.DynamicPeerstore
+
+	Out    string            `ask:"<out>" help:"File to write the export to"`
+	Format string            `ask:"--format" help:"Format of the export: 'json' (one peer object per line, can be imported again) or 'csv' (flat columns, for analysis, cannot be imported)"`
+	ID     track.PeerstoreID `ask:"--id" help:"ID of the peerstore to export. The current peerstore if empty"`
+}
+
+func (c *ExportCmd) Default() {
+	c.Format = "json"
+}
+
+func (c *ExportCmd) Help() string {
+	return "Export the data of every peer in the peerstore: ENR, fork digest, attnets, status, metadata, user agent, protocols, latency and addresses. " +
+		"The local host itself is excluded."
+}
+
+func (c *ExportCmd) Run(ctx context.Context, args ...string) error {
+	var store track.ExtendedPeerstore
+	if c.ID == "" {
+		if !c.CurrentPeerstore.Initialized() {
+			return errors.New("no current peerstore, create one first")
+		}
+		store = c.CurrentPeerstore
+	} else {
+		ep, ok := c.GlobalPeerstores.Find(c.ID)
+		if !ok {
+			return fmt.Errorf("peerstore %s does not exist", c.ID)
+		}
+		store = ep
+	}
+	var write func(w io.Writer, peers []*track.PeerAllData) error
+	switch c.Format {
+	case "json":
+		write = writePeersJSON
+	case "csv":
+		write = writePeersCSV
+	default:
+		return fmt.Errorf("unrecognized export format: %s", c.Format)
+	}
+
+	var self peer.ID
+	if h, err := c.Host(); err == nil {
+		self = h.ID()
+	}
+	ids := store.Peers()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	peers := make([]*track.PeerAllData, 0, len(ids))
+	for _, id := range ids {
+		if id == self {
+			continue
+		}
+		peers = append(peers, store.GetAllData(id))
+	}
+
+	f, err := os.OpenFile(c.Out, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open export file: %v", err)
+	}
+	defer f.Close()
+	if err := write(f, peers); err != nil {
+		return fmt.Errorf("failed to write export: %v", err)
+	}
+	c.Log.WithField("out", c.Out).WithField("format", c.Format).Infof("Exported %d peers", len(peers))
+	return nil
+}
+
+func writePeersJSON(w io.Writer, peers []*track.PeerAllData) error {
+	enc := json.NewEncoder(w)
+	for _, p := range peers {
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var csvHeader = []string{
+	"peer_id", "node_id", "pubkey", "addrs", "protocols", "latency_ms", "user_agent", "protocol_version",
+	"enr_fork_digest", "enr_next_fork_version", "enr_next_fork_epoch", "enr_attnets",
+	"metadata_seq_number", "metadata_attnets", "claimed_seq",
+	"status_fork_digest", "status_finalized_root", "status_finalized_epoch", "status_head_root", "status_head_slot",
+	"goodbye_reason", "goodbye_received", "goodbye_time",
+	"first_seen", "last_connected", "last_disconnected", "total_connected_s", "connections", "direction", "disconnect_reason",
+	"crawl_time", "crawl_success", "crawl_errors",
+	"enr",
+}
+
+// writePeersCSV writes the peers with flat columns. Lists are separated with ';', unknown values are empty.
+func writePeersCSV(w io.Writer, peers []*track.PeerAllData) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, p := range peers {
+		row := make([]string, 0, len(csvHeader))
+		str := func(v interface{}) {
+			row = append(row, fmt.Sprintf("%v", v))
+		}
+		empty := func(n int) {
+			for i := 0; i < n; i++ {
+				row = append(row, "")
+			}
+		}
+		row = append(row, p.PeerID.String())
+		if p.Pubkey != "" {
+			str(p.NodeID)
+		} else {
+			empty(1)
+		}
+		row = append(row, p.Pubkey, strings.Join(p.Addrs, ";"), strings.Join(p.Protocols, ";"))
+		if p.Latency != 0 {
+			str(p.Latency.Milliseconds())
+		} else {
+			empty(1)
+		}
+		row = append(row, p.UserAgent, p.ProtocolVersion)
+		if p.ForkDigest != nil {
+			str(*p.ForkDigest)
+			str(*p.NextForkVersion)
+			str(uint64(*p.NextForkEpoch))
+		} else {
+			empty(3)
+		}
+		if p.Attnets != nil {
+			str(p.Attnets)
+		} else {
+			empty(1)
+		}
+		if p.MetaData != nil {
+			str(uint64(p.MetaData.SeqNumber))
+			str(p.MetaData.Attnets)
+		} else {
+			empty(2)
+		}
+		if p.ClaimedSeq != 0 {
+			str(uint64(p.ClaimedSeq))
+		} else {
+			empty(1)
+		}
+		if st := p.Status; st != nil {
+			str(st.ForkDigest)
+			str(st.FinalizedRoot)
+			str(uint64(st.FinalizedEpoch))
+			str(st.HeadRoot)
+			str(uint64(st.HeadSlot))
+		} else {
+			empty(5)
+		}
+		if gb := p.Goodbye; gb != nil {
+			row = append(row, gb.Reason.Name())
+			str(gb.Received)
+			str(gb.Time.UTC().Format(timeFormat))
+		} else {
+			empty(3)
+		}
+		if cr := p.Connection; cr != nil {
+			str(cr.FirstSeen.UTC().Format(timeFormat))
+			str(cr.LastConnected.UTC().Format(timeFormat))
+			if !cr.LastDisconnected.IsZero() {
+				str(cr.LastDisconnected.UTC().Format(timeFormat))
+			} else {
+				empty(1)
+			}
+			str(cr.TotalConnected.Seconds())
+			str(cr.Connections)
+			row = append(row, cr.Direction, cr.DisconnectReason)
+		} else {
+			empty(7)
+		}
+		if cr := p.Crawl; cr != nil {
+			str(cr.Time.UTC().Format(timeFormat))
+			str(cr.Success)
+			errs := make([]string, 0, len(cr.Errors))
+			for step, reason := range cr.Errors {
+				errs = append(errs, step+": "+reason)
+			}
+			sort.Strings(errs)
+			row = append(row, strings.Join(errs, ";"))
+		} else {
+			empty(3)
+		}
+		if p.ENR != nil {
+			str(p.ENR.String())
+		} else {
+			empty(1)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
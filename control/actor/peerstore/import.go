@@ -0,0 +1,144 @@
+package peerstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	ic "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/track"
+	"io"
+	"os"
+	"time"
+)
+
+type ImportCmd struct {
+	*base.Base
+
+	GlobalPeerstores track.Peerstores
+	CurrentPeerstore track.DynamicPeerstore
+
+	Input   string            `ask:"<input>" help:"JSON export file to import, as written by 'peerstore export --format=json'"`
+	ID      track.PeerstoreID `ask:"[id]" help:"ID of the new peerstore, random otherwise"`
+	Switch  bool              `ask:"--switch" help:"If the host should immediately switch to the new peerstore"`
+	Path    string            `ask:"--path" help:"Directory of an on-disk leveldb datastore to persist the new peerstore in. In-memory if empty."`
+	AddrTTL time.Duration     `ask:"--addr-ttl" help:"How long the imported addresses are kept in the peerstore"`
+}
+
+func (c *ImportCmd) Default() {
+	c.AddrTTL = peerstore.PermanentAddrTTL
+}
+
+func (c *ImportCmd) Help() string {
+	return "Import a JSON peerstore export into a new peerstore: keys, addresses, protocols, user agent, latency, ENR, status and metadata. " +
+		"RPC stats, goodbyes and connection history are only exported, not imported."
+}
+
+func (c *ImportCmd) Run(ctx context.Context, args ...string) error {
+	f, err := os.Open(c.Input)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %v", err)
+	}
+	defer f.Close()
+
+	create := CreateCmd{Base: c.Base, GlobalPeerstores: c.GlobalPeerstores, CurrentPeerstore: c.CurrentPeerstore,
+		ID: c.ID, Path: c.Path}
+	id, ep, err := create.create()
+	if err != nil {
+		return err
+	}
+	count, err := importPeers(bufio.NewReader(f), ep, c.AddrTTL)
+	if err != nil {
+		// don't leave a partially imported peerstore behind
+		c.GlobalPeerstores.Remove(id)
+		if closeErr := ep.Close(); closeErr != nil {
+			c.Log.WithError(closeErr).Warn("failed to close peerstore of failed import")
+		}
+		return err
+	}
+	c.Log.WithField("id", id).WithField("input", c.Input).Infof("Imported %d peers", count)
+	if c.Switch {
+		switchTo(c.Base, c.CurrentPeerstore, id, ep)
+	}
+	return nil
+}
+
+// importPeers imports the peers of a JSON export, one peer object per line. Returns the number of imported peers.
+func importPeers(r io.Reader, ep track.ExtendedPeerstore, addrTTL time.Duration) (count int, err error) {
+	dec := json.NewDecoder(r)
+	for {
+		var p track.PeerAllData
+		if err := dec.Decode(&p); err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, fmt.Errorf("failed to decode peer %d of export: %v", count, err)
+		}
+		if err := importPeer(ep, &p, addrTTL); err != nil {
+			return count, fmt.Errorf("failed to import peer %s: %v", p.PeerID, err)
+		}
+		count += 1
+	}
+}
+
+func importPeer(ep track.ExtendedPeerstore, p *track.PeerAllData, addrTTL time.Duration) error {
+	id := p.PeerID
+	if p.Pubkey != "" {
+		keyBytes, err := hex.DecodeString(p.Pubkey)
+		if err != nil {
+			return fmt.Errorf("invalid pubkey: %v", err)
+		}
+		pub, err := ic.UnmarshalSecp256k1PublicKey(keyBytes)
+		if err != nil {
+			return fmt.Errorf("invalid pubkey: %v", err)
+		}
+		if err := ep.AddPubKey(id, pub); err != nil {
+			return err
+		}
+	}
+	addrs := make([]ma.Multiaddr, 0, len(p.Addrs))
+	for _, a := range p.Addrs {
+		addr, err := ma.NewMultiaddr(a)
+		if err != nil {
+			return fmt.Errorf("invalid address %s: %v", a, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	ep.AddAddrs(id, addrs, addrTTL)
+	if len(p.Protocols) > 0 {
+		if err := ep.AddProtocols(id, p.Protocols...); err != nil {
+			return err
+		}
+	}
+	if p.UserAgent != "" {
+		if err := ep.Put(id, "AgentVersion", p.UserAgent); err != nil {
+			return err
+		}
+	}
+	if p.ProtocolVersion != "" {
+		if err := ep.Put(id, "ProtocolVersion", p.ProtocolVersion); err != nil {
+			return err
+		}
+	}
+	if p.Latency != 0 {
+		ep.RecordLatency(id, p.Latency)
+	}
+	if p.ENR != nil {
+		if _, err := ep.UpdateENRMaybe(id, p.ENR); err != nil {
+			return err
+		}
+	}
+	if p.Status != nil {
+		ep.RegisterStatus(id, *p.Status)
+	}
+	if p.MetaData != nil {
+		ep.RegisterMetadata(id, *p.MetaData)
+	}
+	if p.ClaimedSeq != 0 {
+		ep.RegisterSeqClaim(id, p.ClaimedSeq)
+	}
+	return nil
+}
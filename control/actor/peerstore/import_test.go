@@ -0,0 +1,96 @@
+package peerstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/sync"
+	ic "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	"github.com/libp2p/go-libp2p-peerstore/pstoreds"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/dstrack"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestPeerstore(t *testing.T, ctx context.Context) track.ExtendedPeerstore {
+	ep, err := dstrack.NewExtendedPeerstore(ctx, sync.MutexWrap(ds.NewMapDatastore()), pstoreds.DefaultOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ep
+}
+
+func TestExportImportJSON(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := newTestPeerstore(t, ctx)
+	defer src.Close()
+
+	_, pub, err := ic.GenerateSecp256k1Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AddPubKey(id, pub); err != nil {
+		t.Fatal(err)
+	}
+	src.AddAddrs(id, []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/9000")}, peerstore.PermanentAddrTTL)
+	if err := src.AddProtocols(id, "/eth2/beacon_chain/req/status/1/ssz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Put(id, "AgentVersion", "test/v1"); err != nil {
+		t.Fatal(err)
+	}
+	src.RecordLatency(id, 20*time.Millisecond)
+	src.RegisterStatus(id, methods.Status{HeadSlot: 123, FinalizedEpoch: 3})
+	src.RegisterMetadata(id, methods.MetaData{SeqNumber: 4})
+
+	var buf bytes.Buffer
+	if err := writePeersJSON(&buf, []*track.PeerAllData{src.GetAllData(id)}); err != nil {
+		t.Fatal(err)
+	}
+	dst := newTestPeerstore(t, ctx)
+	defer dst.Close()
+	count, err := importPeers(&buf, dst, peerstore.PermanentAddrTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 imported peer, got %d", count)
+	}
+
+	exp, got := src.GetAllData(id), dst.GetAllData(id)
+	if got.Pubkey != exp.Pubkey || got.NodeID != exp.NodeID {
+		t.Fatal("pubkey was not imported")
+	}
+	if !reflect.DeepEqual(got.Addrs, exp.Addrs) || !reflect.DeepEqual(got.Protocols, exp.Protocols) {
+		t.Fatalf("addrs or protocols were not imported: %v %v", got.Addrs, got.Protocols)
+	}
+	if got.UserAgent != exp.UserAgent || got.Latency != exp.Latency {
+		t.Fatalf("user agent or latency were not imported: %q %s", got.UserAgent, got.Latency)
+	}
+	if !reflect.DeepEqual(got.Status, exp.Status) || !reflect.DeepEqual(got.MetaData, exp.MetaData) {
+		t.Fatalf("status or metadata were not imported: %v %v", got.Status, got.MetaData)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dst := newTestPeerstore(t, ctx)
+	defer dst.Close()
+	if _, err := importPeers(strings.NewReader("peer_id,node_id\n"), dst, peerstore.PermanentAddrTTL); err == nil {
+		t.Fatal("expected CSV import to fail")
+	}
+}
@@ -0,0 +1,142 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+type RpcCustomCmd struct {
+	*base.Base
+	*RPCState
+}
+
+func (c *RpcCustomCmd) Help() string {
+	return "Manage custom RPC methods. Registered methods are available as 'rpc <name>', like the standard methods."
+}
+
+func (c *RpcCustomCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "register":
+		cmd = &RpcCustomRegisterCmd{Base: c.Base, RPCState: c.RPCState}
+	case "list":
+		cmd = &RpcCustomListCmd{Base: c.Base, RPCState: c.RPCState}
+	case "remove":
+		cmd = &RpcCustomRemoveCmd{Base: c.Base, RPCState: c.RPCState}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *RpcCustomCmd) Routes() []string {
+	return []string{"register", "list", "remove"}
+}
+
+type RpcCustomRegisterCmd struct {
+	*base.Base
+	*RPCState
+	Name      string      `ask:"<name>" help:"The name of the method, used as 'rpc <name>'"`
+	Protocol  protocol.ID `ask:"--protocol" help:"The protocol ID, without encoding suffix. E.g. '/eth2/beacon_chain/req/example/1'"`
+	ReqType   string      `ask:"--req-type" help:"SSZ type of the request. E.g. 'empty', 'uint64', 'bytes32', 'status', 'bytes:<limit>'"`
+	RespType  string      `ask:"--resp-type" help:"SSZ type of each response chunk. Same options as the request type"`
+	MaxChunks uint64      `ask:"--max-chunks" help:"Default max response chunk count. If 0, requests are not answered"`
+}
+
+func (c *RpcCustomRegisterCmd) Default() {
+	c.ReqType = "empty"
+	c.RespType = "empty"
+	c.MaxChunks = 1
+}
+
+func (c *RpcCustomRegisterCmd) Help() string {
+	return fmt.Sprintf("Register a custom RPC method. Known SSZ types: %s",
+		strings.Join(methods.SSZTypeNames(), ", "))
+}
+
+func (c *RpcCustomRegisterCmd) Run(ctx context.Context, args ...string) error {
+	for _, r := range builtinRoutes {
+		if r == c.Name {
+			return fmt.Errorf("cannot register custom method with standard name %s", c.Name)
+		}
+	}
+	if c.Protocol == "" {
+		return fmt.Errorf("custom method %s needs a protocol ID", c.Name)
+	}
+	reqCodec, err := methods.ParseSSZTypeCodec(c.ReqType)
+	if err != nil {
+		return fmt.Errorf("invalid request type: %v", err)
+	}
+	respCodec, err := methods.ParseSSZTypeCodec(c.RespType)
+	if err != nil {
+		return fmt.Errorf("invalid response type: %v", err)
+	}
+	m := &CustomMethod{
+		ReqType:  c.ReqType,
+		RespType: c.RespType,
+		Method: reqresp.RPCMethod{
+			Protocol:                  c.Protocol,
+			RequestCodec:              reqCodec,
+			ResponseChunkCodec:        respCodec,
+			DefaultResponseChunkCount: c.MaxChunks,
+		},
+	}
+	if !c.RPCState.AddCustom(c.Name, m) {
+		return fmt.Errorf("custom method %s already exists", c.Name)
+	}
+	c.Log.WithFields(logrus.Fields{
+		"name":       c.Name,
+		"protocol":   c.Protocol,
+		"req_type":   c.ReqType,
+		"resp_type":  c.RespType,
+		"max_chunks": c.MaxChunks,
+	}).Info("Registered custom RPC method")
+	return nil
+}
+
+type RpcCustomListCmd struct {
+	*base.Base
+	*RPCState
+}
+
+func (c *RpcCustomListCmd) Help() string {
+	return "List the registered custom RPC methods"
+}
+
+func (c *RpcCustomListCmd) Run(ctx context.Context, args ...string) error {
+	out := make(map[string]interface{})
+	for name, m := range c.RPCState.CustomMethods() {
+		out[name] = map[string]interface{}{
+			"protocol":   m.Method.Protocol,
+			"req_type":   m.ReqType,
+			"resp_type":  m.RespType,
+			"max_chunks": m.Method.DefaultResponseChunkCount,
+		}
+	}
+	c.Log.WithField("methods", out).Infof("%d custom RPC methods", len(out))
+	return nil
+}
+
+type RpcCustomRemoveCmd struct {
+	*base.Base
+	*RPCState
+	Name string `ask:"<name>" help:"The name of the custom method to remove"`
+}
+
+func (c *RpcCustomRemoveCmd) Help() string {
+	return "Remove a custom RPC method. Running listeners of the method are not stopped."
+}
+
+func (c *RpcCustomRemoveCmd) Run(ctx context.Context, args ...string) error {
+	if c.RPCState.RemoveCustom(c.Name) == nil {
+		return fmt.Errorf("custom method %s does not exist", c.Name)
+	}
+	c.Log.WithField("name", c.Name).Info("Removed custom RPC method")
+	return nil
+}
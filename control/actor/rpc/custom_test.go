@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testControl runs steps immediately, and collects the stop functions
+type testControl struct {
+	lock  sync.Mutex
+	stops []base.OnStop
+}
+
+func (c *testControl) RegisterStop(onStop base.OnStop) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stops = append(c.stops, onStop)
+}
+
+func (c *testControl) Step(step base.Step) error {
+	return step(context.Background())
+}
+
+func (c *testControl) stop(t *testing.T) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, s := range c.stops {
+		if err := s(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}
+	c.stops = nil
+}
+
+type testHost struct {
+	h host.Host
+}
+
+func (t testHost) Host() (host.Host, error) {
+	return t.h, nil
+}
+
+func TestCustomMethod(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	newHost := func() host.Host {
+		h, err := libp2p.New(ctx, libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	a, b := newHost(), newHost()
+	defer a.Close()
+	defer b.Close()
+	if err := b.Connect(ctx, peer.AddrInfo{ID: a.ID(), Addrs: a.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	control := new(testControl)
+	defer control.stop(t)
+	bas := &base.Base{WithHost: testHost{a}, Control: control, Log: log}
+	state := new(RPCState)
+	rpcCmd := &RpcCmd{Base: bas, RPCState: state}
+
+	// register
+	reg := &RpcCustomRegisterCmd{Base: bas, RPCState: state, Name: "example"}
+	reg.Default()
+	reg.Protocol = "/eth2/beacon_chain/req/example/1"
+	reg.ReqType = "uint64"
+	reg.RespType = "uint64"
+	if err := reg.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Run(ctx); err == nil {
+		t.Fatal("expected registering an existing method to fail")
+	}
+	if err := (&RpcCustomRegisterCmd{Base: bas, RPCState: state, Name: "status", Protocol: "/test/1"}).Run(ctx); err == nil {
+		t.Fatal("expected registering a standard method name to fail")
+	}
+	routes := rpcCmd.Routes()
+	if routes[len(routes)-1] != "example" {
+		t.Fatalf("expected custom method route, got %v", routes)
+	}
+
+	// serve
+	cmd, err := rpcCmd.Cmd("example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	methodCmd := cmd.(*RpcMethodCmd)
+	listen, err := methodCmd.Cmd("listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := listen.(*RpcMethodListenCmd).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	custom := state.GetCustom("example")
+	go func() {
+		// respond to the request once it is queued
+		for i := 0; i < 100; i++ {
+			if custom.Responder.GetRequest(0) != nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		resp := &RpcMethodRespChunkRawCmd{Base: bas, RpcMethodData: methodCmd.RpcMethodData,
+			Done: true, ResultCode: reqresp.SuccessCode, ReqId: "0", Data: encodeUint64(43)}
+		if err := resp.Run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	var got *uint64
+	reqCtx, reqCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer reqCancel()
+	err = custom.Method.RunRequest(reqCtx, b.NewStream, a.ID(), reqresp.SSZSnappyEncoding,
+		reqresp.RequestBytesInput(encodeUint64(42)), 1, func() error { return nil },
+		func(chunk reqresp.ChunkedResponseHandler) error {
+			got = custom.Method.ResponseChunkCodec.Alloc().(*uint64)
+			return chunk.ReadObj(got)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || *got != 43 {
+		t.Fatalf("expected response 43, got %v", got)
+	}
+
+	// remove
+	remove := &RpcCustomRemoveCmd{Base: bas, RPCState: state, Name: "example"}
+	if err := remove.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := remove.Run(ctx); err == nil {
+		t.Fatal("expected removing a removed method to fail")
+	}
+	if _, err := rpcCmd.Cmd("example"); err != ask.UnrecognizedErr {
+		t.Fatalf("expected removed method to be unrecognized, got %v", err)
+	}
+	if routes := rpcCmd.Routes(); len(routes) != len(builtinRoutes) {
+		t.Fatalf("expected only standard routes, got %v", routes)
+	}
+}
+
+func encodeUint64(v uint64) []byte {
+	var out [8]byte
+	binary.LittleEndian.PutUint64(out[:], v)
+	return out[:]
+}
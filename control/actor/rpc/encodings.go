@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+)
+
+type RpcEncodingsCmd struct {
+	*base.Base
+	Registry *reqresp.EncodingRegistry
+}
+
+func (c *RpcEncodingsCmd) Help() string {
+	return "Manage the RPC encodings. The registry is shared by all actors. Commands that serve RPC methods register all encodings by default, in order of preference."
+}
+
+func (c *RpcEncodingsCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "list":
+		cmd = &RpcEncodingsListCmd{Base: c.Base, Registry: c.Registry}
+	case "experimental":
+		cmd = &RpcEncodingsExperimentalCmd{Base: c.Base, Registry: c.Registry}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *RpcEncodingsCmd) Routes() []string {
+	return []string{"list", "experimental"}
+}
+
+type RpcEncodingsListCmd struct {
+	*base.Base
+	Registry *reqresp.EncodingRegistry
+}
+
+func (c *RpcEncodingsListCmd) Help() string {
+	return "List the registered encodings, in order of preference"
+}
+
+func (c *RpcEncodingsListCmd) Run(ctx context.Context, args ...string) error {
+	for i, enc := range c.Registry.All() {
+		compression := "none"
+		if enc.Compression != nil {
+			compression = enc.Compression.Name()
+		}
+		c.Log.WithField("preference", i).WithField("compression", compression).
+			WithField("experimental", enc.Experimental).Info(enc.Name)
+	}
+	return nil
+}
+
+type RpcEncodingsExperimentalCmd struct {
+	*base.Base
+	Registry *reqresp.EncodingRegistry
+
+	Name        string `ask:"[name]" help:"Name of the experimental encoding, used as protocol ID suffix, e.g. 'ssz_snappy_draft'. Empty to clear the experimental slot."`
+	Compression string `ask:"--compression" help:"Compression of the SSZ contents of the encoding: 'none' or 'snappy'"`
+}
+
+func (c *RpcEncodingsExperimentalCmd) Default() {
+	c.Compression = "snappy"
+}
+
+func (c *RpcEncodingsExperimentalCmd) Help() string {
+	return "Register an experimental encoding, replacing the previous one. It is the last preference when serving, " +
+		"and can be selected by name with the '--compression' and '--encodings' options of other commands. " +
+		"Handlers that are already serving are not updated."
+}
+
+func (c *RpcEncodingsExperimentalCmd) Run(ctx context.Context, args ...string) error {
+	if c.Name == "" {
+		if err := c.Registry.SetExperimental(nil); err != nil {
+			return err
+		}
+		c.Log.Info("cleared experimental encoding")
+		return nil
+	}
+	if c.Name == "none" || c.Name == "snappy" {
+		return errors.New("encoding name is reserved as alias")
+	}
+	comp, err := reqresp.CompressionByName(c.Compression)
+	if err != nil {
+		return err
+	}
+	if err := c.Registry.SetExperimental(&reqresp.Encoding{Name: c.Name, Compression: comp}); err != nil {
+		return err
+	}
+	c.Log.WithField("compression", c.Compression).Infof("registered experimental encoding %s", c.Name)
+	return nil
+}
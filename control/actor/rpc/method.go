@@ -48,11 +48,12 @@ func (c *RpcMethodCmd) Cmd(route string) (cmd interface{}, err error) {
 	case "listen":
 		cmd = &RpcMethodListenCmd{
 			Base: c.Base, RpcMethodData: c.RpcMethodData,
-			Timeout:     10 * time.Second,
-			Compression: flags.CompressionFlag{Compression: reqresp.SnappyCompression{}},
-			Raw:         false,
-			Drop:        c.Method.DefaultResponseChunkCount == 0,
-			Read:        true,
+			Timeout: 10 * time.Second,
+			Raw:     false,
+			Drop:    c.Method.DefaultResponseChunkCount == 0,
+			Read:    true,
+
+			RateLimitFlags: flags.DefaultRateLimit(),
 		}
 	case "resp":
 		cmd = &RpcMethodRespCmd{
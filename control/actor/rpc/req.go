@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
-	"github.com/libp2p/go-libp2p-core/protocol"
 	"github.com/protolambda/ask"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/flags"
@@ -29,7 +28,7 @@ func (c *RpcMethodReqCmd) Cmd(route string) (cmd interface{}, err error) {
 			Base:          c.Base,
 			RpcMethodData: c.RpcMethodData,
 			Timeout:       10 * time.Second,
-			Compression:   flags.CompressionFlag{Compression: reqresp.SnappyCompression{}},
+			Compression:   flags.CompressionFlag{Encoding: reqresp.SSZSnappyEncoding},
 			MaxChunks:     c.Method.DefaultResponseChunkCount,
 			Raw:           false,
 		}
@@ -48,7 +47,7 @@ type RpcMethodReqRawCmd struct {
 	*base.Base
 	*RpcMethodData
 	Timeout     time.Duration         `ask:"--timeout" help:"Timeout for full request and response. 0 to disable"`
-	Compression flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
+	Compression flags.CompressionFlag `ask:"--compression" help:"Encoding. 'ssz' (or 'none') for uncompressed, 'ssz_snappy' (or 'snappy') for streaming-snappy, or any other registered encoding"`
 	MaxChunks   uint64                `ask:"--max-chunks" help:"Max response chunk count, if 0, do not wait for a response at all."`
 	Raw         bool                  `ask:"--raw" help:"If chunks should be logged as raw hex-encoded byte strings"`
 	PeerID      flags.PeerIDFlag      `ask:"<peer-id>" help:"libp2p Peer-ID to request"`
@@ -71,13 +70,10 @@ func (c *RpcMethodReqRawCmd) Run(ctx context.Context, args ...string) error {
 		reqCtx, _ = context.WithTimeout(reqCtx, c.Timeout)
 	}
 
-	protocolId := c.Method.Protocol
-	if c.Compression.Compression != nil {
-		protocolId += protocol.ID("_" + c.Compression.Compression.Name())
-	}
+	protocolId := c.Method.ProtocolID(c.Compression.Encoding)
 
 	go func() {
-		reqErr := c.Method.RunRequest(reqCtx, sFn, c.PeerID.PeerID, c.Compression.Compression,
+		reqErr := c.Method.RunRequest(reqCtx, sFn, c.PeerID.PeerID, c.Compression.Encoding,
 			reqresp.RequestBytesInput(c.Data), c.MaxChunks,
 			func() error {
 				return c.Control.Step(func(ctx context.Context) error {
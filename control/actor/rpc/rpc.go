@@ -5,6 +5,7 @@ import (
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/p2p/rpc/methods"
 	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"sort"
 )
 
 type RpcCmd struct {
@@ -26,14 +27,32 @@ func (c *RpcCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = c.Method("blocks-by-range", &c.RPCState.BlocksByRange, &methods.BlocksByRangeRPCv1)
 	case "blocks-by-root":
 		cmd = c.Method("blocks-by-root", &c.RPCState.BlocksByRoot, &methods.BlocksByRootRPCv1)
+	case "custom":
+		cmd = &RpcCustomCmd{Base: c.Base, RPCState: c.RPCState}
+	case "stats":
+		cmd = &RpcStatsCmd{Base: c.Base, RPCState: c.RPCState}
+	case "encodings":
+		cmd = &RpcEncodingsCmd{Base: c.Base, Registry: reqresp.Encodings}
 	default:
-		return nil, ask.UnrecognizedErr
+		custom := c.RPCState.GetCustom(route)
+		if custom == nil {
+			return nil, ask.UnrecognizedErr
+		}
+		cmd = c.Method(route, &custom.Responder, &custom.Method)
 	}
 	return cmd, nil
 }
 
+var builtinRoutes = []string{"goodbye", "status", "ping", "metadata", "blocks-by-range", "blocks-by-root", "custom", "stats", "encodings"}
+
 func (c *RpcCmd) Routes() []string {
-	return []string{"goodbye", "status", "ping", "metadata", "blocks-by-range", "blocks-by-root"}
+	routes := append([]string(nil), builtinRoutes...)
+	var custom []string
+	for name := range c.RPCState.CustomMethods() {
+		custom = append(custom, name)
+	}
+	sort.Strings(custom)
+	return append(routes, custom...)
 }
 
 func (c *RpcCmd) Help() string {
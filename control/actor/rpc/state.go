@@ -3,6 +3,7 @@ package rpc
 import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"github.com/protolambda/rumor/p2p/rpc/stats"
 	"sync"
 )
 
@@ -13,6 +14,62 @@ type RPCState struct {
 	Metadata      Responder
 	BlocksByRange Responder
 	BlocksByRoot  Responder
+	// Methods registered at runtime
+	customLock sync.RWMutex
+	custom     map[string]*CustomMethod
+	// Stats of all requests made and served by the host
+	Stats stats.Registry
+}
+
+type CustomMethod struct {
+	ReqType  string
+	RespType string
+	Method   reqresp.RPCMethod
+	Responder
+}
+
+// AddCustom registers the custom method. It returns false if a method with the name already exists.
+func (s *RPCState) AddCustom(name string, m *CustomMethod) bool {
+	s.customLock.Lock()
+	defer s.customLock.Unlock()
+	if _, ok := s.custom[name]; ok {
+		return false
+	}
+	if s.custom == nil {
+		s.custom = make(map[string]*CustomMethod)
+	}
+	s.custom[name] = m
+	return true
+}
+
+// RemoveCustom removes the custom method, and returns it. It returns nil if the method does not exist.
+func (s *RPCState) RemoveCustom(name string) *CustomMethod {
+	s.customLock.Lock()
+	defer s.customLock.Unlock()
+	m, ok := s.custom[name]
+	if !ok {
+		return nil
+	}
+	delete(s.custom, name)
+	return m
+}
+
+// GetCustom returns the custom method, or nil if it does not exist.
+func (s *RPCState) GetCustom(name string) *CustomMethod {
+	s.customLock.RLock()
+	defer s.customLock.RUnlock()
+	return s.custom[name]
+}
+
+// CustomMethods returns a copy of the registered custom methods, by name.
+func (s *RPCState) CustomMethods() map[string]*CustomMethod {
+	s.customLock.RLock()
+	defer s.customLock.RUnlock()
+	out := make(map[string]*CustomMethod, len(s.custom))
+	for name, m := range s.custom {
+		out[name] = m
+	}
+	return out
 }
 
 type RequestKey uint64
@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"context"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/track"
+	"sort"
+)
+
+type RpcStatsCmd struct {
+	*base.Base
+	*RPCState
+	Peers   bool   `ask:"--peers" help:"Rank peers by average time to the first response chunk of requests made by us"`
+	Limit   uint64 `ask:"--limit" help:"Max number of peers to rank. 0 to rank all peers"`
+	Inbound bool   `ask:"--inbound" help:"Rank peers by the requests they made to us, instead of requests we made"`
+	Reset   bool   `ask:"--reset" help:"Reset the stats after showing them"`
+}
+
+func (c *RpcStatsCmd) Default() {
+	c.Limit = 20
+}
+
+func (c *RpcStatsCmd) Help() string {
+	return "Show latency and error stats of requests made and served, per protocol, or per peer."
+}
+
+func (c *RpcStatsCmd) Run(ctx context.Context, args ...string) error {
+	if c.Peers {
+		type entry struct {
+			id    string
+			stats track.RPCStats
+		}
+		var entries []entry
+		for id, ps := range c.RPCState.Stats.Peers() {
+			total := ps.Total(c.Inbound)
+			if total.Count == 0 {
+				continue
+			}
+			entries = append(entries, entry{id: id.String(), stats: total})
+		}
+		// fastest first, peers without any response last
+		sort.Slice(entries, func(i, j int) bool {
+			a, b := &entries[i].stats, &entries[j].stats
+			if (a.Responded == 0) != (b.Responded == 0) {
+				return b.Responded == 0
+			}
+			return a.AvgFirstChunk() < b.AvgFirstChunk()
+		})
+		if c.Limit != 0 && uint64(len(entries)) > c.Limit {
+			entries = entries[:c.Limit]
+		}
+		ranking := make([]map[string]interface{}, 0, len(entries))
+		for _, e := range entries {
+			d := e.stats.Data()
+			d["peer_id"] = e.id
+			ranking = append(ranking, d)
+		}
+		c.Log.WithField("peers", ranking).Infof("ranked %d peers", len(ranking))
+	} else {
+		stats := func(inbound bool) map[string]interface{} {
+			out := make(map[string]interface{})
+			for prot, s := range c.RPCState.Stats.Protocols(inbound) {
+				out[string(prot)] = s.Data()
+			}
+			return out
+		}
+		c.Log.WithField("outbound", stats(false)).WithField("inbound", stats(true)).Info("rpc stats")
+	}
+	if c.Reset {
+		c.RPCState.Stats.Reset()
+	}
+	return nil
+}
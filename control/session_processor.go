@@ -291,6 +291,17 @@ func (sp *SessionProcessor) Close() {
 		return true
 	})
 
+	log.Trace("Closing peerstores...")
+	// flush and close peerstores before exiting, persistent peerstores write their data to disk.
+	peerstores := sp.actorGlobals.GlobalPeerstores
+	for _, id := range peerstores.List() {
+		if ps, ok := peerstores.Find(id); ok && peerstores.Remove(id) {
+			if err := ps.Close(); err != nil {
+				log.WithError(err).WithField("peerstore", id).Error("Failed to close peerstore")
+			}
+		}
+	}
+
 	log.Trace("Closing global context...")
 	// closes cross-actor things such as peerstores and chains
 	sp.globalActorCancel()
@@ -12,6 +12,7 @@ require (
 	github.com/golang/snappy v0.0.2-0.20200707131729-196ae77b8a26
 	github.com/gorilla/websocket v1.4.2
 	github.com/ipfs/go-datastore v0.4.4
+	github.com/ipfs/go-ds-leveldb v0.4.1
 	github.com/libp2p/go-libp2p v0.8.1
 	github.com/libp2p/go-libp2p-connmgr v0.2.1
 	github.com/libp2p/go-libp2p-core v0.5.1
@@ -21,6 +22,7 @@ require (
 	github.com/libp2p/go-libp2p-pubsub v0.2.6
 	github.com/libp2p/go-libp2p-secio v0.2.2
 	github.com/libp2p/go-libp2p-tls v0.1.3
+	github.com/libp2p/go-libp2p-transport-upgrader v0.2.0
 	github.com/libp2p/go-libp2p-yamux v0.2.7
 	github.com/libp2p/go-tcp-transport v0.2.0
 	github.com/libp2p/go-ws-transport v0.3.0
@@ -0,0 +1,211 @@
+package gossip
+
+import (
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BlockArrival tracks the propagation of a single block message.
+type BlockArrival struct {
+	MsgID string `json:"msg_id"`
+	// Time of the first arrival of the message, before validation
+	FirstSeen time.Time `json:"first_seen"`
+	FirstFrom peer.ID   `json:"first_from"`
+	// Number of times the message arrived after the first time
+	Duplicates uint64 `json:"duplicates"`
+
+	// Known after the message is decoded
+	Decoded       bool                  `json:"decoded"`
+	Slot          beacon.Slot           `json:"slot"`
+	ProposerIndex beacon.ValidatorIndex `json:"proposer_index"`
+	Root          beacon.Root           `json:"root"`
+	// Time between the start of the slot of the block, and the first arrival
+	Delay time.Duration `json:"delay"`
+}
+
+// BlockTracker measures block propagation: the delay between the slot start and the first arrival of a block,
+// who delivered it first, and how many duplicates arrived.
+// Arrivals are traced from the pubsub RPCs, the block contents are added with OnBlock.
+type BlockTracker struct {
+	GenesisTime beacon.Timestamp
+	// Max number of blocks to keep, the oldest are pruned first
+	MaxBlocks int
+
+	lock     sync.Mutex
+	arrivals map[string]*BlockArrival
+	order    []string
+	// cache of topic names to check if they are block topics
+	topics map[string]bool
+}
+
+func NewBlockTracker(genesisTime beacon.Timestamp, maxBlocks int) *BlockTracker {
+	return &BlockTracker{
+		GenesisTime: genesisTime,
+		MaxBlocks:   maxBlocks,
+		arrivals:    make(map[string]*BlockArrival),
+		topics:      make(map[string]bool),
+	}
+}
+
+func (bt *BlockTracker) isBlockTopic(name string) bool {
+	if v, ok := bt.topics[name]; ok {
+		return v
+	}
+	topic, err := ParseTopic(name)
+	v := err == nil && topic.Type == BeaconBlockTopic
+	bt.topics[name] = v
+	return v
+}
+
+// OnTrace is a TraceListener, to track arrivals of block messages.
+func (bt *BlockTracker) OnTrace(evt *pubsub_pb.TraceEvent) {
+	if evt.GetType() != pubsub_pb.TraceEvent_RECV_RPC {
+		return
+	}
+	rpc := evt.GetRecvRPC()
+	msgs := rpc.GetMeta().GetMessages()
+	if len(msgs) == 0 {
+		return
+	}
+	t := time.Unix(0, evt.GetTimestamp())
+	from := peer.ID(rpc.GetReceivedFrom())
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+	for _, m := range msgs {
+		isBlock := false
+		for _, topic := range m.GetTopics() {
+			if bt.isBlockTopic(topic) {
+				isBlock = true
+				break
+			}
+		}
+		if !isBlock {
+			continue
+		}
+		bt.arrival(string(m.GetMessageID()), t, from)
+	}
+}
+
+func (bt *BlockTracker) arrival(msgID string, t time.Time, from peer.ID) {
+	if a, ok := bt.arrivals[msgID]; ok {
+		a.Duplicates += 1
+		return
+	}
+	bt.arrivals[msgID] = &BlockArrival{MsgID: msgID, FirstSeen: t, FirstFrom: from}
+	bt.order = append(bt.order, msgID)
+	if bt.MaxBlocks > 0 && len(bt.order) > bt.MaxBlocks {
+		delete(bt.arrivals, bt.order[0])
+		bt.order = bt.order[1:]
+	}
+}
+
+// OnBlock adds the block contents to the arrival of the message.
+func (bt *BlockTracker) OnBlock(msgID string, block *beacon.SignedBeaconBlock) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+	a, ok := bt.arrivals[msgID]
+	if !ok {
+		return
+	}
+	a.Decoded = true
+	a.Slot = block.Message.Slot
+	a.ProposerIndex = block.Message.ProposerIndex
+	a.Root = block.Message.HashTreeRoot()
+	slotStart := time.Unix(int64(bt.GenesisTime+beacon.Timestamp(a.Slot)*beacon.SECONDS_PER_SLOT), 0)
+	a.Delay = a.FirstSeen.Sub(slotStart)
+}
+
+// Arrivals returns a copy of the tracked arrivals, in order of first arrival.
+func (bt *BlockTracker) Arrivals() []BlockArrival {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+	out := make([]BlockArrival, 0, len(bt.order))
+	for _, id := range bt.order {
+		out = append(out, *bt.arrivals[id])
+	}
+	return out
+}
+
+func (bt *BlockTracker) Reset() {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+	bt.arrivals = make(map[string]*BlockArrival)
+	bt.order = nil
+}
+
+type PeerFirstCount struct {
+	Peer  peer.ID `json:"peer"`
+	Count uint64  `json:"count"`
+}
+
+type BlockStatsSummary struct {
+	// Number of tracked block messages
+	Blocks uint64 `json:"blocks"`
+	// Number of decoded blocks, the delay is measured for these
+	Decoded uint64 `json:"decoded"`
+	// Delay percentiles, keyed by percentile, e.g. "p50"
+	Delay map[string]time.Duration `json:"delay"`
+	// Duplicate percentiles, keyed by percentile, e.g. "p50"
+	Duplicates map[string]uint64 `json:"duplicates"`
+	// Peers that delivered blocks first, most first deliveries first
+	FirstPeers []PeerFirstCount `json:"first_peers"`
+}
+
+// Percentiles reported in summaries
+var SummaryPercentiles = []uint{50, 75, 90, 95, 99}
+
+func percentileIndex(n int, p uint) int {
+	return (n - 1) * int(p) / 100
+}
+
+// Summary computes the delay and duplicates percentiles, and the top peers delivering blocks first.
+func (bt *BlockTracker) Summary(topPeers int) *BlockStatsSummary {
+	arrivals := bt.Arrivals()
+	out := &BlockStatsSummary{
+		Blocks:     uint64(len(arrivals)),
+		Delay:      make(map[string]time.Duration),
+		Duplicates: make(map[string]uint64),
+	}
+	var delays []time.Duration
+	dups := make([]uint64, 0, len(arrivals))
+	firsts := make(map[peer.ID]uint64)
+	for i := range arrivals {
+		a := &arrivals[i]
+		if a.Decoded {
+			delays = append(delays, a.Delay)
+		}
+		dups = append(dups, a.Duplicates)
+		firsts[a.FirstFrom] += 1
+	}
+	out.Decoded = uint64(len(delays))
+	sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+	sort.Slice(dups, func(i, j int) bool { return dups[i] < dups[j] })
+	for _, p := range SummaryPercentiles {
+		key := fmt.Sprintf("p%d", p)
+		if len(delays) > 0 {
+			out.Delay[key] = delays[percentileIndex(len(delays), p)]
+		}
+		if len(dups) > 0 {
+			out.Duplicates[key] = dups[percentileIndex(len(dups), p)]
+		}
+	}
+	for id, count := range firsts {
+		out.FirstPeers = append(out.FirstPeers, PeerFirstCount{Peer: id, Count: count})
+	}
+	sort.Slice(out.FirstPeers, func(i, j int) bool {
+		a, b := out.FirstPeers[i], out.FirstPeers[j]
+		if a.Count == b.Count {
+			return a.Peer < b.Peer
+		}
+		return a.Count > b.Count
+	})
+	if topPeers >= 0 && len(out.FirstPeers) > topPeers {
+		out.FirstPeers = out.FirstPeers[:topPeers]
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package gossip
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"io"
+	"sync"
+	"time"
+)
+
+// CaptureRecord is a single received gossip message, as persisted in a capture.
+type CaptureRecord struct {
+	// Time the message was received at
+	Time  time.Time `json:"time"`
+	Topic string    `json:"topic"`
+	// Peer that propagated the message to us
+	ReceivedFrom peer.ID `json:"received_from"`
+	// Original author of the message, if known
+	From  peer.ID `json:"from,omitempty"`
+	MsgID string  `json:"msg_id"`
+	// Raw message data, as it was received (i.e. compressed on snappy topics)
+	Data []byte `json:"data"`
+}
+
+// NewCaptureRecord creates a record of the message, with its message ID formatted with FormatMsgID.
+func NewCaptureRecord(t time.Time, topic string, msgID string, msg *pubsub.Message) *CaptureRecord {
+	rec := &CaptureRecord{
+		Time:         t,
+		Topic:        topic,
+		ReceivedFrom: msg.ReceivedFrom,
+		MsgID:        FormatMsgID(msgID),
+		Data:         msg.Data,
+	}
+	if from, err := peer.IDFromBytes(msg.Message.From); err == nil {
+		rec.From = from
+	}
+	return rec
+}
+
+// CaptureWriter writes capture records as JSON lines. Safe for concurrent use.
+type CaptureWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewCaptureWriter(w io.Writer) *CaptureWriter {
+	return &CaptureWriter{enc: json.NewEncoder(w)}
+}
+
+func (cw *CaptureWriter) Write(rec *CaptureRecord) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.enc.Encode(rec)
+}
+
+// CaptureReader reads capture records, written by a CaptureWriter.
+type CaptureReader struct {
+	dec *json.Decoder
+}
+
+func NewCaptureReader(r io.Reader) *CaptureReader {
+	return &CaptureReader{dec: json.NewDecoder(bufio.NewReader(r))}
+}
+
+// Next returns the next record, or io.EOF if there are no more records.
+func (cr *CaptureReader) Next() (*CaptureRecord, error) {
+	var rec CaptureRecord
+	if err := cr.dec.Decode(&rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
@@ -0,0 +1,203 @@
+package gossip
+
+import (
+	"encoding/hex"
+	"github.com/golang/snappy"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/minio/sha256-simd"
+	"sync"
+)
+
+// DupStats are cumulative duplicate statistics, these are not affected by pruning old entries.
+type DupStats struct {
+	// Distinct message IDs that arrived
+	Messages uint64 `json:"messages"`
+	// Arrivals of messages, including the first arrival
+	Copies uint64 `json:"copies"`
+	// Message IDs that arrived from more than one peer
+	MultiPeer uint64 `json:"multi_peer"`
+	// Distinct message contents that were delivered
+	Contents uint64 `json:"contents"`
+	// Contents that were delivered under more than one message ID
+	ContentsMultiID uint64 `json:"contents_multi_id"`
+	// Message IDs of a content, in addition to its first message ID
+	ExtraIDs uint64 `json:"extra_ids"`
+}
+
+// AvgCopies is the average number of times a message arrived.
+func (ds *DupStats) AvgCopies() float64 {
+	if ds.Messages == 0 {
+		return 0
+	}
+	return float64(ds.Copies) / float64(ds.Messages)
+}
+
+type dupMsg struct {
+	copies uint64
+	peers  map[peer.ID]struct{}
+}
+
+// DupContent is a message content that was delivered under multiple message IDs.
+type DupContent struct {
+	// SHA256 of the uncompressed message data
+	Hash   string   `json:"hash"`
+	MsgIDs []string `json:"msg_ids"`
+}
+
+// DupTracker tracks how often the same message arrived from different peers,
+// and how often the same content arrived under different message IDs.
+// Arrivals are traced from the pubsub RPCs, the contents are added with OnDeliver.
+type DupTracker struct {
+	// Topics to track, all topics if empty
+	Topics map[string]struct{}
+	// Max number of message IDs and contents to keep, the oldest are pruned first
+	MaxEntries int
+
+	lock       sync.Mutex
+	stats      DupStats
+	byID       map[string]*dupMsg
+	idOrder    []string
+	byContent  map[string][]string
+	contentOrd []string
+}
+
+func NewDupTracker(topics []string, maxEntries int) *DupTracker {
+	dt := &DupTracker{
+		Topics:     make(map[string]struct{}, len(topics)),
+		MaxEntries: maxEntries,
+		byID:       make(map[string]*dupMsg),
+		byContent:  make(map[string][]string),
+	}
+	for _, t := range topics {
+		dt.Topics[t] = struct{}{}
+	}
+	return dt
+}
+
+func (dt *DupTracker) tracked(topics []string) bool {
+	if len(dt.Topics) == 0 {
+		return true
+	}
+	for _, t := range topics {
+		if _, ok := dt.Topics[t]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// OnTrace is a TraceListener, to track the arrivals of messages.
+func (dt *DupTracker) OnTrace(evt *pubsub_pb.TraceEvent) {
+	if evt.GetType() != pubsub_pb.TraceEvent_RECV_RPC {
+		return
+	}
+	rpc := evt.GetRecvRPC()
+	msgs := rpc.GetMeta().GetMessages()
+	if len(msgs) == 0 {
+		return
+	}
+	from := peer.ID(rpc.GetReceivedFrom())
+	dt.lock.Lock()
+	defer dt.lock.Unlock()
+	for _, m := range msgs {
+		if !dt.tracked(m.GetTopics()) {
+			continue
+		}
+		id := string(m.GetMessageID())
+		dm, ok := dt.byID[id]
+		if !ok {
+			dm = &dupMsg{peers: make(map[peer.ID]struct{})}
+			dt.byID[id] = dm
+			dt.idOrder = append(dt.idOrder, id)
+			if dt.MaxEntries > 0 && len(dt.idOrder) > dt.MaxEntries {
+				delete(dt.byID, dt.idOrder[0])
+				dt.idOrder = dt.idOrder[1:]
+			}
+			dt.stats.Messages += 1
+		}
+		dm.copies += 1
+		dt.stats.Copies += 1
+		if _, ok := dm.peers[from]; !ok {
+			dm.peers[from] = struct{}{}
+			if len(dm.peers) == 2 {
+				dt.stats.MultiPeer += 1
+			}
+		}
+	}
+}
+
+// OnDeliver adds the content of a delivered message. The content is the snappy-decompressed data,
+// or the raw data if it is not valid snappy.
+func (dt *DupTracker) OnDeliver(msgID string, data []byte) {
+	if dec, err := snappy.Decode(nil, data); err == nil {
+		data = dec
+	}
+	h := sha256.Sum256(data)
+	key := string(h[:])
+	dt.lock.Lock()
+	defer dt.lock.Unlock()
+	ids, ok := dt.byContent[key]
+	if !ok {
+		dt.byContent[key] = []string{msgID}
+		dt.contentOrd = append(dt.contentOrd, key)
+		if dt.MaxEntries > 0 && len(dt.contentOrd) > dt.MaxEntries {
+			delete(dt.byContent, dt.contentOrd[0])
+			dt.contentOrd = dt.contentOrd[1:]
+		}
+		dt.stats.Contents += 1
+		return
+	}
+	for _, id := range ids {
+		if id == msgID {
+			return
+		}
+	}
+	dt.byContent[key] = append(ids, msgID)
+	dt.stats.ExtraIDs += 1
+	if len(ids) == 1 {
+		dt.stats.ContentsMultiID += 1
+	}
+}
+
+func (dt *DupTracker) Stats() DupStats {
+	dt.lock.Lock()
+	defer dt.lock.Unlock()
+	return dt.stats
+}
+
+// MultiIDContents returns up to n of the most recent contents that arrived under different message IDs.
+func (dt *DupTracker) MultiIDContents(n int) (out []DupContent) {
+	dt.lock.Lock()
+	defer dt.lock.Unlock()
+	for i := len(dt.contentOrd) - 1; i >= 0 && len(out) < n; i-- {
+		key := dt.contentOrd[i]
+		ids := dt.byContent[key]
+		if len(ids) < 2 {
+			continue
+		}
+		formatted := make([]string, len(ids))
+		for j, id := range ids {
+			formatted[j] = FormatMsgID(id)
+		}
+		out = append(out, DupContent{Hash: hex.EncodeToString([]byte(key)), MsgIDs: formatted})
+	}
+	return out
+}
+
+// Restore sets the cumulative stats, to continue the stats of a previous run.
+func (dt *DupTracker) Restore(stats DupStats) {
+	dt.lock.Lock()
+	defer dt.lock.Unlock()
+	dt.stats = stats
+}
+
+func (dt *DupTracker) Reset() {
+	dt.lock.Lock()
+	defer dt.lock.Unlock()
+	dt.stats = DupStats{}
+	dt.byID = make(map[string]*dupMsg)
+	dt.idOrder = nil
+	dt.byContent = make(map[string][]string)
+	dt.contentOrd = nil
+}
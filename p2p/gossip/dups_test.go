@@ -0,0 +1,67 @@
+package gossip
+
+import (
+	"github.com/golang/snappy"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"testing"
+)
+
+func recvMsgs(from peer.ID, topic string, ids ...string) *pubsub_pb.TraceEvent {
+	msgs := make([]*pubsub_pb.TraceEvent_MessageMeta, len(ids))
+	for i, id := range ids {
+		msgs[i] = &pubsub_pb.TraceEvent_MessageMeta{MessageID: []byte(id), Topics: []string{topic}}
+	}
+	return &pubsub_pb.TraceEvent{Type: pubsub_pb.TraceEvent_RECV_RPC.Enum(),
+		RecvRPC: &pubsub_pb.TraceEvent_RecvRPC{ReceivedFrom: []byte(from),
+			Meta: &pubsub_pb.TraceEvent_RPCMeta{Messages: msgs}}}
+}
+
+func TestDupTracker(t *testing.T) {
+	dt := NewDupTracker([]string{"a"}, 0)
+	dt.OnTrace(recvMsgs("peer-1", "a", "id-1", "id-2"))
+	dt.OnTrace(recvMsgs("peer-2", "a", "id-1"))
+	dt.OnTrace(recvMsgs("peer-2", "a", "id-1"))
+	// untracked topic
+	dt.OnTrace(recvMsgs("peer-1", "b", "id-3"))
+
+	dt.OnDeliver("id-1", snappy.Encode(nil, []byte("content")))
+	dt.OnDeliver("id-2", []byte("content"))
+	// same ID again is not an extra ID
+	dt.OnDeliver("id-2", []byte("content"))
+
+	st := dt.Stats()
+	expected := DupStats{Messages: 2, Copies: 4, MultiPeer: 1, Contents: 1, ContentsMultiID: 1, ExtraIDs: 1}
+	if st != expected {
+		t.Fatalf("unexpected stats: %+v, expected %+v", st, expected)
+	}
+	if st.AvgCopies() != 2 {
+		t.Fatalf("unexpected average copies: %f", st.AvgCopies())
+	}
+	contents := dt.MultiIDContents(10)
+	if len(contents) != 1 || len(contents[0].MsgIDs) != 2 ||
+		contents[0].MsgIDs[0] != "id-1" || contents[0].MsgIDs[1] != "id-2" {
+		t.Fatalf("unexpected multi-ID contents: %+v", contents)
+	}
+
+	dt.Reset()
+	if st := dt.Stats(); st != (DupStats{}) {
+		t.Fatalf("expected zero stats after reset: %+v", st)
+	}
+}
+
+func TestDupTrackerMaxEntries(t *testing.T) {
+	dt := NewDupTracker(nil, 2)
+	dt.OnTrace(recvMsgs("peer-1", "a", "id-1", "id-2", "id-3"))
+	// id-1 was pruned, and is counted as a new message
+	dt.OnTrace(recvMsgs("peer-2", "b", "id-1"))
+	st := dt.Stats()
+	if st.Messages != 4 || st.MultiPeer != 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	dt.lock.Lock()
+	defer dt.lock.Unlock()
+	if len(dt.byID) != 2 || len(dt.idOrder) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(dt.byID))
+	}
+}
@@ -0,0 +1,248 @@
+package gossip
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"sort"
+	"sync"
+)
+
+// ControlCounters counts gossipsub control messages. IHAVE and IWANT also count the message IDs they carry.
+type ControlCounters struct {
+	IHave    uint64 `json:"ihave"`
+	IHaveIDs uint64 `json:"ihave_ids"`
+	IWant    uint64 `json:"iwant"`
+	IWantIDs uint64 `json:"iwant_ids"`
+	Graft    uint64 `json:"graft"`
+	Prune    uint64 `json:"prune"`
+}
+
+// TopicCounters counts the messages and control messages of a single topic.
+// IWANT does not carry a topic, and is only counted globally and per peer.
+type TopicCounters struct {
+	// Received messages, including duplicates
+	Messages uint64          `json:"messages"`
+	Sent     ControlCounters `json:"sent"`
+	Received ControlCounters `json:"received"`
+}
+
+// PeerCounters counts what a single peer sent to us.
+// Outbound RPCs are not attributed to peers, the pubsub version of rumor does not trace the recipient correctly.
+type PeerCounters struct {
+	// Received messages, including duplicates
+	Messages   uint64          `json:"messages"`
+	Duplicates uint64          `json:"duplicates"`
+	Received   ControlCounters `json:"received"`
+}
+
+// DuplicateRatio is the fraction of received messages that were duplicates.
+func (pc *PeerCounters) DuplicateRatio() float64 {
+	if pc.Messages == 0 {
+		return 0
+	}
+	return float64(pc.Duplicates) / float64(pc.Messages)
+}
+
+type MeshCounters struct {
+	Messages   uint64          `json:"messages"`
+	Duplicates uint64          `json:"duplicates"`
+	Delivered  uint64          `json:"delivered"`
+	Rejected   uint64          `json:"rejected"`
+	Sent       ControlCounters `json:"sent"`
+	Received   ControlCounters `json:"received"`
+}
+
+func (mc *MeshCounters) DuplicateRatio() float64 {
+	if mc.Messages == 0 {
+		return 0
+	}
+	return float64(mc.Duplicates) / float64(mc.Messages)
+}
+
+// MeshTracker reconstructs the gossipsub mesh from GRAFT/PRUNE trace events, and counts control messages
+// and duplicates. Fanout peers are not tracked: the pubsub version of rumor does not trace fanout changes,
+// nor the recipients of sent messages. Peer counters are dropped when the peer is removed from the router.
+type MeshTracker struct {
+	lock sync.Mutex
+	// topic -> mesh peers
+	mesh   map[string]map[peer.ID]struct{}
+	total  MeshCounters
+	topics map[string]*TopicCounters
+	peers  map[peer.ID]*PeerCounters
+}
+
+func NewMeshTracker() *MeshTracker {
+	return &MeshTracker{
+		mesh:   make(map[string]map[peer.ID]struct{}),
+		topics: make(map[string]*TopicCounters),
+		peers:  make(map[peer.ID]*PeerCounters),
+	}
+}
+
+func (mt *MeshTracker) topic(name string) *TopicCounters {
+	tc, ok := mt.topics[name]
+	if !ok {
+		tc = new(TopicCounters)
+		mt.topics[name] = tc
+	}
+	return tc
+}
+
+func (mt *MeshTracker) peer(id peer.ID) *PeerCounters {
+	pc, ok := mt.peers[id]
+	if !ok {
+		pc = new(PeerCounters)
+		mt.peers[id] = pc
+	}
+	return pc
+}
+
+// countControl counts the control messages in both the total, per topic, and optionally the peer counters.
+func (mt *MeshTracker) countControl(ctl *pubsub_pb.TraceEvent_ControlMeta, total *ControlCounters,
+	perTopic func(tc *TopicCounters) *ControlCounters, perPeer *ControlCounters) {
+	if ctl == nil {
+		return
+	}
+	add := func(f func(c *ControlCounters), topic string, withTopic bool) {
+		f(total)
+		if withTopic {
+			f(perTopic(mt.topic(topic)))
+		}
+		if perPeer != nil {
+			f(perPeer)
+		}
+	}
+	for _, ih := range ctl.GetIhave() {
+		ids := uint64(len(ih.GetMessageIDs()))
+		add(func(c *ControlCounters) { c.IHave += 1; c.IHaveIDs += ids }, ih.GetTopic(), true)
+	}
+	for _, iw := range ctl.GetIwant() {
+		ids := uint64(len(iw.GetMessageIDs()))
+		add(func(c *ControlCounters) { c.IWant += 1; c.IWantIDs += ids }, "", false)
+	}
+	for _, g := range ctl.GetGraft() {
+		add(func(c *ControlCounters) { c.Graft += 1 }, g.GetTopic(), true)
+	}
+	for _, p := range ctl.GetPrune() {
+		add(func(c *ControlCounters) { c.Prune += 1 }, p.GetTopic(), true)
+	}
+}
+
+// OnTrace is a TraceListener, to track the mesh and count messages.
+func (mt *MeshTracker) OnTrace(evt *pubsub_pb.TraceEvent) {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+	switch evt.GetType() {
+	case pubsub_pb.TraceEvent_GRAFT:
+		g := evt.GetGraft()
+		m, ok := mt.mesh[g.GetTopic()]
+		if !ok {
+			m = make(map[peer.ID]struct{})
+			mt.mesh[g.GetTopic()] = m
+		}
+		m[peer.ID(g.GetPeerID())] = struct{}{}
+	case pubsub_pb.TraceEvent_PRUNE:
+		p := evt.GetPrune()
+		if m, ok := mt.mesh[p.GetTopic()]; ok {
+			delete(m, peer.ID(p.GetPeerID()))
+		}
+	case pubsub_pb.TraceEvent_REMOVE_PEER:
+		id := peer.ID(evt.GetRemovePeer().GetPeerID())
+		for _, m := range mt.mesh {
+			delete(m, id)
+		}
+		delete(mt.peers, id)
+	case pubsub_pb.TraceEvent_LEAVE:
+		delete(mt.mesh, evt.GetLeave().GetTopic())
+	case pubsub_pb.TraceEvent_DUPLICATE_MESSAGE:
+		mt.total.Duplicates += 1
+		mt.peer(peer.ID(evt.GetDuplicateMessage().GetReceivedFrom())).Duplicates += 1
+	case pubsub_pb.TraceEvent_DELIVER_MESSAGE:
+		mt.total.Delivered += 1
+	case pubsub_pb.TraceEvent_REJECT_MESSAGE:
+		mt.total.Rejected += 1
+	case pubsub_pb.TraceEvent_RECV_RPC:
+		rpc := evt.GetRecvRPC()
+		pc := mt.peer(peer.ID(rpc.GetReceivedFrom()))
+		for _, m := range rpc.GetMeta().GetMessages() {
+			mt.total.Messages += 1
+			pc.Messages += 1
+			for _, t := range m.GetTopics() {
+				mt.topic(t).Messages += 1
+			}
+		}
+		mt.countControl(rpc.GetMeta().GetControl(), &mt.total.Received,
+			func(tc *TopicCounters) *ControlCounters { return &tc.Received }, &pc.Received)
+	case pubsub_pb.TraceEvent_SEND_RPC:
+		mt.countControl(evt.GetSendRPC().GetMeta().GetControl(), &mt.total.Sent,
+			func(tc *TopicCounters) *ControlCounters { return &tc.Sent }, nil)
+	}
+}
+
+// Mesh returns the mesh peers of the topic, sorted.
+func (mt *MeshTracker) Mesh(topic string) []peer.ID {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+	m := mt.mesh[topic]
+	out := make([]peer.ID, 0, len(m))
+	for id := range m {
+		out = append(out, id)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func (mt *MeshTracker) Total() MeshCounters {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+	return mt.total
+}
+
+// Topic returns the counters of the topic, zeroed if the topic is unknown.
+func (mt *MeshTracker) Topic(topic string) TopicCounters {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+	if tc, ok := mt.topics[topic]; ok {
+		return *tc
+	}
+	return TopicCounters{}
+}
+
+func (mt *MeshTracker) Topics() map[string]TopicCounters {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+	out := make(map[string]TopicCounters, len(mt.topics))
+	for k, v := range mt.topics {
+		out[k] = *v
+	}
+	return out
+}
+
+// Peer returns the counters of the peer, zeroed if the peer is unknown.
+func (mt *MeshTracker) Peer(id peer.ID) PeerCounters {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+	if pc, ok := mt.peers[id]; ok {
+		return *pc
+	}
+	return PeerCounters{}
+}
+
+func (mt *MeshTracker) Peers() map[peer.ID]PeerCounters {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+	out := make(map[peer.ID]PeerCounters, len(mt.peers))
+	for k, v := range mt.peers {
+		out[k] = *v
+	}
+	return out
+}
+
+// ResetCounters resets all counters, the mesh is kept.
+func (mt *MeshTracker) ResetCounters() {
+	mt.lock.Lock()
+	defer mt.lock.Unlock()
+	mt.total = MeshCounters{}
+	mt.topics = make(map[string]*TopicCounters)
+	mt.peers = make(map[peer.ID]*PeerCounters)
+}
@@ -0,0 +1,50 @@
+package gossip
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"testing"
+)
+
+func TestMeshTracker(t *testing.T) {
+	mt := NewMeshTracker()
+	topic := "/eth2/e7a75d5a/beacon_block/ssz_snappy"
+	a, b := peer.ID("peer-a"), peer.ID("peer-b")
+	graft := func(id peer.ID) *pubsub_pb.TraceEvent {
+		return &pubsub_pb.TraceEvent{Type: pubsub_pb.TraceEvent_GRAFT.Enum(),
+			Graft: &pubsub_pb.TraceEvent_Graft{PeerID: []byte(id), Topic: &topic}}
+	}
+	recv := func(id peer.ID) *pubsub_pb.TraceEvent {
+		return &pubsub_pb.TraceEvent{Type: pubsub_pb.TraceEvent_RECV_RPC.Enum(),
+			RecvRPC: &pubsub_pb.TraceEvent_RecvRPC{ReceivedFrom: []byte(id), Meta: &pubsub_pb.TraceEvent_RPCMeta{
+				Messages: []*pubsub_pb.TraceEvent_MessageMeta{{Topics: []string{topic}}}}}}
+	}
+	mt.OnTrace(graft(a))
+	mt.OnTrace(graft(b))
+	mt.OnTrace(recv(a))
+	mt.OnTrace(recv(b))
+	mt.OnTrace(&pubsub_pb.TraceEvent{Type: pubsub_pb.TraceEvent_PRUNE.Enum(),
+		Prune: &pubsub_pb.TraceEvent_Prune{PeerID: []byte(b), Topic: &topic}})
+	if mesh := mt.Mesh(topic); len(mesh) != 1 || mesh[0] != a {
+		t.Fatalf("unexpected mesh: %v", mesh)
+	}
+	if pc := mt.Peer(b); pc.Messages != 1 {
+		t.Fatalf("expected 1 message from pruned peer, got %d", pc.Messages)
+	}
+
+	mt.OnTrace(&pubsub_pb.TraceEvent{Type: pubsub_pb.TraceEvent_REMOVE_PEER.Enum(),
+		RemovePeer: &pubsub_pb.TraceEvent_RemovePeer{PeerID: []byte(a)}})
+	if mesh := mt.Mesh(topic); len(mesh) != 0 {
+		t.Fatalf("expected removed peer to leave the mesh: %v", mesh)
+	}
+	peers := mt.Peers()
+	if _, ok := peers[a]; ok {
+		t.Fatal("expected counters of removed peer to be dropped")
+	}
+	if _, ok := peers[b]; !ok {
+		t.Fatal("expected counters of connected peer to be kept")
+	}
+	if total := mt.Total(); total.Messages != 2 {
+		t.Fatalf("expected total counters to be kept, got %d messages", total.Messages)
+	}
+}
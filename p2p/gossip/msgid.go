@@ -0,0 +1,106 @@
+package gossip
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/golang/snappy"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/minio/sha256-simd"
+)
+
+// MsgIDMode selects the function to compute gossip message IDs with.
+type MsgIDMode string
+
+const (
+	// URL-safe base64 of the SHA256 of the raw message data. The original eth2 message ID.
+	MsgIDData MsgIDMode = "data"
+	// URL-safe base64 of the SHA256 of the snappy-decompressed message data,
+	// or of the raw data if it is not valid snappy.
+	MsgIDUncompressed MsgIDMode = "uncompressed"
+	// The first 20 bytes of the SHA256 of a domain and the message data:
+	// the valid-snappy domain and the decompressed data, or the invalid-snappy domain and the raw data.
+	MsgIDDomain MsgIDMode = "domain"
+)
+
+var (
+	MessageDomainInvalidSnappy = [4]byte{0, 0, 0, 0}
+	MessageDomainValidSnappy   = [4]byte{1, 0, 0, 0}
+)
+
+func (m MsgIDMode) Valid() bool {
+	switch m {
+	case MsgIDData, MsgIDUncompressed, MsgIDDomain:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m MsgIDMode) String() string {
+	return string(m)
+}
+
+func (m *MsgIDMode) Set(v string) error {
+	mode := MsgIDMode(v)
+	if !mode.Valid() {
+		return fmt.Errorf("unknown message ID mode: %s, expected 'data', 'uncompressed' or 'domain'", v)
+	}
+	*m = mode
+	return nil
+}
+
+func (m *MsgIDMode) Type() string {
+	return "msg-id-mode"
+}
+
+// Fn returns the message ID function of the mode. Unknown modes default to MsgIDFunction.
+func (m MsgIDMode) Fn() func(pmsg *pubsub_pb.Message) string {
+	switch m {
+	case MsgIDUncompressed:
+		return UncompressedMsgIDFunction
+	case MsgIDDomain:
+		return DomainMsgIDFunction
+	default:
+		return MsgIDFunction
+	}
+}
+
+func MsgIDFunction(pmsg *pubsub_pb.Message) string {
+	h := sha256.New()
+	// never errors, see crypto/sha256 Go doc
+	_, _ = h.Write(pmsg.Data)
+	id := h.Sum(nil)
+	return base64.URLEncoding.EncodeToString(id)
+}
+
+func UncompressedMsgIDFunction(pmsg *pubsub_pb.Message) string {
+	data := pmsg.Data
+	if dec, err := snappy.Decode(nil, data); err == nil {
+		data = dec
+	}
+	id := sha256.Sum256(data)
+	return base64.URLEncoding.EncodeToString(id[:])
+}
+
+func DomainMsgIDFunction(pmsg *pubsub_pb.Message) string {
+	h := sha256.New()
+	if dec, err := snappy.Decode(nil, pmsg.Data); err == nil {
+		_, _ = h.Write(MessageDomainValidSnappy[:])
+		_, _ = h.Write(dec)
+	} else {
+		_, _ = h.Write(MessageDomainInvalidSnappy[:])
+		_, _ = h.Write(pmsg.Data)
+	}
+	return string(h.Sum(nil)[:20])
+}
+
+// FormatMsgID formats a message ID for logs and captures: base64 IDs are kept as-is, binary IDs are hex-encoded.
+func FormatMsgID(id string) string {
+	for _, c := range []byte(id) {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '=') {
+			return "0x" + hex.EncodeToString([]byte(id))
+		}
+	}
+	return id
+}
@@ -0,0 +1,41 @@
+package gossip
+
+import (
+	"crypto/sha256"
+	"github.com/golang/snappy"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"testing"
+)
+
+func TestDomainMsgIDFunction(t *testing.T) {
+	data := []byte("hello eth2")
+	compressed := snappy.Encode(nil, data)
+
+	expected := sha256.Sum256(append([]byte{1, 0, 0, 0}, data...))
+	id := DomainMsgIDFunction(&pubsub_pb.Message{Data: compressed})
+	if len(id) != 20 {
+		t.Fatalf("expected 20 byte message ID, got %d bytes", len(id))
+	}
+	if id != string(expected[:20]) {
+		t.Fatal("unexpected message ID of valid snappy data")
+	}
+
+	invalid := []byte{0xff, 0xff, 0xff}
+	expected = sha256.Sum256(append([]byte{0, 0, 0, 0}, invalid...))
+	if id := DomainMsgIDFunction(&pubsub_pb.Message{Data: invalid}); id != string(expected[:20]) {
+		t.Fatal("unexpected message ID of invalid snappy data")
+	}
+
+	if DomainMsgIDFunction(&pubsub_pb.Message{Data: data}) == id {
+		t.Fatal("expected uncompressed data to be in a different domain")
+	}
+}
+
+func TestFormatMsgID(t *testing.T) {
+	if s := FormatMsgID("abc-_="); s != "abc-_=" {
+		t.Fatalf("expected base64 ID to be kept, got %s", s)
+	}
+	if s := FormatMsgID(string([]byte{0x00, 0xff})); s != "0x00ff" {
+		t.Fatalf("expected binary ID to be hex-encoded, got %s", s)
+	}
+}
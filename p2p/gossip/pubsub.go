@@ -2,40 +2,59 @@ package gossip
 
 import (
 	"context"
-	"encoding/base64"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
-	"github.com/minio/sha256-simd"
 )
 
 type GossipSub interface {
 	Join(topic string, opts ...pubsub.TopicOpt) (*pubsub.Topic, error)
 	BlacklistPeer(id peer.ID)
+	RegisterTopicValidator(topic string, val pubsub.Validator, opts ...pubsub.ValidatorOpt) error
+	UnregisterTopicValidator(topic string) error
+	// Tracer to listen for pubsub trace events with
+	Tracer() *Tracer
+	// Mesh tracks the mesh and counts control messages, from the start of the node
+	Mesh() *MeshTracker
+	// MsgID computes the message ID, with the function the node was started with
+	MsgID(pmsg *pubsub_pb.Message) string
 }
 
 type gossipImpl struct {
 	*pubsub.PubSub
+	tracer *Tracer
+	mesh   *MeshTracker
+	msgID  func(pmsg *pubsub_pb.Message) string
 }
 
-func NewGossipSub(ctx context.Context, h host.Host) (GossipSub, error) {
+func (g *gossipImpl) Tracer() *Tracer {
+	return g.tracer
+}
+
+func (g *gossipImpl) Mesh() *MeshTracker {
+	return g.mesh
+}
+
+func (g *gossipImpl) MsgID(pmsg *pubsub_pb.Message) string {
+	return g.msgID(pmsg)
+}
+
+// NewGossipSub starts a GossipSub node. Message IDs are computed as selected by msgIDMode.
+func NewGossipSub(ctx context.Context, h host.Host, msgIDMode MsgIDMode) (GossipSub, error) {
+	msgID := msgIDMode.Fn()
+	tracer := NewTracer()
+	mesh := NewMeshTracker()
+	tracer.AddListener("mesh", mesh.OnTrace)
 	psOptions := []pubsub.Option{
 		pubsub.WithMessageSigning(false),
 		pubsub.WithStrictSignatureVerification(false),
-		pubsub.WithMessageIdFn(MsgIDFunction),
+		pubsub.WithMessageIdFn(msgID),
+		pubsub.WithEventTracer(tracer),
 	}
 	ps, err := pubsub.NewGossipSub(ctx, h, psOptions...)
 	if err != nil {
 		return nil, err
 	}
-	return &gossipImpl{PubSub: ps}, nil
-}
-
-func MsgIDFunction(pmsg *pubsub_pb.Message) string {
-	h := sha256.New()
-	// never errors, see crypto/sha256 Go doc
-	_, _ = h.Write(pmsg.Data)
-	id := h.Sum(nil)
-	return base64.URLEncoding.EncodeToString(id)
+	return &gossipImpl{PubSub: ps, tracer: tracer, mesh: mesh, msgID: msgID}, nil
 }
@@ -0,0 +1,148 @@
+package gossip
+
+import (
+	"fmt"
+	"github.com/protolambda/rumor/p2p/types"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"math/rand"
+	"sort"
+)
+
+// Random long-lived subnet subscriptions, as a validator client would do, see the validator spec.
+const (
+	RandomSubnetsPerValidator         = 1
+	EpochsPerRandomSubnetSubscription = 256
+)
+
+type SubnetSubscription struct {
+	Subnet uint64 `json:"subnet"`
+	// Fixed subscriptions do not expire
+	Fixed bool `json:"fixed"`
+	// Epoch at which the subscription is rotated out
+	Expiry beacon.Epoch `json:"expiry,omitempty"`
+}
+
+// SubnetSchedule decides which attestation subnets to subscribe to: a set of fixed subnets,
+// and a number of random subnets that are rotated after a random amount of epochs,
+// in the range [RotationEpochs, 2*RotationEpochs).
+type SubnetSchedule struct {
+	Fixed          []uint64
+	Random         uint64
+	RotationEpochs beacon.Epoch
+
+	rng  *rand.Rand
+	subs map[uint64]*SubnetSubscription
+}
+
+func NewSubnetSchedule(fixed []uint64, random uint64, rotationEpochs beacon.Epoch, seed int64) (*SubnetSchedule, error) {
+	for _, s := range fixed {
+		if s >= types.ATTESTATION_SUBNET_COUNT {
+			return nil, fmt.Errorf("subnet %d out of range, there are %d subnets", s, types.ATTESTATION_SUBNET_COUNT)
+		}
+	}
+	if uint64(len(fixed))+random > types.ATTESTATION_SUBNET_COUNT {
+		return nil, fmt.Errorf("cannot subscribe to %d fixed and %d random subnets, there are %d subnets",
+			len(fixed), random, types.ATTESTATION_SUBNET_COUNT)
+	}
+	if random > 0 && rotationEpochs == 0 {
+		return nil, fmt.Errorf("random subnets need a rotation period")
+	}
+	return &SubnetSchedule{
+		Fixed:          fixed,
+		Random:         random,
+		RotationEpochs: rotationEpochs,
+		rng:            rand.New(rand.NewSource(seed)),
+		subs:           make(map[uint64]*SubnetSubscription),
+	}, nil
+}
+
+// Update the subscriptions for the given epoch. Expired random subnets are rotated out, and new ones are picked.
+// Returns the subnets to join and leave, in ascending order.
+func (s *SubnetSchedule) Update(epoch beacon.Epoch) (join []uint64, leave []uint64) {
+	for _, subnet := range s.Fixed {
+		if sub, ok := s.subs[subnet]; !ok {
+			s.subs[subnet] = &SubnetSubscription{Subnet: subnet, Fixed: true}
+			join = append(join, subnet)
+		} else {
+			sub.Fixed = true
+			sub.Expiry = 0
+		}
+	}
+	randomCount := uint64(0)
+	for subnet, sub := range s.subs {
+		if sub.Fixed {
+			continue
+		}
+		if epoch >= sub.Expiry {
+			delete(s.subs, subnet)
+			leave = append(leave, subnet)
+		} else {
+			randomCount += 1
+		}
+	}
+	for ; randomCount < s.Random; randomCount++ {
+		var free []uint64
+		for subnet := uint64(0); subnet < types.ATTESTATION_SUBNET_COUNT; subnet++ {
+			if _, ok := s.subs[subnet]; !ok {
+				free = append(free, subnet)
+			}
+		}
+		if len(free) == 0 {
+			break
+		}
+		subnet := free[s.rng.Intn(len(free))]
+		expiry := epoch + s.RotationEpochs + beacon.Epoch(s.rng.Int63n(int64(s.RotationEpochs)))
+		s.subs[subnet] = &SubnetSubscription{Subnet: subnet, Expiry: expiry}
+		join = append(join, subnet)
+	}
+	// a subnet may be left and re-joined in the same update, then nothing changes.
+	joinSet := make(map[uint64]bool, len(join))
+	for _, subnet := range join {
+		joinSet[subnet] = true
+	}
+	keptLeave := leave[:0]
+	for _, subnet := range leave {
+		if joinSet[subnet] {
+			delete(joinSet, subnet)
+			continue
+		}
+		keptLeave = append(keptLeave, subnet)
+	}
+	leave = keptLeave
+	join = join[:0]
+	for subnet := range joinSet {
+		join = append(join, subnet)
+	}
+	sort.Slice(join, func(i, j int) bool { return join[i] < join[j] })
+	sort.Slice(leave, func(i, j int) bool { return leave[i] < leave[j] })
+	return join, leave
+}
+
+// Subscriptions returns the current subscriptions, in order of subnet.
+func (s *SubnetSchedule) Subscriptions() []SubnetSubscription {
+	out := make([]SubnetSubscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		out = append(out, *sub)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Subnet < out[j].Subnet })
+	return out
+}
+
+// Attnets returns the bitfield of the subscribed subnets, as advertised in the ENR and metadata.
+func (s *SubnetSchedule) Attnets() (out types.AttnetBits) {
+	for subnet := range s.subs {
+		out[subnet/8] |= 1 << (subnet % 8)
+	}
+	return out
+}
+
+// AttestationSubnetTopic returns the topic of the given attestation subnet.
+func AttestationSubnetTopic(digest beacon.ForkDigest, subnet uint64) *Topic {
+	return &Topic{
+		Digest:   digest,
+		Name:     fmt.Sprintf("%s_%d", AttestationTopic.Name, subnet),
+		Encoding: SSZSnappyEncoding,
+		Type:     AttestationTopic,
+		Subnet:   subnet,
+	}
+}
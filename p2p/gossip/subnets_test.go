@@ -0,0 +1,97 @@
+package gossip
+
+import (
+	"github.com/protolambda/rumor/p2p/types"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"sort"
+	"testing"
+)
+
+func isSorted(subnets []uint64) bool {
+	return sort.SliceIsSorted(subnets, func(i, j int) bool { return subnets[i] < subnets[j] })
+}
+
+func TestSubnetScheduleUpdate(t *testing.T) {
+	s, err := NewSubnetSchedule([]uint64{3, 1}, 2, 4, 123)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join, leave := s.Update(0)
+	if len(join) != 4 || len(leave) != 0 {
+		t.Fatalf("expected to join 2 fixed and 2 random subnets, got join %v, leave %v", join, leave)
+	}
+	if !isSorted(join) {
+		t.Fatalf("expected sorted join: %v", join)
+	}
+	subs := s.Subscriptions()
+	if len(subs) != 4 {
+		t.Fatalf("expected 4 subscriptions, got %d", len(subs))
+	}
+	for _, sub := range subs {
+		fixed := sub.Subnet == 1 || sub.Subnet == 3
+		if sub.Fixed != fixed {
+			t.Fatalf("subnet %d: expected fixed=%v", sub.Subnet, fixed)
+		}
+		if !fixed && (sub.Expiry < 4 || sub.Expiry >= 8) {
+			t.Fatalf("random subnet %d: expiry %d out of rotation range [4, 8)", sub.Subnet, sub.Expiry)
+		}
+	}
+	attnets := s.Attnets()
+	count := 0
+	for subnet := uint64(0); subnet < types.ATTESTATION_SUBNET_COUNT; subnet++ {
+		if attnets[subnet/8]&(1<<(subnet%8)) != 0 {
+			count++
+		}
+	}
+	if count != 4 || attnets[0]&0b1010 != 0b1010 {
+		t.Fatalf("unexpected attnets: %x", attnets)
+	}
+
+	// nothing expires before the rotation period
+	if join, leave := s.Update(3); len(join) != 0 || len(leave) != 0 {
+		t.Fatalf("expected no changes before expiry, got join %v, leave %v", join, leave)
+	}
+
+	// all random subnets expire after twice the rotation period, fixed subnets stay
+	prev := s.Subscriptions()
+	join, leave = s.Update(8)
+	if !isSorted(join) || !isSorted(leave) {
+		t.Fatalf("expected sorted join and leave: %v, %v", join, leave)
+	}
+	if len(join) != len(leave) {
+		t.Fatalf("expected as many joined as left subnets, got join %v, leave %v", join, leave)
+	}
+	for _, subnet := range leave {
+		if subnet == 1 || subnet == 3 {
+			t.Fatalf("fixed subnet %d was left", subnet)
+		}
+	}
+	for _, subnet := range join {
+		for _, sub := range prev {
+			if sub.Subnet == subnet {
+				t.Fatalf("joined subnet %d that was already subscribed", subnet)
+			}
+		}
+	}
+	subs = s.Subscriptions()
+	if len(subs) != 4 {
+		t.Fatalf("expected 4 subscriptions after rotation, got %d", len(subs))
+	}
+	for _, sub := range subs {
+		if !sub.Fixed && sub.Expiry < 12 {
+			t.Fatalf("random subnet %d was not rotated, expiry %d", sub.Subnet, sub.Expiry)
+		}
+	}
+}
+
+func TestSubnetScheduleRange(t *testing.T) {
+	if _, err := NewSubnetSchedule([]uint64{types.ATTESTATION_SUBNET_COUNT}, 0, 0, 0); err == nil {
+		t.Fatal("expected out of range subnet to fail")
+	}
+	if _, err := NewSubnetSchedule(nil, types.ATTESTATION_SUBNET_COUNT+1, 1, 0); err == nil {
+		t.Fatal("expected too many subnets to fail")
+	}
+	if _, err := NewSubnetSchedule(nil, 1, beacon.Epoch(0), 0); err == nil {
+		t.Fatal("expected random subnets without rotation period to fail")
+	}
+}
@@ -0,0 +1,313 @@
+package gossip
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/protolambda/rumor/p2p/types"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zssz"
+	ztypes "github.com/protolambda/zssz/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const SSZSnappyEncoding = "ssz_snappy"
+
+// TopicType describes the messages of an eth2 topic, and how to summarize them.
+type TopicType struct {
+	// Name of the topic, e.g. "beacon_block".
+	// For subnet topics, the subnet is appended, e.g. "beacon_attestation_5"
+	Name string
+	// True if there is a topic per subnet
+	Subnets bool
+
+	ssz       ztypes.SSZ
+	rTyp      reflect.Type
+	summarize func(obj interface{}) map[string]interface{}
+}
+
+func newTopicType(name string, subnets bool, typ interface{}, summarize func(obj interface{}) map[string]interface{}) *TopicType {
+	return &TopicType{
+		Name:      name,
+		Subnets:   subnets,
+		ssz:       zssz.GetSSZ(typ),
+		rTyp:      reflect.TypeOf(typ).Elem(),
+		summarize: summarize,
+	}
+}
+
+// Alloc a new message object, to decode into
+func (t *TopicType) Alloc() interface{} {
+	return reflect.New(t.rTyp).Interface()
+}
+
+// Decode the SSZ message contents (uncompressed) into a new typed object.
+func (t *TopicType) Decode(data []byte) (interface{}, error) {
+	obj := t.Alloc()
+	if err := zssz.Decode(bytes.NewReader(data), uint64(len(data)), obj, t.ssz); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Encode the typed object to SSZ (uncompressed)
+func (t *TopicType) Encode(obj interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := zssz.Encode(&buf, obj, t.ssz); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Summary of the decoded message, to log.
+func (t *TopicType) Summary(obj interface{}) map[string]interface{} {
+	return t.summarize(obj)
+}
+
+var (
+	BeaconBlockTopic = newTopicType("beacon_block", false, (*beacon.SignedBeaconBlock)(nil),
+		func(obj interface{}) map[string]interface{} {
+			b := obj.(*beacon.SignedBeaconBlock)
+			return map[string]interface{}{
+				"slot":         b.Message.Slot,
+				"proposer":     b.Message.ProposerIndex,
+				"root":         b.Message.HashTreeRoot(),
+				"parent_root":  b.Message.ParentRoot,
+				"attestations": len(b.Message.Body.Attestations),
+			}
+		})
+	AggregateAndProofTopic = newTopicType("beacon_aggregate_and_proof", false, (*SignedAggregateAndProof)(nil),
+		func(obj interface{}) map[string]interface{} {
+			a := obj.(*SignedAggregateAndProof)
+			att := &a.Message.Aggregate
+			return map[string]interface{}{
+				"aggregator":      a.Message.AggregatorIndex,
+				"slot":            att.Data.Slot,
+				"committee_index": att.Data.Index,
+				"block_root":      att.Data.BeaconBlockRoot,
+				"bits_count":      countBits(att.AggregationBits),
+				"bits_len":        att.AggregationBits.BitLen(),
+			}
+		})
+	AttestationTopic = newTopicType("beacon_attestation", true, (*beacon.Attestation)(nil),
+		func(obj interface{}) map[string]interface{} {
+			att := obj.(*beacon.Attestation)
+			return map[string]interface{}{
+				"slot":            att.Data.Slot,
+				"committee_index": att.Data.Index,
+				"block_root":      att.Data.BeaconBlockRoot,
+				"bits_count":      countBits(att.AggregationBits),
+				"bits_len":        att.AggregationBits.BitLen(),
+			}
+		})
+	VoluntaryExitTopic = newTopicType("voluntary_exit", false, (*beacon.SignedVoluntaryExit)(nil),
+		func(obj interface{}) map[string]interface{} {
+			e := obj.(*beacon.SignedVoluntaryExit)
+			return map[string]interface{}{
+				"validator_index": e.Message.ValidatorIndex,
+				"epoch":           e.Message.Epoch,
+			}
+		})
+	ProposerSlashingTopic = newTopicType("proposer_slashing", false, (*beacon.ProposerSlashing)(nil),
+		func(obj interface{}) map[string]interface{} {
+			s := obj.(*beacon.ProposerSlashing)
+			return map[string]interface{}{
+				"validator_index": s.SignedHeader1.Message.ProposerIndex,
+				"slot":            s.SignedHeader1.Message.Slot,
+			}
+		})
+	AttesterSlashingTopic = newTopicType("attester_slashing", false, (*beacon.AttesterSlashing)(nil),
+		func(obj interface{}) map[string]interface{} {
+			s := obj.(*beacon.AttesterSlashing)
+			return map[string]interface{}{
+				"slot_1":    s.Attestation1.Data.Slot,
+				"slot_2":    s.Attestation2.Data.Slot,
+				"indices_1": len(s.Attestation1.AttestingIndices),
+				"indices_2": len(s.Attestation2.AttestingIndices),
+			}
+		})
+)
+
+// TopicTypes lists all known eth2 topic types
+var TopicTypes = []*TopicType{
+	BeaconBlockTopic,
+	AggregateAndProofTopic,
+	AttestationTopic,
+	VoluntaryExitTopic,
+	ProposerSlashingTopic,
+	AttesterSlashingTopic,
+}
+
+// TopicTypeByName finds the topic type by name, e.g. "beacon_block".
+// Subnet topics are matched with or without subnet, e.g. "beacon_attestation_5" and "beacon_attestation".
+// Returns the subnet, if the name has one.
+func TopicTypeByName(name string) (typ *TopicType, subnet uint64, hasSubnet bool) {
+	for _, t := range TopicTypes {
+		if t.Name == name {
+			return t, 0, false
+		}
+		if t.Subnets && strings.HasPrefix(name, t.Name+"_") {
+			n, err := strconv.ParseUint(name[len(t.Name)+1:], 10, 64)
+			if err == nil {
+				return t, n, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// Topic is a parsed eth2 topic: /eth2/{fork-digest}/{name}/{encoding}
+type Topic struct {
+	Digest beacon.ForkDigest
+	// Full name, including any subnet. E.g. "beacon_attestation_5"
+	Name     string
+	Encoding string
+	// Nil if the topic name is not known
+	Type   *TopicType
+	Subnet uint64
+}
+
+// ParseTopic parses an eth2 topic. Unknown topic names are not an error, the type is nil in that case.
+func ParseTopic(topic string) (*Topic, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "eth2" {
+		return nil, fmt.Errorf("not an eth2 topic: %s", topic)
+	}
+	var digest beacon.ForkDigest
+	digestBytes, err := hex.DecodeString(parts[2])
+	if err != nil || len(digestBytes) != 4 {
+		return nil, fmt.Errorf("topic %s has invalid fork digest", topic)
+	}
+	copy(digest[:], digestBytes)
+	typ, subnet, _ := TopicTypeByName(parts[3])
+	return &Topic{
+		Digest:   digest,
+		Name:     parts[3],
+		Encoding: parts[4],
+		Type:     typ,
+		Subnet:   subnet,
+	}, nil
+}
+
+func (t *Topic) String() string {
+	return fmt.Sprintf("/eth2/%x/%s/%s", t.Digest[:], t.Name, t.Encoding)
+}
+
+// Uncompress the message data, if the encoding is compressed
+func (t *Topic) Uncompress(data []byte) ([]byte, error) {
+	if strings.HasSuffix(t.Encoding, "_snappy") {
+		return snappy.Decode(nil, data)
+	}
+	return data, nil
+}
+
+// Compress the message data, if the encoding is compressed
+func (t *Topic) Compress(data []byte) []byte {
+	if strings.HasSuffix(t.Encoding, "_snappy") {
+		return snappy.Encode(nil, data)
+	}
+	return data
+}
+
+var UnknownTopicTypeErr = errors.New("unknown topic type")
+
+// Decode the message data, as received on the topic, into a typed object.
+func (t *Topic) Decode(data []byte) (interface{}, error) {
+	if t.Type == nil {
+		return nil, UnknownTopicTypeErr
+	}
+	raw, err := t.Uncompress(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot uncompress message on %s: %v", t.Name, err)
+	}
+	return t.Type.Decode(raw)
+}
+
+// Short aliases of topic names, usable in short topic names
+var TopicAliases = map[string]string{
+	"block":       BeaconBlockTopic.Name,
+	"aggregate":   AggregateAndProofTopic.Name,
+	"attestation": AttestationTopic.Name,
+	"exit":        VoluntaryExitTopic.Name,
+}
+
+// IsShortTopic checks if the topic is a short name, as accepted by ExpandTopic, instead of a full topic.
+func IsShortTopic(name string) bool {
+	return !strings.HasPrefix(name, "/")
+}
+
+// ExpandTopic expands a short topic name into a full eth2 topic with the given fork digest,
+// and the ssz_snappy encoding. E.g. "beacon_block", "attestation:5" or "beacon_attestation_5".
+// Full topic names, starting with "/", are parsed as-is.
+func ExpandTopic(name string, digest beacon.ForkDigest) (*Topic, error) {
+	if !IsShortTopic(name) {
+		return ParseTopic(name)
+	}
+	typeName, subnetStr := name, ""
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		typeName, subnetStr = name[:i], name[i+1:]
+	}
+	if alias, ok := TopicAliases[typeName]; ok {
+		typeName = alias
+	}
+	typ, subnet, hasSubnet := TopicTypeByName(typeName)
+	if typ == nil {
+		return nil, fmt.Errorf("unknown topic name: %s", typeName)
+	}
+	if subnetStr != "" {
+		if hasSubnet || !typ.Subnets {
+			return nil, fmt.Errorf("topic %s cannot have subnet %s", typeName, subnetStr)
+		}
+		n, err := strconv.ParseUint(subnetStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %s: %v", subnetStr, err)
+		}
+		subnet, hasSubnet = n, true
+	}
+	if typ.Subnets && !hasSubnet {
+		return nil, fmt.Errorf("topic %s needs a subnet, e.g. %s:0", typ.Name, typ.Name)
+	}
+	if typ.Subnets && subnet >= types.ATTESTATION_SUBNET_COUNT {
+		return nil, fmt.Errorf("subnet %d out of range, there are %d subnets", subnet, types.ATTESTATION_SUBNET_COUNT)
+	}
+	topicName := typ.Name
+	if typ.Subnets {
+		topicName = fmt.Sprintf("%s_%d", typ.Name, subnet)
+	}
+	return &Topic{
+		Digest:   digest,
+		Name:     topicName,
+		Encoding: SSZSnappyEncoding,
+		Type:     typ,
+		Subnet:   subnet,
+	}, nil
+}
+
+// WithDigest returns a copy of the topic, with a different fork digest.
+func (t *Topic) WithDigest(digest beacon.ForkDigest) *Topic {
+	out := *t
+	out.Digest = digest
+	return &out
+}
+
+// StateForkDigest computes the current fork digest of the state, and returns the genesis validators root
+// to compute digests of future forks with.
+func StateForkDigest(state *beacon.BeaconStateView) (digest beacon.ForkDigest, genesisValidatorsRoot beacon.Root, err error) {
+	forkView, err := state.Fork()
+	if err != nil {
+		return
+	}
+	version, err := forkView.CurrentVersion()
+	if err != nil {
+		return
+	}
+	genesisValidatorsRoot, err = state.GenesisValidatorsRoot()
+	if err != nil {
+		return
+	}
+	return beacon.ComputeForkDigest(version, genesisValidatorsRoot), genesisValidatorsRoot, nil
+}
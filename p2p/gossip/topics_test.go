@@ -0,0 +1,80 @@
+package gossip
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"testing"
+)
+
+func TestParseTopic(t *testing.T) {
+	topic, err := ParseTopic("/eth2/e7a75d5a/beacon_attestation_12/ssz_snappy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic.Type != AttestationTopic {
+		t.Fatalf("unexpected topic type: %v", topic.Type)
+	}
+	if topic.Subnet != 12 {
+		t.Fatalf("unexpected subnet: %d", topic.Subnet)
+	}
+	if s := topic.String(); s != "/eth2/e7a75d5a/beacon_attestation_12/ssz_snappy" {
+		t.Fatalf("unexpected topic string: %s", s)
+	}
+
+	topic, err = ParseTopic("/eth2/e7a75d5a/unknown_topic/ssz_snappy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic.Type != nil {
+		t.Fatal("expected unknown topic type")
+	}
+
+	if _, err := ParseTopic("/eth2/e7a7/beacon_block/ssz_snappy"); err == nil {
+		t.Fatal("expected invalid fork digest error")
+	}
+	if _, err := ParseTopic("/foobar/beacon_block"); err == nil {
+		t.Fatal("expected non-eth2 topic error")
+	}
+}
+
+func TestBlockRoundtrip(t *testing.T) {
+	topic, err := ParseTopic("/eth2/00000000/beacon_block/ssz_snappy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := topic.Type.Alloc()
+	raw, err := topic.Type.Encode(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := topic.Decode(topic.Compress(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := topic.Type.Summary(obj); summary["slot"] == nil {
+		t.Fatalf("expected slot in summary: %v", summary)
+	}
+}
+
+func TestExpandTopic(t *testing.T) {
+	digest := beacon.ForkDigest{0xe7, 0xa7, 0x5d, 0x5a}
+	for short, expected := range map[string]string{
+		"beacon_block":         "/eth2/e7a75d5a/beacon_block/ssz_snappy",
+		"block":                "/eth2/e7a75d5a/beacon_block/ssz_snappy",
+		"attestation:5":        "/eth2/e7a75d5a/beacon_attestation_5/ssz_snappy",
+		"beacon_attestation_5": "/eth2/e7a75d5a/beacon_attestation_5/ssz_snappy",
+		"/eth2/00000000/voluntary_exit/ssz_snappy": "/eth2/00000000/voluntary_exit/ssz_snappy",
+	} {
+		topic, err := ExpandTopic(short, digest)
+		if err != nil {
+			t.Fatalf("%s: %v", short, err)
+		}
+		if s := topic.String(); s != expected {
+			t.Fatalf("%s: unexpected topic: %s", short, s)
+		}
+	}
+	for _, invalid := range []string{"attestation", "attestation:64", "beacon_block:1", "unknown"} {
+		if _, err := ExpandTopic(invalid, digest); err == nil {
+			t.Fatalf("%s: expected error", invalid)
+		}
+	}
+}
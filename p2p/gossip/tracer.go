@@ -0,0 +1,45 @@
+package gossip
+
+import (
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"sync"
+)
+
+// TraceListener receives pubsub trace events. Listeners are called synchronously
+// from the pubsub event loop, and should not block.
+type TraceListener func(evt *pubsub_pb.TraceEvent)
+
+// Tracer is a pubsub event tracer that multiplexes the events to any registered listeners.
+type Tracer struct {
+	lock      sync.RWMutex
+	listeners map[string]TraceListener
+}
+
+func NewTracer() *Tracer {
+	return &Tracer{listeners: make(map[string]TraceListener)}
+}
+
+// AddListener registers a listener by key. It returns false if the key is already taken.
+func (t *Tracer) AddListener(key string, listener TraceListener) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.listeners[key]; ok {
+		return false
+	}
+	t.listeners[key] = listener
+	return true
+}
+
+func (t *Tracer) RemoveListener(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.listeners, key)
+}
+
+func (t *Tracer) Trace(evt *pubsub_pb.TraceEvent) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	for _, l := range t.listeners {
+		l(evt)
+	}
+}
@@ -0,0 +1,33 @@
+package gossip
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zssz"
+)
+
+// AggregateAndProof is not available in zrnt (yet), it is only used on the network.
+type AggregateAndProof struct {
+	AggregatorIndex beacon.ValidatorIndex
+	Aggregate       beacon.Attestation
+	SelectionProof  beacon.BLSSignature
+}
+
+type SignedAggregateAndProof struct {
+	Message   AggregateAndProof
+	Signature beacon.BLSSignature
+}
+
+var aggregateAndProofMessageSSZ = zssz.GetSSZ((*AggregateAndProof)(nil))
+
+var SignedAggregateAndProofSSZ = zssz.GetSSZ((*SignedAggregateAndProof)(nil))
+
+// countBits counts the set bits of the bitlist
+func countBits(bits beacon.CommitteeBits) (out uint64) {
+	n := bits.BitLen()
+	for i := uint64(0); i < n; i++ {
+		if bits.GetBit(i) {
+			out++
+		}
+	}
+	return
+}
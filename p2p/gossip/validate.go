@@ -0,0 +1,399 @@
+package gossip
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/minio/sha256-simd"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/p2p/types"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/util/bls"
+	"github.com/protolambda/zrnt/eth2/util/ssz"
+	"time"
+)
+
+type ValidationResult uint8
+
+const (
+	// The message is valid, and relayed
+	ValidationAccept ValidationResult = iota
+	// The message is not relayed, but the sender is not at fault
+	ValidationIgnore
+	// The message is invalid, and the sender is at fault
+	ValidationReject
+)
+
+func (r ValidationResult) String() string {
+	switch r {
+	case ValidationAccept:
+		return "accept"
+	case ValidationIgnore:
+		return "ignore"
+	case ValidationReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(r))
+	}
+}
+
+// ValidationMode decides what happens with a message after it was validated.
+type ValidationMode string
+
+const (
+	// Accepted messages are delivered and relayed, ignored and rejected messages are dropped.
+	ValidationHonest ValidationMode = "honest"
+	// All messages are delivered and relayed, the result is only reported.
+	// Note that pubsub does not distinguish delivery from relaying.
+	ValidationObserve ValidationMode = "observe"
+	// No validation, all messages are delivered and relayed.
+	ValidationOff ValidationMode = "off"
+)
+
+func (m ValidationMode) Valid() bool {
+	switch m {
+	case ValidationHonest, ValidationObserve, ValidationOff:
+		return true
+	default:
+		return false
+	}
+}
+
+// Pass returns true if the message should be delivered and relayed.
+func (m ValidationMode) Pass(res ValidationResult) bool {
+	return m != ValidationHonest || res == ValidationAccept
+}
+
+// ValidationListener is called with the result of every validated message.
+type ValidationListener func(from peer.ID, topic string, res ValidationResult, reason string)
+
+// ChainFn gets the chain to validate messages against.
+type ChainFn func() (chain.FullChain, error)
+
+var NoChainErr = errors.New("no chain to validate against")
+
+// Max clock disparity, as specified in the p2p spec.
+const MaximumGossipClockDisparity = 500 * time.Millisecond
+
+// Max age of attestations, in slots, as specified in the p2p spec.
+const AttestationPropagationSlotRange = 32
+
+// Expected number of aggregators per committee, as specified in the validator spec.
+const TargetAggregatorsPerCommittee = 16
+
+// Eth2Validator validates eth2 gossip messages, following the p2p spec.
+// Checks that need a beacon state are run against the current chain, if the state is available.
+type Eth2Validator struct {
+	GetChain ChainFn
+}
+
+// Validate the decoded message of the topic. The reason describes why the message is not accepted.
+func (v *Eth2Validator) Validate(ctx context.Context, topic *Topic, obj interface{}) (res ValidationResult, reason string) {
+	ch, err := v.GetChain()
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	switch x := obj.(type) {
+	case *beacon.SignedBeaconBlock:
+		return validateBlock(ctx, ch, x)
+	case *SignedAggregateAndProof:
+		return validateAggregate(ctx, ch, x)
+	case *beacon.Attestation:
+		return validateAttestation(ctx, ch, x, topic.Subnet)
+	case *beacon.SignedVoluntaryExit:
+		return validateExit(ctx, ch, x)
+	case *beacon.ProposerSlashing:
+		return validateProposerSlashing(ctx, ch, x)
+	case *beacon.AttesterSlashing:
+		return validateAttesterSlashing(ctx, ch, x)
+	default:
+		return ValidationIgnore, fmt.Sprintf("no validation for message type %T", obj)
+	}
+}
+
+// headState returns the head entry and its state. The chain returns a copy of the state, which is safe to modify.
+func headState(ctx context.Context, ch chain.FullChain) (chain.ChainEntry, *beacon.BeaconStateView, error) {
+	head, err := ch.Head()
+	if err != nil {
+		return nil, nil, fmt.Errorf("no chain head: %v", err)
+	}
+	state, err := head.State(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("no head state: %v", err)
+	}
+	return head, state, nil
+}
+
+// clockSlots returns the current slot, allowing for clock disparity: the earliest and the latest possible slot.
+func clockSlots(state *beacon.BeaconStateView) (earliest beacon.Slot, latest beacon.Slot, err error) {
+	genesis, err := state.GenesisTime()
+	if err != nil {
+		return 0, 0, err
+	}
+	now := time.Now()
+	toSlot := func(t time.Time) beacon.Slot {
+		ts := beacon.Timestamp(t.Unix())
+		if ts < genesis {
+			return 0
+		}
+		return ts.ToSlot(genesis)
+	}
+	return toSlot(now.Add(-MaximumGossipClockDisparity)), toSlot(now.Add(MaximumGossipClockDisparity)), nil
+}
+
+func validateBlock(ctx context.Context, ch chain.FullChain, block *beacon.SignedBeaconBlock) (ValidationResult, string) {
+	_, state, err := headState(ctx, ch)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	_, latest, err := clockSlots(state)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	slot := block.Message.Slot
+	if slot > latest {
+		return ValidationIgnore, fmt.Sprintf("block slot %d is in the future, current slot: %d", slot, latest)
+	}
+	if fin := ch.Finalized(); slot <= fin.Epoch.GetStartSlot() {
+		return ValidationIgnore, fmt.Sprintf("block slot %d is not later than finalized epoch %d", slot, fin.Epoch)
+	}
+	parent, err := ch.ByBlockRoot(block.Message.ParentRoot)
+	if err != nil {
+		return ValidationIgnore, fmt.Sprintf("unknown parent %s", block.Message.ParentRoot)
+	}
+	if slot <= parent.Slot() {
+		return ValidationReject, fmt.Sprintf("block slot %d is not later than parent slot %d", slot, parent.Slot())
+	}
+	epc, err := parent.EpochsContext(ctx)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	parentState, err := parent.State(ctx)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	if slot.ToEpoch() != epc.CurrentEpoch.Epoch {
+		// The proposer and signature domain depend on the epoch transition:
+		// process the empty slots up to the block, on top of the (copied) parent state.
+		if err := parentState.ProcessSlots(ctx, epc, slot); err != nil {
+			return ValidationIgnore, fmt.Sprintf("failed to process slots up to block: %v", err)
+		}
+	}
+	proposer, err := epc.GetBeaconProposer(slot)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	if proposer != block.Message.ProposerIndex {
+		return ValidationReject, fmt.Sprintf("unexpected proposer %d, expected %d", block.Message.ProposerIndex, proposer)
+	}
+	if !parentState.VerifySignature(epc, block, false) {
+		return ValidationReject, "invalid proposer signature"
+	}
+	return ValidationAccept, ""
+}
+
+// checkAttestationData runs the checks shared by subnet attestations and aggregates,
+// and returns the committee of the attestation.
+func checkAttestationData(ctx context.Context, ch chain.FullChain, state *beacon.BeaconStateView,
+	epc *beacon.EpochsContext, att *beacon.Attestation) ([]beacon.ValidatorIndex, ValidationResult, string) {
+	earliest, latest, err := clockSlots(state)
+	if err != nil {
+		return nil, ValidationIgnore, err.Error()
+	}
+	slot := att.Data.Slot
+	if slot > latest || slot+AttestationPropagationSlotRange < earliest {
+		return nil, ValidationIgnore, fmt.Sprintf("attestation slot %d is outside of propagation range, current slot: %d", slot, earliest)
+	}
+	if att.Data.Target.Epoch != slot.ToEpoch() {
+		return nil, ValidationReject, fmt.Sprintf("target epoch %d does not match slot %d", att.Data.Target.Epoch, slot)
+	}
+	if _, err := ch.ByBlockRoot(att.Data.BeaconBlockRoot); err != nil {
+		return nil, ValidationIgnore, fmt.Sprintf("unknown block %s", att.Data.BeaconBlockRoot)
+	}
+	committee, err := epc.GetBeaconCommittee(slot, att.Data.Index)
+	if err != nil {
+		return nil, ValidationIgnore, err.Error()
+	}
+	if att.AggregationBits.BitLen() != uint64(len(committee)) {
+		return nil, ValidationReject, fmt.Sprintf("aggregation bits length %d does not match committee size %d",
+			att.AggregationBits.BitLen(), len(committee))
+	}
+	return committee, ValidationAccept, ""
+}
+
+// verifyAttestationSignature checks the aggregate signature of the attestation
+func verifyAttestationSignature(state *beacon.BeaconStateView, epc *beacon.EpochsContext,
+	att *beacon.Attestation, committee []beacon.ValidatorIndex) error {
+	indexed, err := att.ConvertToIndexed(committee)
+	if err != nil {
+		return err
+	}
+	return state.ValidateIndexedAttestation(epc, indexed)
+}
+
+// ComputeSubnetForAttestation computes the subnet an attestation is expected on, following the validator spec.
+func ComputeSubnetForAttestation(committeesPerSlot uint64, slot beacon.Slot, index beacon.CommitteeIndex) uint64 {
+	slotsSinceEpochStart := uint64(slot % beacon.SLOTS_PER_EPOCH)
+	committeesSinceEpochStart := committeesPerSlot * slotsSinceEpochStart
+	return (committeesSinceEpochStart + uint64(index)) % types.ATTESTATION_SUBNET_COUNT
+}
+
+// AttestationSubnet computes the subnet of the attestation, with the committees of the head of the chain.
+func AttestationSubnet(ctx context.Context, ch chain.FullChain, att *beacon.Attestation) (uint64, error) {
+	head, err := ch.Head()
+	if err != nil {
+		return 0, fmt.Errorf("no chain head: %v", err)
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	committeesPerSlot, err := epc.GetCommitteeCountAtSlot(att.Data.Slot)
+	if err != nil {
+		return 0, err
+	}
+	return ComputeSubnetForAttestation(committeesPerSlot, att.Data.Slot, att.Data.Index), nil
+}
+
+func validateAttestation(ctx context.Context, ch chain.FullChain, att *beacon.Attestation, subnet uint64) (ValidationResult, string) {
+	if n := countBits(att.AggregationBits); n != 1 {
+		return ValidationReject, fmt.Sprintf("expected exactly 1 aggregation bit, got %d", n)
+	}
+	head, state, err := headState(ctx, ch)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	committee, res, reason := checkAttestationData(ctx, ch, state, epc, att)
+	if res != ValidationAccept {
+		return res, reason
+	}
+	committeesPerSlot, err := epc.GetCommitteeCountAtSlot(att.Data.Slot)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	if expected := ComputeSubnetForAttestation(committeesPerSlot, att.Data.Slot, att.Data.Index); expected != subnet {
+		return ValidationReject, fmt.Sprintf("attestation is for subnet %d, but received on subnet %d", expected, subnet)
+	}
+	if err := verifyAttestationSignature(state, epc, att, committee); err != nil {
+		return ValidationReject, err.Error()
+	}
+	return ValidationAccept, ""
+}
+
+// IsAggregator checks the selection proof of an aggregator, following the validator spec.
+func IsAggregator(committeeSize uint64, selectionProof beacon.BLSSignature) bool {
+	modulo := committeeSize / TargetAggregatorsPerCommittee
+	if modulo == 0 {
+		modulo = 1
+	}
+	h := sha256.Sum256(selectionProof[:])
+	return binary.LittleEndian.Uint64(h[:8])%modulo == 0
+}
+
+func validateAggregate(ctx context.Context, ch chain.FullChain, agg *SignedAggregateAndProof) (ValidationResult, string) {
+	head, state, err := headState(ctx, ch)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	att := &agg.Message.Aggregate
+	committee, res, reason := checkAttestationData(ctx, ch, state, epc, att)
+	if res != ValidationAccept {
+		return res, reason
+	}
+	aggregator := agg.Message.AggregatorIndex
+	inCommittee := false
+	for _, i := range committee {
+		if i == aggregator {
+			inCommittee = true
+			break
+		}
+	}
+	if !inCommittee {
+		return ValidationReject, fmt.Sprintf("aggregator %d is not part of the committee", aggregator)
+	}
+	if !IsAggregator(uint64(len(committee)), agg.Message.SelectionProof) {
+		return ValidationReject, fmt.Sprintf("validator %d is not selected as aggregator", aggregator)
+	}
+	pub, ok := epc.PubkeyCache.Pubkey(aggregator)
+	if !ok {
+		return ValidationIgnore, fmt.Sprintf("unknown pubkey of aggregator %d", aggregator)
+	}
+	epoch := att.Data.Slot.ToEpoch()
+	selDom, err := state.GetDomain(beacon.DOMAIN_SELECTION_PROOF, epoch)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	var slotRoot beacon.Root
+	binary.LittleEndian.PutUint64(slotRoot[:8], uint64(att.Data.Slot))
+	if !bls.Verify(pub, beacon.ComputeSigningRoot(slotRoot, selDom), agg.Message.SelectionProof) {
+		return ValidationReject, "invalid selection proof"
+	}
+	aggDom, err := state.GetDomain(beacon.DOMAIN_AGGREGATE_AND_PROOF, epoch)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	msgRoot := ssz.HashTreeRoot(&agg.Message, aggregateAndProofMessageSSZ)
+	if !bls.Verify(pub, beacon.ComputeSigningRoot(msgRoot, aggDom), agg.Signature) {
+		return ValidationReject, "invalid aggregate and proof signature"
+	}
+	if err := verifyAttestationSignature(state, epc, att, committee); err != nil {
+		return ValidationReject, err.Error()
+	}
+	return ValidationAccept, ""
+}
+
+func validateExit(ctx context.Context, ch chain.FullChain, exit *beacon.SignedVoluntaryExit) (ValidationResult, string) {
+	head, state, err := headState(ctx, ch)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	// Processing the exit on the copy of the state checks it fully, including the signature.
+	if err := state.ProcessVoluntaryExit(epc, exit); err != nil {
+		return ValidationReject, err.Error()
+	}
+	return ValidationAccept, ""
+}
+
+func validateProposerSlashing(ctx context.Context, ch chain.FullChain, sl *beacon.ProposerSlashing) (ValidationResult, string) {
+	head, state, err := headState(ctx, ch)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	if err := state.ProcessProposerSlashing(epc, sl); err != nil {
+		return ValidationReject, err.Error()
+	}
+	return ValidationAccept, ""
+}
+
+func validateAttesterSlashing(ctx context.Context, ch chain.FullChain, sl *beacon.AttesterSlashing) (ValidationResult, string) {
+	head, state, err := headState(ctx, ch)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return ValidationIgnore, err.Error()
+	}
+	if err := state.ProcessAttesterSlashing(epc, sl); err != nil {
+		return ValidationReject, err.Error()
+	}
+	return ValidationAccept, ""
+}
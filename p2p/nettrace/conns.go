@@ -0,0 +1,89 @@
+package nettrace
+
+import (
+	"github.com/libp2p/go-libp2p-core/network"
+	"reflect"
+	"strings"
+)
+
+// Notifiee traces connections, with the security and muxer that were selected for them.
+func (t *Tracer) Notifiee() network.Notifiee {
+	return &network.NotifyBundle{
+		ConnectedF: func(n network.Network, conn network.Conn) {
+			if !t.active() {
+				return
+			}
+			security, muxer := ConnUpgrades(conn)
+			t.Trace(&Event{Type: Connected, Peer: conn.RemotePeer(), Direction: fmtDirection(conn.Stat().Direction),
+				Addr: conn.RemoteMultiaddr().String(), Security: security, Muxer: muxer})
+		},
+		DisconnectedF: func(n network.Network, conn network.Conn) {
+			if !t.active() {
+				return
+			}
+			security, muxer := ConnUpgrades(conn)
+			t.Trace(&Event{Type: Disconnected, Peer: conn.RemotePeer(), Direction: fmtDirection(conn.Stat().Direction),
+				Addr: conn.RemoteMultiaddr().String(), Security: security, Muxer: muxer})
+		},
+	}
+}
+
+// ConnUpgrades returns the names of the security and muxer implementations of the connection,
+// e.g. "noise" and "yamux", or empty strings if they cannot be determined.
+// The libp2p version of rumor does not expose the negotiated protocol IDs of the connection upgrades,
+// so they are inferred from the types in the swarm and upgrader connection wrappers (read-only, via reflection).
+func ConnUpgrades(conn network.Conn) (security string, muxer string) {
+	v := reflect.ValueOf(conn)
+	// swarm.Conn keeps the upgraded transport connection in the "conn" field
+	if v = field(v, "conn"); !v.IsValid() {
+		return "", ""
+	}
+	return implName(field(v, "ConnSecurity")), implName(field(v, "MuxedConn"))
+}
+
+// field returns the named struct field, dereferencing pointers and interfaces first.
+func field(v reflect.Value, name string) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return v.FieldByName(name)
+}
+
+// implName derives an implementation name from the package of the dynamic type,
+// e.g. "github.com/libp2p/go-libp2p-noise" becomes "noise".
+func implName(v reflect.Value) string {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+	pkg := v.Type().PkgPath()
+	if pkg == "" {
+		return ""
+	}
+	name := pkg[strings.LastIndex(pkg, "/")+1:]
+	name = strings.TrimPrefix(name, "go-libp2p-")
+	name = strings.TrimPrefix(name, "go-")
+	return name
+}
+
+func fmtDirection(d network.Direction) string {
+	switch d {
+	case network.DirInbound:
+		return "inbound"
+	case network.DirOutbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
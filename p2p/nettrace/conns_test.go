@@ -0,0 +1,57 @@
+package nettrace
+
+import (
+	"context"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mplex "github.com/libp2p/go-libp2p-mplex"
+	noise "github.com/libp2p/go-libp2p-noise"
+	secio "github.com/libp2p/go-libp2p-secio"
+	yamux "github.com/libp2p/go-libp2p-yamux"
+	"github.com/libp2p/go-tcp-transport"
+	"testing"
+)
+
+func TestConnUpgrades(t *testing.T) {
+	cases := []struct {
+		security, muxer string
+		opts            []libp2p.Option
+	}{
+		{"noise", "yamux", []libp2p.Option{libp2p.Security(noise.ID, noise.New),
+			libp2p.Muxer("/yamux/1.0.0", yamux.DefaultTransport)}},
+		{"secio", "mplex", []libp2p.Option{libp2p.Security(secio.ID, secio.New),
+			libp2p.Muxer("/mplex/6.7.0", mplex.DefaultTransport)}},
+	}
+	for _, c := range cases {
+		t.Run(c.security+"_"+c.muxer, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			newHost := func() host.Host {
+				opts := append([]libp2p.Option{libp2p.Transport(tcp.NewTCPTransport),
+					libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0")}, c.opts...)
+				h, err := libp2p.New(ctx, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return h
+			}
+			a, b := newHost(), newHost()
+			defer a.Close()
+			defer b.Close()
+			if err := a.Connect(ctx, peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}); err != nil {
+				t.Fatal(err)
+			}
+			for _, h := range []host.Host{a, b} {
+				conns := h.Network().Conns()
+				if len(conns) != 1 {
+					t.Fatalf("expected 1 connection, got %d", len(conns))
+				}
+				security, muxer := ConnUpgrades(conns[0])
+				if security != c.security || muxer != c.muxer {
+					t.Fatalf("expected %s and %s, got %q and %q", c.security, c.muxer, security, muxer)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package nettrace
+
+import (
+	"context"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/mux"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"strings"
+	"sync"
+)
+
+type tracedHost struct {
+	host.Host
+	tracer *Tracer
+}
+
+// WrapHost wraps the host, to trace the protocol negotiation of outbound streams,
+// the protocols of inbound streams, and stream resets.
+// Rejected proposals of inbound streams are handled by libp2p before the stream reaches the host, and cannot be traced.
+func (t *Tracer) WrapHost(h host.Host) host.Host {
+	return &tracedHost{Host: h, tracer: t}
+}
+
+func isUnsupported(err error) bool {
+	return strings.Contains(err.Error(), "protocol not supported")
+}
+
+func (h *tracedHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	if !h.tracer.active() {
+		return h.Host.NewStream(ctx, p, pids...)
+	}
+	proposed := make([]string, len(pids))
+	for i, pid := range pids {
+		proposed[i] = string(pid)
+	}
+	// The host skips negotiation of alternatives if the peer is known to support one of the protocols:
+	// the first supported protocol is then selected, and only confirmed by the peer when the stream is used.
+	supported, _ := h.Peerstore().SupportsProtocols(p, proposed...)
+	lazy := len(supported) > 0
+	if lazy {
+		proposed = supported[:1]
+	}
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		evt := &Event{Type: NegotiationFailed, Peer: p, Direction: "outbound", Proposed: proposed, Lazy: lazy,
+			Error: err.Error()}
+		if isUnsupported(err) {
+			evt.Rejected = proposed
+		}
+		h.tracer.Trace(evt)
+		return nil, err
+	}
+	selected := string(s.Protocol())
+	var rejected []string
+	if !lazy {
+		// proposals are tried in order, until the peer accepts one
+		for _, pid := range proposed {
+			if pid == selected {
+				break
+			}
+			rejected = append(rejected, pid)
+		}
+	}
+	h.tracer.Trace(&Event{Type: Negotiated, Peer: p, Direction: "outbound", Addr: s.Conn().RemoteMultiaddr().String(),
+		Proposed: proposed, Rejected: rejected, Protocol: selected, Lazy: lazy})
+	return &tracedStream{Stream: s, tracer: h.tracer, direction: "outbound", lazy: lazy}, nil
+}
+
+func (h *tracedHost) wrapHandler(handler network.StreamHandler) network.StreamHandler {
+	return func(s network.Stream) {
+		if !h.tracer.active() {
+			handler(s)
+			return
+		}
+		h.tracer.Trace(&Event{Type: Negotiated, Peer: s.Conn().RemotePeer(), Direction: "inbound",
+			Addr: s.Conn().RemoteMultiaddr().String(), Protocol: string(s.Protocol())})
+		handler(&tracedStream{Stream: s, tracer: h.tracer, direction: "inbound"})
+	}
+}
+
+func (h *tracedHost) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	h.Host.SetStreamHandler(pid, h.wrapHandler(handler))
+}
+
+func (h *tracedHost) SetStreamHandlerMatch(pid protocol.ID, match func(string) bool, handler network.StreamHandler) {
+	h.Host.SetStreamHandlerMatch(pid, match, h.wrapHandler(handler))
+}
+
+// tracedStream traces stream resets, and the rejection of lazily negotiated protocols.
+// The libp2p version of rumor does not support reset error codes, the error is traced instead.
+type tracedStream struct {
+	network.Stream
+	tracer    *Tracer
+	direction string
+	lazy      bool
+
+	once sync.Once
+}
+
+func (s *tracedStream) event(typ EventType) *Event {
+	return &Event{Type: typ, Peer: s.Conn().RemotePeer(), Direction: s.direction, Protocol: string(s.Protocol())}
+}
+
+// onErr traces the first read or write error that is a reset or a rejection.
+func (s *tracedStream) onErr(err error) {
+	if err == nil {
+		return
+	}
+	if err == mux.ErrReset {
+		s.once.Do(func() {
+			evt := s.event(StreamReset)
+			evt.ResetBy = "remote"
+			evt.Error = err.Error()
+			s.tracer.Trace(evt)
+		})
+	} else if s.lazy && isUnsupported(err) {
+		s.once.Do(func() {
+			evt := s.event(NegotiationFailed)
+			evt.Proposed = []string{evt.Protocol}
+			evt.Rejected = evt.Proposed
+			evt.Lazy = true
+			evt.Error = err.Error()
+			s.tracer.Trace(evt)
+		})
+	}
+}
+
+func (s *tracedStream) Read(p []byte) (n int, err error) {
+	n, err = s.Stream.Read(p)
+	s.onErr(err)
+	return
+}
+
+func (s *tracedStream) Write(p []byte) (n int, err error) {
+	n, err = s.Stream.Write(p)
+	s.onErr(err)
+	return
+}
+
+func (s *tracedStream) Reset() error {
+	s.once.Do(func() {
+		evt := s.event(StreamReset)
+		evt.ResetBy = "local"
+		s.tracer.Trace(evt)
+	})
+	return s.Stream.Reset()
+}
@@ -0,0 +1,95 @@
+package nettrace
+
+import (
+	"context"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-tcp-transport"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTracedHostNewStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	newHost := func() host.Host {
+		h, err := libp2p.New(ctx, libp2p.Transport(tcp.NewTCPTransport),
+			libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	tracer := NewTracer()
+	var lock sync.Mutex
+	var events []*Event
+	tracer.AddListener("test", func(evt *Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, evt)
+	})
+	takeEvents := func() []*Event {
+		lock.Lock()
+		defer lock.Unlock()
+		out := events
+		events = nil
+		return out
+	}
+
+	a, b := newHost(), newHost()
+	defer a.Close()
+	defer b.Close()
+	ta := tracer.WrapHost(a)
+	const supported, unsupported = "/test/supported/1", "/test/unsupported/1"
+	b.SetStreamHandler(supported, func(s network.Stream) {
+		_, _ = ioutil.ReadAll(s)
+		_ = s.Close()
+	})
+	if err := a.Connect(ctx, peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	// wait for identify, then forget the protocols of b, so the proposals are negotiated one by one
+	for i := 0; ; i++ {
+		if protocols, _ := a.Peerstore().SupportsProtocols(b.ID(), supported); len(protocols) > 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatal("identify did not complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := a.Peerstore().SetProtocols(b.ID()); err != nil {
+		t.Fatal(err)
+	}
+	takeEvents()
+
+	if _, err := ta.NewStream(ctx, b.ID(), unsupported); err == nil {
+		t.Fatal("expected negotiation of unsupported protocol to fail")
+	}
+	evts := takeEvents()
+	if len(evts) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(evts))
+	}
+	if evt := evts[0]; evt.Type != NegotiationFailed || evt.Peer != b.ID() || evt.Lazy ||
+		len(evt.Rejected) != 1 || evt.Rejected[0] != unsupported {
+		t.Fatalf("unexpected event: %+v", evt)
+	}
+
+	s, err := ta.NewStream(ctx, b.ID(), unsupported, supported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	evts = takeEvents()
+	if len(evts) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(evts))
+	}
+	if evt := evts[0]; evt.Type != Negotiated || evt.Peer != b.ID() || evt.Protocol != supported || evt.Lazy ||
+		len(evt.Proposed) != 2 || len(evt.Rejected) != 1 || evt.Rejected[0] != unsupported {
+		t.Fatalf("unexpected event: %+v", evt)
+	}
+}
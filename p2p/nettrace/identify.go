@@ -0,0 +1,55 @@
+package nettrace
+
+import (
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"io"
+)
+
+// WatchIdentify traces the identify results of the host, until the returned closer is closed.
+func (t *Tracer) WatchIdentify(h host.Host) (io.Closer, error) {
+	sub, err := h.EventBus().Subscribe([]interface{}{
+		new(event.EvtPeerIdentificationCompleted), new(event.EvtPeerIdentificationFailed)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to identify events: %v", err)
+	}
+	go func() {
+		for ev := range sub.Out() {
+			if !t.active() {
+				continue
+			}
+			switch ev := ev.(type) {
+			case event.EvtPeerIdentificationCompleted:
+				t.Trace(identified(h, ev.Peer))
+			case event.EvtPeerIdentificationFailed:
+				evt := &Event{Type: IdentifyFailed, Peer: ev.Peer}
+				if ev.Reason != nil {
+					evt.Error = ev.Reason.Error()
+				}
+				t.Trace(evt)
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// identified creates an event with the identify results, as stored in the peerstore by the identify service.
+func identified(h host.Host, id peer.ID) *Event {
+	evt := &Event{Type: Identified, Peer: id}
+	ps := h.Peerstore()
+	if v, err := ps.Get(id, "AgentVersion"); err == nil {
+		evt.AgentVersion, _ = v.(string)
+	}
+	if v, err := ps.Get(id, "ProtocolVersion"); err == nil {
+		evt.ProtocolVersion, _ = v.(string)
+	}
+	if protocols, err := ps.GetProtocols(id); err == nil {
+		evt.Protocols = protocols
+	}
+	for _, addr := range ps.Addrs(id) {
+		evt.ListenAddrs = append(evt.ListenAddrs, addr.String())
+	}
+	return evt
+}
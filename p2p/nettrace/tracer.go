@@ -0,0 +1,109 @@
+package nettrace
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	Connected    EventType = "connected"
+	Disconnected EventType = "disconnected"
+	// A stream protocol was selected with multistream-select
+	Negotiated EventType = "negotiated"
+	// None of the proposed protocols were accepted, or the stream failed during negotiation
+	NegotiationFailed EventType = "negotiation_failed"
+	StreamReset       EventType = "stream_reset"
+	Identified        EventType = "identified"
+	IdentifyFailed    EventType = "identify_failed"
+)
+
+// Event is a network trace event. Only the fields relevant to the event type are set.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Peer peer.ID   `json:"peer"`
+	// "inbound" or "outbound", of the connection or stream
+	Direction string `json:"direction,omitempty"`
+	// Remote address of the connection
+	Addr string `json:"addr,omitempty"`
+
+	// Security and muxer of the connection, inferred from their implementation, empty if unknown
+	Security string `json:"security,omitempty"`
+	Muxer    string `json:"muxer,omitempty"`
+
+	// Protocols we proposed for an outbound stream, in order of preference
+	Proposed []string `json:"proposed,omitempty"`
+	// Proposed protocols that the peer rejected
+	Rejected []string `json:"rejected,omitempty"`
+	// The selected protocol of the stream
+	Protocol string `json:"protocol,omitempty"`
+	// True if the protocol was optimistically selected, because identify listed it as supported.
+	// The peer only confirms or rejects it when the stream is first used.
+	Lazy bool `json:"lazy,omitempty"`
+	// Who reset the stream: "local" or "remote"
+	ResetBy string `json:"reset_by,omitempty"`
+
+	// Identify results
+	AgentVersion    string   `json:"agent_version,omitempty"`
+	ProtocolVersion string   `json:"protocol_version,omitempty"`
+	Protocols       []string `json:"protocols,omitempty"`
+	ListenAddrs     []string `json:"listen_addrs,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// TraceListener receives network trace events. Listeners are called synchronously, and should not block.
+type TraceListener func(evt *Event)
+
+// Tracer multiplexes network trace events to any registered listeners.
+type Tracer struct {
+	lock      sync.RWMutex
+	listeners map[string]TraceListener
+}
+
+func NewTracer() *Tracer {
+	return &Tracer{listeners: make(map[string]TraceListener)}
+}
+
+// AddListener registers a listener by key. It returns false if the key is already taken.
+func (t *Tracer) AddListener(key string, listener TraceListener) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.listeners[key]; ok {
+		return false
+	}
+	t.listeners[key] = listener
+	return true
+}
+
+func (t *Tracer) RemoveListener(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.listeners, key)
+}
+
+func (t *Tracer) active() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return len(t.listeners) > 0
+}
+
+// Trace calls the listeners with the event. The listeners are called outside of the lock,
+// so they can add or remove listeners.
+func (t *Tracer) Trace(evt *Event) {
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+	t.lock.RLock()
+	listeners := make([]TraceListener, 0, len(t.listeners))
+	for _, l := range t.listeners {
+		listeners = append(listeners, l)
+	}
+	t.lock.RUnlock()
+	for _, l := range listeners {
+		l(evt)
+	}
+}
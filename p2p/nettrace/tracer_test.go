@@ -0,0 +1,17 @@
+package nettrace
+
+import "testing"
+
+func TestTracerListenerRemovesItself(t *testing.T) {
+	tracer := NewTracer()
+	calls := 0
+	tracer.AddListener("once", func(evt *Event) {
+		calls++
+		tracer.RemoveListener("once")
+	})
+	tracer.Trace(&Event{Type: Connected})
+	tracer.Trace(&Event{Type: Connected})
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
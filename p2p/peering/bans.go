@@ -0,0 +1,106 @@
+package peering
+
+import (
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Ban struct {
+	Peer   peer.ID   `json:"peer"`
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
+	// Zero if the ban does not expire
+	Until time.Time `json:"until,omitempty"`
+}
+
+func (b *Ban) Expired(now time.Time) bool {
+	return !b.Until.IsZero() && !now.Before(b.Until)
+}
+
+// BanList keeps banned peers, bans expire lazily. Safe for concurrent use.
+type BanList struct {
+	lock sync.Mutex
+	bans map[peer.ID]*Ban
+}
+
+var _ ConnectionGater = (*BanList)(nil)
+
+func NewBanList() *BanList {
+	return &BanList{bans: make(map[peer.ID]*Ban)}
+}
+
+// Add bans the peer for the given duration, or without expiry if 0. An existing ban is replaced.
+func (bl *BanList) Add(id peer.ID, reason string, dur time.Duration) *Ban {
+	now := time.Now()
+	b := &Ban{Peer: id, Reason: reason, Since: now}
+	if dur != 0 {
+		b.Until = now.Add(dur)
+	}
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	bl.bans[id] = b
+	return b
+}
+
+// Remove the ban of the peer, returns false if the peer was not banned.
+func (bl *BanList) Remove(id peer.ID) bool {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	b, ok := bl.bans[id]
+	delete(bl.bans, id)
+	return ok && !b.Expired(time.Now())
+}
+
+// Banned returns the ban of the peer, if it is banned.
+func (bl *BanList) Banned(id peer.ID) (Ban, bool) {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	b, ok := bl.bans[id]
+	if !ok {
+		return Ban{}, false
+	}
+	if b.Expired(time.Now()) {
+		delete(bl.bans, id)
+		return Ban{}, false
+	}
+	return *b, true
+}
+
+// List the current bans, by time of banning.
+func (bl *BanList) List() []Ban {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	now := time.Now()
+	out := make([]Ban, 0, len(bl.bans))
+	for id, b := range bl.bans {
+		if b.Expired(now) {
+			delete(bl.bans, id)
+			continue
+		}
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Since.Before(out[j].Since) })
+	return out
+}
+
+func (bl *BanList) InterceptPeerDial(p peer.ID) bool {
+	_, banned := bl.Banned(p)
+	return !banned
+}
+
+func (bl *BanList) InterceptAddrDial(p peer.ID, addr ma.Multiaddr) bool {
+	return true
+}
+
+func (bl *BanList) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (bl *BanList) InterceptSecured(dir network.Direction, p peer.ID, addrs network.ConnMultiaddrs) bool {
+	_, banned := bl.Banned(p)
+	return !banned
+}
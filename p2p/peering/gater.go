@@ -0,0 +1,83 @@
+package peering
+
+import (
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// ConnectionGater is shaped like the connection gater of newer libp2p versions.
+// The libp2p version of rumor has no gater option, so rumor enforces the gater in its transports, see GatedTransport.
+type ConnectionGater interface {
+	// InterceptPeerDial tests whether we may dial the peer
+	InterceptPeerDial(p peer.ID) (allow bool)
+	// InterceptAddrDial tests whether we may dial the peer on the address
+	InterceptAddrDial(p peer.ID, addr ma.Multiaddr) (allow bool)
+	// InterceptAccept tests whether an inbound connection from the address may stay open
+	InterceptAccept(addrs network.ConnMultiaddrs) (allow bool)
+	// InterceptSecured tests whether a connection with the authenticated peer may stay open
+	InterceptSecured(dir network.Direction, p peer.ID, addrs network.ConnMultiaddrs) (allow bool)
+}
+
+// Gaters combines gaters, all of them must allow a connection.
+type Gaters []ConnectionGater
+
+func (gs Gaters) InterceptPeerDial(p peer.ID) bool {
+	for _, g := range gs {
+		if !g.InterceptPeerDial(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (gs Gaters) InterceptAddrDial(p peer.ID, addr ma.Multiaddr) bool {
+	for _, g := range gs {
+		if !g.InterceptAddrDial(p, addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (gs Gaters) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	for _, g := range gs {
+		if !g.InterceptAccept(addrs) {
+			return false
+		}
+	}
+	return true
+}
+
+func (gs Gaters) InterceptSecured(dir network.Direction, p peer.ID, addrs network.ConnMultiaddrs) bool {
+	for _, g := range gs {
+		if !g.InterceptSecured(dir, p, addrs) {
+			return false
+		}
+	}
+	return true
+}
+
+// GaterNotifiee closes every new connection that the gater does not allow, after the connection is set up.
+// This is a fallback for connections of transports that are not gated, e.g. the relay transport.
+// The onDeny callback, if not nil, is called with every denied connection, right before it is closed.
+func GaterNotifiee(gater ConnectionGater, onDeny func(conn network.Conn)) network.Notifiee {
+	return &network.NotifyBundle{
+		ConnectedF: func(net network.Network, conn network.Conn) {
+			dir := conn.Stat().Direction
+			allow := gater.InterceptSecured(dir, conn.RemotePeer(), conn)
+			if allow && dir == network.DirInbound {
+				allow = gater.InterceptAccept(conn)
+			}
+			if !allow {
+				// close asynchronously, not while the network is notifying
+				go func() {
+					if onDeny != nil {
+						onDeny(conn)
+					}
+					_ = conn.Close()
+				}()
+			}
+		},
+	}
+}
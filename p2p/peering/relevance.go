@@ -0,0 +1,112 @@
+package peering
+
+import (
+	"fmt"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/types"
+	"github.com/protolambda/zrnt/eth2/beacon"
+)
+
+// RelevanceRules decide if a peer is relevant to stay connected to, based on our own status.
+type RelevanceRules struct {
+	// Our current status, the status rules are skipped if nil
+	Local *methods.Status
+	// Fork digest peers must be on. Our status fork digest is used if nil.
+	ForkDigest *beacon.ForkDigest
+	// Max number of slots the head of a peer may be behind ours. 0 to disable.
+	MaxBehind beacon.Slot
+}
+
+func (r *RelevanceRules) forkDigest() (beacon.ForkDigest, bool) {
+	if r.ForkDigest != nil {
+		return *r.ForkDigest, true
+	}
+	if r.Local != nil {
+		return r.Local.ForkDigest, true
+	}
+	return beacon.ForkDigest{}, false
+}
+
+// Check returns a reason why the peer is irrelevant, or an empty string if it is relevant.
+// Rules on data the peer does not have yet, e.g. a status, are skipped.
+// The status is preferred over the ENR, it is more recent.
+func (r *RelevanceRules) Check(p *track.PeerAllData) string {
+	if digest, ok := r.forkDigest(); ok {
+		if p.Status != nil {
+			if p.Status.ForkDigest != digest {
+				return fmt.Sprintf("wrong fork digest in status: %s", p.Status.ForkDigest)
+			}
+		} else if p.ForkDigest != nil && *p.ForkDigest != digest {
+			return fmt.Sprintf("wrong fork digest in ENR: %s", *p.ForkDigest)
+		}
+	}
+	if r.Local == nil || p.Status == nil {
+		return ""
+	}
+	local, remote := r.Local, p.Status
+	// Only a finalized checkpoint at the same epoch can be compared without the chain history
+	if local.FinalizedEpoch == remote.FinalizedEpoch && local.FinalizedEpoch != 0 &&
+		local.FinalizedRoot != remote.FinalizedRoot {
+		return fmt.Sprintf("conflicting finalized checkpoint at epoch %d: %s", remote.FinalizedEpoch, remote.FinalizedRoot)
+	}
+	if r.MaxBehind != 0 && local.HeadSlot > remote.HeadSlot && local.HeadSlot-remote.HeadSlot > r.MaxBehind {
+		return fmt.Sprintf("head slot %d too far behind ours, %d", remote.HeadSlot, local.HeadSlot)
+	}
+	return ""
+}
+
+// Attnets returns the attnets of the peer, from the metadata if available, the ENR otherwise.
+func Attnets(p *track.PeerAllData) *types.AttnetBits {
+	if p.MetaData != nil {
+		return &p.MetaData.Attnets
+	}
+	return p.Attnets
+}
+
+// CheckSubnets checks that the subnets are valid attestation subnets.
+func CheckSubnets(subnets []uint64) error {
+	for _, subnet := range subnets {
+		if subnet >= types.ATTESTATION_SUBNET_COUNT {
+			return fmt.Errorf("subnet %d out of range, there are %d subnets", subnet, types.ATTESTATION_SUBNET_COUNT)
+		}
+	}
+	return nil
+}
+
+// SubnetScore counts how many of the needed subnets the peer covers.
+// A subnet that is covered by fewer peers than wanted counts double.
+func SubnetScore(p *track.PeerAllData, needed []uint64, coverage map[uint64]int, wanted int) int {
+	attnets := Attnets(p)
+	if attnets == nil {
+		return 0
+	}
+	score := 0
+	for _, subnet := range needed {
+		if attnets[subnet/8]&(1<<(subnet%8)) == 0 {
+			continue
+		}
+		score += 1
+		if coverage[subnet] < wanted {
+			score += 1
+		}
+	}
+	return score
+}
+
+// Coverage counts the peers per needed subnet.
+func Coverage(peers []*track.PeerAllData, needed []uint64) map[uint64]int {
+	out := make(map[uint64]int, len(needed))
+	for _, p := range peers {
+		attnets := Attnets(p)
+		if attnets == nil {
+			continue
+		}
+		for _, subnet := range needed {
+			if attnets[subnet/8]&(1<<(subnet%8)) != 0 {
+				out[subnet] += 1
+			}
+		}
+	}
+	return out
+}
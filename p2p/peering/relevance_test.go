@@ -0,0 +1,71 @@
+package peering
+
+import (
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/types"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"testing"
+)
+
+func TestRelevanceRules(t *testing.T) {
+	digest := beacon.ForkDigest{0xe7, 0xa7, 0x5d, 0x5a}
+	other := beacon.ForkDigest{1, 2, 3, 4}
+	local := &methods.Status{ForkDigest: digest, FinalizedEpoch: 10, FinalizedRoot: beacon.Root{1}, HeadSlot: 400}
+	rules := &RelevanceRules{Local: local, MaxBehind: 64}
+	for name, tc := range map[string]struct {
+		peer     track.PeerAllData
+		relevant bool
+	}{
+		"no data":             {track.PeerAllData{}, true},
+		"enr digest":          {track.PeerAllData{ForkDigest: &digest}, true},
+		"wrong enr digest":    {track.PeerAllData{ForkDigest: &other}, false},
+		"status over enr":     {track.PeerAllData{ForkDigest: &other, Status: &methods.Status{ForkDigest: digest, HeadSlot: 400}}, true},
+		"wrong status digest": {track.PeerAllData{Status: &methods.Status{ForkDigest: other, HeadSlot: 400}}, false},
+		"conflicting finality": {track.PeerAllData{Status: &methods.Status{ForkDigest: digest,
+			FinalizedEpoch: 10, FinalizedRoot: beacon.Root{2}, HeadSlot: 400}}, false},
+		"older finality":  {track.PeerAllData{Status: &methods.Status{ForkDigest: digest, FinalizedEpoch: 9, HeadSlot: 400}}, true},
+		"slightly behind": {track.PeerAllData{Status: &methods.Status{ForkDigest: digest, HeadSlot: 340}}, true},
+		"too far behind":  {track.PeerAllData{Status: &methods.Status{ForkDigest: digest, HeadSlot: 300}}, false},
+	} {
+		if reason := rules.Check(&tc.peer); (reason == "") != tc.relevant {
+			t.Errorf("%s: expected relevant=%v, got reason %q", name, tc.relevant, reason)
+		}
+	}
+}
+
+func TestCheckSubnets(t *testing.T) {
+	if err := CheckSubnets([]uint64{0, types.ATTESTATION_SUBNET_COUNT - 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSubnets([]uint64{3, types.ATTESTATION_SUBNET_COUNT}); err == nil {
+		t.Fatal("expected out of range subnet to fail")
+	}
+}
+
+func TestSubnetScore(t *testing.T) {
+	needed := []uint64{1, 9}
+	var both, one types.AttnetBits
+	both[0], both[1] = 0b10, 0b10
+	one[1] = 0b10
+	peers := []*track.PeerAllData{
+		{MetaData: &methods.MetaData{Attnets: both}},
+		// metadata is preferred over the ENR
+		{Attnets: &both, MetaData: &methods.MetaData{Attnets: one}},
+		{},
+	}
+	coverage := Coverage(peers, needed)
+	if coverage[1] != 1 || coverage[9] != 2 {
+		t.Fatalf("unexpected coverage: %v", coverage)
+	}
+	// subnet 1 is covered by fewer peers than wanted, and counts double
+	if score := SubnetScore(peers[0], needed, coverage, 2); score != 3 {
+		t.Fatalf("unexpected score: %d", score)
+	}
+	if score := SubnetScore(peers[1], needed, coverage, 2); score != 1 {
+		t.Fatalf("unexpected score: %d", score)
+	}
+	if score := SubnetScore(peers[2], needed, coverage, 2); score != 0 {
+		t.Fatalf("unexpected score of peer without attnets: %d", score)
+	}
+}
@@ -0,0 +1,290 @@
+package peering
+
+import (
+	"errors"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RuleKind is what a gate rule matches on.
+type RuleKind string
+
+const (
+	// Matches the peer ID
+	RulePeer RuleKind = "peer"
+	// Matches the IP of the address, with a single IP or a CIDR range
+	RuleIP RuleKind = "ip"
+	// Matches a protocol in the address, e.g. "tcp", "ws" or "ip6"
+	RuleProto RuleKind = "proto"
+)
+
+type GateRule struct {
+	Allow bool     `json:"allow"`
+	Kind  RuleKind `json:"kind"`
+	Value string   `json:"value"`
+}
+
+func (r GateRule) String() string {
+	if r.Allow {
+		return fmt.Sprintf("allow %s %s", r.Kind, r.Value)
+	}
+	return fmt.Sprintf("deny %s %s", r.Kind, r.Value)
+}
+
+type gateList struct {
+	peers  map[peer.ID]string
+	nets   map[string]*net.IPNet
+	protos map[string]struct{}
+}
+
+func newGateList() gateList {
+	return gateList{
+		peers:  make(map[peer.ID]string),
+		nets:   make(map[string]*net.IPNet),
+		protos: make(map[string]struct{}),
+	}
+}
+
+// GateRules allows and denies connections by peer ID, IP and address protocol. Safe for concurrent use.
+//
+// Deny rules take precedence over allow rules. For each kind of rule, once there is an allow rule,
+// only matching connections are allowed: the peer ID must be allowed, the IP must be in an allowed range,
+// and the address must contain an allowed protocol. Addresses without IP, e.g. DNS addresses,
+// do not match any IP rule.
+//
+// The rules are enforced while connections are set up, by the transports of GatedTransport.
+// Changes do not affect connections that are already open, these are checked with Check.
+type GateRules struct {
+	lock  sync.RWMutex
+	allow gateList
+	deny  gateList
+}
+
+var _ ConnectionGater = (*GateRules)(nil)
+
+func NewGateRules() *GateRules {
+	return &GateRules{allow: newGateList(), deny: newGateList()}
+}
+
+func parseIPNet(v string) (*net.IPNet, error) {
+	if strings.Contains(v, "/") {
+		_, ipNet, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %v", err)
+		}
+		return ipNet, nil
+	}
+	ip := net.ParseIP(v)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP: %q", v)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func (g *GateRules) list(allow bool) *gateList {
+	if allow {
+		return &g.allow
+	}
+	return &g.deny
+}
+
+// Add a rule. The value is validated, and normalized in the returned rule.
+func (g *GateRules) Add(rule GateRule) (GateRule, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	l := g.list(rule.Allow)
+	switch rule.Kind {
+	case RulePeer:
+		id, err := peer.Decode(rule.Value)
+		if err != nil {
+			return rule, fmt.Errorf("invalid peer ID: %v", err)
+		}
+		rule.Value = id.Pretty()
+		l.peers[id] = rule.Value
+	case RuleIP:
+		ipNet, err := parseIPNet(rule.Value)
+		if err != nil {
+			return rule, err
+		}
+		rule.Value = ipNet.String()
+		l.nets[rule.Value] = ipNet
+	case RuleProto:
+		if p := ma.ProtocolWithName(rule.Value); p.Code == 0 {
+			return rule, fmt.Errorf("unknown multiaddr protocol: %q", rule.Value)
+		}
+		l.protos[rule.Value] = struct{}{}
+	default:
+		return rule, fmt.Errorf("unknown rule kind: %q", rule.Kind)
+	}
+	return rule, nil
+}
+
+// Remove a rule, returns false if there was no such rule.
+func (g *GateRules) Remove(rule GateRule) (bool, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	l := g.list(rule.Allow)
+	switch rule.Kind {
+	case RulePeer:
+		id, err := peer.Decode(rule.Value)
+		if err != nil {
+			return false, fmt.Errorf("invalid peer ID: %v", err)
+		}
+		_, ok := l.peers[id]
+		delete(l.peers, id)
+		return ok, nil
+	case RuleIP:
+		ipNet, err := parseIPNet(rule.Value)
+		if err != nil {
+			return false, err
+		}
+		_, ok := l.nets[ipNet.String()]
+		delete(l.nets, ipNet.String())
+		return ok, nil
+	case RuleProto:
+		_, ok := l.protos[rule.Value]
+		delete(l.protos, rule.Value)
+		return ok, nil
+	default:
+		return false, fmt.Errorf("unknown rule kind: %q", rule.Kind)
+	}
+}
+
+// Clear removes all rules.
+func (g *GateRules) Clear() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.allow = newGateList()
+	g.deny = newGateList()
+}
+
+// Rules lists the current rules, allow rules first.
+func (g *GateRules) Rules() []GateRule {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	var out []GateRule
+	for _, allow := range []bool{true, false} {
+		l := g.list(allow)
+		var rules []GateRule
+		for _, v := range l.peers {
+			rules = append(rules, GateRule{Allow: allow, Kind: RulePeer, Value: v})
+		}
+		for v := range l.nets {
+			rules = append(rules, GateRule{Allow: allow, Kind: RuleIP, Value: v})
+		}
+		for v := range l.protos {
+			rules = append(rules, GateRule{Allow: allow, Kind: RuleProto, Value: v})
+		}
+		sort.Slice(rules, func(i, j int) bool {
+			if rules[i].Kind != rules[j].Kind {
+				return rules[i].Kind < rules[j].Kind
+			}
+			return rules[i].Value < rules[j].Value
+		})
+		out = append(out, rules...)
+	}
+	return out
+}
+
+func (g *GateRules) checkPeer(p peer.ID) error {
+	if _, ok := g.deny.peers[p]; ok {
+		return errors.New("peer is denied")
+	}
+	if _, ok := g.allow.peers[p]; len(g.allow.peers) > 0 && !ok {
+		return errors.New("peer is not allowed")
+	}
+	return nil
+}
+
+func addrIP(addr ma.Multiaddr) (ip net.IP) {
+	ma.ForEach(addr, func(c ma.Component) bool {
+		switch c.Protocol().Code {
+		case ma.P_IP4, ma.P_IP6:
+			ip = net.IP(c.RawValue())
+			return false
+		}
+		return true
+	})
+	return ip
+}
+
+func (g *GateRules) checkAddr(addr ma.Multiaddr) error {
+	ip := addrIP(addr)
+	if ip != nil {
+		for _, ipNet := range g.deny.nets {
+			if ipNet.Contains(ip) {
+				return fmt.Errorf("IP %s is denied", ip)
+			}
+		}
+	}
+	if len(g.allow.nets) > 0 {
+		allowed := false
+		if ip != nil {
+			for _, ipNet := range g.allow.nets {
+				if ipNet.Contains(ip) {
+					allowed = true
+					break
+				}
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("IP of address %s is not allowed", addr)
+		}
+	}
+	allowedProto := len(g.allow.protos) == 0
+	for _, p := range addr.Protocols() {
+		if _, ok := g.deny.protos[p.Name]; ok {
+			return fmt.Errorf("protocol %s is denied", p.Name)
+		}
+		if _, ok := g.allow.protos[p.Name]; ok {
+			allowedProto = true
+		}
+	}
+	if !allowedProto {
+		return fmt.Errorf("address %s has no allowed protocol", addr)
+	}
+	return nil
+}
+
+// Check returns why the connection with the peer on the address would be denied, or nil if it is allowed.
+// An empty peer ID or nil address is not checked.
+func (g *GateRules) Check(p peer.ID, addr ma.Multiaddr) error {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	if p != "" {
+		if err := g.checkPeer(p); err != nil {
+			return err
+		}
+	}
+	if addr != nil {
+		if err := g.checkAddr(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GateRules) InterceptPeerDial(p peer.ID) bool {
+	return g.Check(p, nil) == nil
+}
+
+func (g *GateRules) InterceptAddrDial(p peer.ID, addr ma.Multiaddr) bool {
+	return g.Check(p, addr) == nil
+}
+
+func (g *GateRules) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	return g.Check("", addrs.RemoteMultiaddr()) == nil
+}
+
+func (g *GateRules) InterceptSecured(dir network.Direction, p peer.ID, addrs network.ConnMultiaddrs) bool {
+	return g.Check(p, addrs.RemoteMultiaddr()) == nil
+}
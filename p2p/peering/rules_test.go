@@ -0,0 +1,57 @@
+package peering
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"testing"
+)
+
+func TestGateRules(t *testing.T) {
+	g := NewGateRules()
+	a, _ := peer.Decode("16Uiu2HAmVAhHhm5mLznuXqyNpkQVyRybvfSChY8uZHVukGJZXLyL")
+	b, _ := peer.Decode("16Uiu2HAm62YMcwJfUruf3BeyCfSyk4t9bzJsQzMe2mwxCt6RLoAq")
+	local := ma.StringCast("/ip4/10.0.1.2/tcp/9000")
+	public := ma.StringCast("/ip4/1.2.3.4/tcp/9000")
+	ws := ma.StringCast("/ip4/10.0.1.2/tcp/9000/ws")
+	dns := ma.StringCast("/dns4/example.com/tcp/9000")
+
+	if g.Check(a, public) != nil || g.Check(b, dns) != nil {
+		t.Fatal("expected everything to be allowed without rules")
+	}
+	for _, r := range []GateRule{
+		{Allow: true, Kind: RuleIP, Value: "10.0.0.0/8"},
+		{Allow: false, Kind: RuleProto, Value: "ws"},
+		{Allow: false, Kind: RulePeer, Value: b.Pretty()},
+	} {
+		if _, err := g.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, tc := range map[string]struct {
+		p       peer.ID
+		addr    ma.Multiaddr
+		allowed bool
+	}{
+		"allowed ip":     {a, local, true},
+		"other ip":       {a, public, false},
+		"no ip":          {a, dns, false},
+		"denied proto":   {a, ws, false},
+		"denied peer":    {b, local, false},
+		"peer only":      {a, nil, true},
+		"addr only":      {"", local, true},
+		"denied no addr": {b, nil, false},
+	} {
+		if allowed := g.Check(tc.p, tc.addr) == nil; allowed != tc.allowed {
+			t.Errorf("%s: expected allowed=%v", name, tc.allowed)
+		}
+	}
+	if ok, err := g.Remove(GateRule{Allow: true, Kind: RuleIP, Value: "10.0.0.0/8"}); err != nil || !ok {
+		t.Fatal("expected rule to be removed")
+	}
+	if g.Check(a, public) != nil {
+		t.Fatal("expected public IP to be allowed after removing the allow rule")
+	}
+	if _, err := g.Add(GateRule{Kind: RuleProto, Value: "nope"}); err == nil {
+		t.Fatal("expected unknown protocol to be rejected")
+	}
+}
@@ -0,0 +1,245 @@
+package peering
+
+import (
+	"errors"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/protolambda/rumor/p2p/gossip"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/track"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScoreParams configure the penalties and the ban threshold. Penalties are positive, and subtracted from the score.
+type ScoreParams struct {
+	// Failed request, or invalid response
+	RPCError float64 `json:"rpc_error"`
+	// Request that timed out
+	RPCTimeout float64 `json:"rpc_timeout"`
+	// Gossip message that was rejected by validation, or that had an invalid signature
+	InvalidGossip float64 `json:"invalid_gossip"`
+	// Received a goodbye with the fault_error reason
+	GoodbyeFault float64 `json:"goodbye_fault"`
+	// Received a goodbye with another reason
+	GoodbyeOther float64 `json:"goodbye_other"`
+	// Time for a score to decay halfway back to zero
+	HalfLife time.Duration `json:"half_life"`
+	// Peers with a score below the threshold are banned
+	BanThreshold float64 `json:"ban_threshold"`
+	// How long peers are banned for, 0 to ban without expiry
+	BanDuration time.Duration `json:"ban_duration"`
+}
+
+func DefaultScoreParams() ScoreParams {
+	return ScoreParams{
+		RPCError:      10,
+		RPCTimeout:    5,
+		InvalidGossip: 20,
+		GoodbyeFault:  20,
+		GoodbyeOther:  2,
+		HalfLife:      10 * time.Minute,
+		BanThreshold:  -100,
+		BanDuration:   time.Hour,
+	}
+}
+
+func (p *ScoreParams) Check() error {
+	if p.RPCError < 0 || p.RPCTimeout < 0 || p.InvalidGossip < 0 || p.GoodbyeFault < 0 || p.GoodbyeOther < 0 {
+		return errors.New("penalties must not be negative")
+	}
+	if p.HalfLife <= 0 {
+		return errors.New("half life must be positive")
+	}
+	if p.BanThreshold >= 0 {
+		return errors.New("ban threshold must be negative")
+	}
+	return nil
+}
+
+type PeerScore struct {
+	Peer  peer.ID `json:"peer"`
+	Score float64 `json:"score"`
+	// Penalty reason -> count
+	Penalties map[string]uint64 `json:"penalties"`
+}
+
+type peerScore struct {
+	value     float64
+	updated   time.Time
+	penalties map[string]uint64
+}
+
+// decay the score towards zero, exponentially with the half life.
+func (ps *peerScore) decay(now time.Time, halfLife time.Duration) {
+	if elapsed := now.Sub(ps.updated); elapsed > 0 {
+		ps.value *= math.Pow(0.5, float64(elapsed)/float64(halfLife))
+	}
+	ps.updated = now
+}
+
+// Scorer keeps a decaying score per peer, and bans peers that drop below the threshold.
+type Scorer struct {
+	lock   sync.Mutex
+	params ScoreParams
+	scores map[peer.ID]*peerScore
+	bans   *BanList
+	// called with every new ban, may be nil
+	onBan func(ban Ban)
+}
+
+func NewScorer(params ScoreParams, bans *BanList) *Scorer {
+	return &Scorer{params: params, scores: make(map[peer.ID]*peerScore), bans: bans}
+}
+
+// OnBan sets the callback for new bans, e.g. to disconnect the peer.
+func (s *Scorer) OnBan(fn func(ban Ban)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.onBan = fn
+}
+
+func (s *Scorer) Params() ScoreParams {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.params
+}
+
+func (s *Scorer) SetParams(params ScoreParams) error {
+	if err := params.Check(); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.params = params
+	return nil
+}
+
+// Penalize lowers the score of the peer, and bans the peer if its score drops below the threshold.
+func (s *Scorer) Penalize(id peer.ID, reason string, penalty float64) {
+	if penalty == 0 {
+		return
+	}
+	s.lock.Lock()
+	now := time.Now()
+	ps, ok := s.scores[id]
+	if !ok {
+		ps = &peerScore{updated: now, penalties: make(map[string]uint64)}
+		s.scores[id] = ps
+	}
+	ps.decay(now, s.params.HalfLife)
+	ps.value -= penalty
+	ps.penalties[reason] += 1
+	var ban *Ban
+	if ps.value < s.params.BanThreshold {
+		if _, banned := s.bans.Banned(id); !banned {
+			ban = s.bans.Add(id, fmt.Sprintf("score %.1f below threshold, last penalty: %s", ps.value, reason),
+				s.params.BanDuration)
+		}
+		// start over after the ban
+		delete(s.scores, id)
+	}
+	onBan := s.onBan
+	s.lock.Unlock()
+	if ban != nil && onBan != nil {
+		onBan(*ban)
+	}
+}
+
+// OnRPC is a track.RPCRecord callback, to penalize failed requests.
+// Cancellations and invalid requests made by us are not the fault of the peer.
+func (s *Scorer) OnRPC(rec *track.RPCRecord) {
+	class := rec.ErrClass
+	switch {
+	case class == "", strings.HasSuffix(class, "canceled"):
+		return
+	case !rec.Inbound && class == "invalid_request":
+		return
+	case strings.HasSuffix(class, "timeout"):
+		s.Penalize(rec.Peer, "rpc_timeout", s.Params().RPCTimeout)
+	default:
+		s.Penalize(rec.Peer, "rpc_"+class, s.Params().RPCError)
+	}
+}
+
+// OnValidation is a gossip ValidationListener, to penalize peers that propagated messages the validator rejected.
+// Ignored messages are not a fault of the peer.
+func (s *Scorer) OnValidation(from peer.ID, topic string, res gossip.ValidationResult, reason string) {
+	if res != gossip.ValidationReject {
+		return
+	}
+	s.Penalize(from, "invalid_gossip", s.Params().InvalidGossip)
+}
+
+// OnTrace is a gossip TraceListener, to penalize peers that propagated messages with an invalid signature.
+// Other rejections are not penalized here: failed validation covers ignored messages too,
+// and throttled validation is not a fault of the peer. See OnValidation for rejected messages.
+func (s *Scorer) OnTrace(evt *pubsub_pb.TraceEvent) {
+	if evt.GetType() != pubsub_pb.TraceEvent_REJECT_MESSAGE {
+		return
+	}
+	rej := evt.GetRejectMessage()
+	if rej.GetReason() != "invalid signature" {
+		return
+	}
+	s.Penalize(peer.ID(rej.GetReceivedFrom()), "invalid_signature", s.Params().InvalidGossip)
+}
+
+// OnGoodbye penalizes the peer for a received goodbye.
+func (s *Scorer) OnGoodbye(id peer.ID, reason methods.Goodbye) {
+	if reason == methods.GoodbyeFaultError {
+		s.Penalize(id, "goodbye_"+reason.Name(), s.Params().GoodbyeFault)
+	} else {
+		s.Penalize(id, "goodbye_"+reason.Name(), s.Params().GoodbyeOther)
+	}
+}
+
+// GoodbyeNotifiee penalizes peers that disconnect after sending a goodbye.
+func (s *Scorer) GoodbyeNotifiee(goodbyes track.GoodbyeBook) network.Notifiee {
+	return &network.NotifyBundle{
+		DisconnectedF: func(net network.Network, conn network.Conn) {
+			id := conn.RemotePeer()
+			if net.Connectedness(id) == network.Connected {
+				return
+			}
+			if gb := goodbyes.LastGoodbye(id); gb != nil && gb.Received && time.Since(gb.Time) < 10*time.Second {
+				s.OnGoodbye(id, gb.Reason)
+			}
+		},
+	}
+}
+
+// Score returns the current score of the peer, 0 if it has no penalties.
+func (s *Scorer) Score(id peer.ID) float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ps, ok := s.scores[id]
+	if !ok {
+		return 0
+	}
+	ps.decay(time.Now(), s.params.HalfLife)
+	return ps.value
+}
+
+// Scores returns the scores of all penalized peers, lowest score first.
+func (s *Scorer) Scores() []PeerScore {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	out := make([]PeerScore, 0, len(s.scores))
+	for id, ps := range s.scores {
+		ps.decay(now, s.params.HalfLife)
+		penalties := make(map[string]uint64, len(ps.penalties))
+		for k, v := range ps.penalties {
+			penalties[k] = v
+		}
+		out = append(out, PeerScore{Peer: id, Score: ps.value, Penalties: penalties})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Score < out[j].Score })
+	return out
+}
@@ -0,0 +1,80 @@
+package peering
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/protolambda/rumor/p2p/gossip"
+	"github.com/protolambda/rumor/p2p/track"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestScorerBan(t *testing.T) {
+	bans := NewBanList()
+	s := NewScorer(DefaultScoreParams(), bans)
+	id := peer.ID("bad")
+	var banned []Ban
+	s.OnBan(func(ban Ban) { banned = append(banned, ban) })
+
+	// not the fault of the peer
+	s.OnRPC(&track.RPCRecord{Peer: id, ErrClass: "canceled"})
+	s.OnRPC(&track.RPCRecord{Peer: id, ErrClass: "invalid_request"})
+	if score := s.Score(id); score != 0 {
+		t.Fatalf("expected no penalty, got score %f", score)
+	}
+	for i := 0; i < 10; i++ {
+		s.OnRPC(&track.RPCRecord{Peer: id, ErrClass: "server_error"})
+	}
+	if _, ok := bans.Banned(id); ok {
+		t.Fatal("peer at the threshold should not be banned yet")
+	}
+	s.OnRPC(&track.RPCRecord{Peer: id, ErrClass: "open_timeout"})
+	if _, ok := bans.Banned(id); !ok || len(banned) != 1 {
+		t.Fatal("expected peer to be banned")
+	}
+	if !bans.InterceptPeerDial("other") || bans.InterceptPeerDial(id) {
+		t.Fatal("gater should only deny the banned peer")
+	}
+	if !bans.Remove(id) || bans.Remove(id) {
+		t.Fatal("expected ban to be removed once")
+	}
+	bans.Add(id, "test", -time.Second)
+	if _, ok := bans.Banned(id); ok {
+		t.Fatal("expected ban to be expired")
+	}
+}
+
+func TestScoreDecay(t *testing.T) {
+	ps := &peerScore{value: -40, updated: time.Now()}
+	ps.decay(ps.updated.Add(2*time.Minute), time.Minute)
+	if ps.value != -10 {
+		t.Fatalf("expected score to decay to -10, got %f", ps.value)
+	}
+}
+
+func rejectEvent(from peer.ID, reason string) *pubsub_pb.TraceEvent {
+	return &pubsub_pb.TraceEvent{Type: pubsub_pb.TraceEvent_REJECT_MESSAGE.Enum(),
+		RejectMessage: &pubsub_pb.TraceEvent_RejectMessage{ReceivedFrom: []byte(from), Reason: &reason}}
+}
+
+func TestScorerGossip(t *testing.T) {
+	s := NewScorer(DefaultScoreParams(), NewBanList())
+	id := peer.ID("gossiper")
+	// validation failures are scored through the validation results, throttling is not a fault of the peer
+	s.OnTrace(rejectEvent(id, "validation failed"))
+	s.OnTrace(rejectEvent(id, "validation throttled"))
+	s.OnValidation(id, "beacon_block", gossip.ValidationIgnore, "block is too old")
+	s.OnValidation(id, "beacon_block", gossip.ValidationAccept, "")
+	if score := s.Score(id); score != 0 {
+		t.Fatalf("expected no penalty, got score %f", score)
+	}
+	s.OnValidation(id, "beacon_block", gossip.ValidationReject, "invalid proposer signature")
+	if score := s.Score(id); math.Abs(score+DefaultScoreParams().InvalidGossip) > 0.01 {
+		t.Fatalf("expected penalty for rejected message, got score %f", score)
+	}
+	s.OnTrace(rejectEvent(id, "invalid signature"))
+	if score := s.Score(id); math.Abs(score+2*DefaultScoreParams().InvalidGossip) > 0.01 {
+		t.Fatalf("expected penalty for invalid signature, got score %f", score)
+	}
+}
@@ -0,0 +1,86 @@
+package peering
+
+import (
+	"context"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/transport"
+	tptu "github.com/libp2p/go-libp2p-transport-upgrader"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// TransportConstructor creates a transport that upgrades its connections with the given upgrader.
+type TransportConstructor func(u *tptu.Upgrader) transport.Transport
+
+// GatedTransport wraps a transport constructor, to enforce the gater while connections are set up:
+// dials are checked before they start, and upgraded connections are checked before they are handed to the swarm.
+// Denied connections are closed by the transport, the host never sees them, and no connection events fire.
+//
+// The libp2p version of rumor upgrades inbound connections within the transport listener,
+// so inbound connections are checked after the security handshake, together with the authenticated peer ID.
+// The onDeny callback, if not nil, is called with every denied connection, right before it is closed.
+func GatedTransport(newTransport TransportConstructor, gater ConnectionGater, onDeny func(conn network.ConnMultiaddrs, dir network.Direction, p peer.ID)) TransportConstructor {
+	return func(u *tptu.Upgrader) transport.Transport {
+		return &gatedTransport{Transport: newTransport(u), gater: gater, onDeny: onDeny}
+	}
+}
+
+type gatedTransport struct {
+	transport.Transport
+	gater  ConnectionGater
+	onDeny func(conn network.ConnMultiaddrs, dir network.Direction, p peer.ID)
+}
+
+func (t *gatedTransport) deny(conn transport.CapableConn, dir network.Direction) {
+	if t.onDeny != nil {
+		t.onDeny(conn, dir, conn.RemotePeer())
+	}
+	_ = conn.Close()
+}
+
+func (t *gatedTransport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	if !t.gater.InterceptPeerDial(p) {
+		return nil, fmt.Errorf("dial to peer %s denied by connection gater", p)
+	}
+	if !t.gater.InterceptAddrDial(p, raddr) {
+		return nil, fmt.Errorf("dial to peer %s on %s denied by connection gater", p, raddr)
+	}
+	conn, err := t.Transport.Dial(ctx, raddr, p)
+	if err != nil {
+		return nil, err
+	}
+	if !t.gater.InterceptSecured(network.DirOutbound, conn.RemotePeer(), conn) {
+		t.deny(conn, network.DirOutbound)
+		return nil, fmt.Errorf("connection to peer %s denied by connection gater", p)
+	}
+	return conn, nil
+}
+
+func (t *gatedTransport) Listen(laddr ma.Multiaddr) (transport.Listener, error) {
+	l, err := t.Transport.Listen(laddr)
+	if err != nil {
+		return nil, err
+	}
+	return &gatedListener{Listener: l, tpt: t}, nil
+}
+
+type gatedListener struct {
+	transport.Listener
+	tpt *gatedTransport
+}
+
+// Accept returns the next connection that the gater allows, denied connections are closed.
+func (l *gatedListener) Accept() (transport.CapableConn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		gater := l.tpt.gater
+		if gater.InterceptAccept(conn) && gater.InterceptSecured(network.DirInbound, conn.RemotePeer(), conn) {
+			return conn, nil
+		}
+		l.tpt.deny(conn, network.DirInbound)
+	}
+}
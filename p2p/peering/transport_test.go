@@ -0,0 +1,121 @@
+package peering
+
+import (
+	"context"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/transport"
+	tptu "github.com/libp2p/go-libp2p-transport-upgrader"
+	"github.com/libp2p/go-tcp-transport"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newGatedHost(t *testing.T, ctx context.Context, gater ConnectionGater) host.Host {
+	h, err := libp2p.New(ctx,
+		libp2p.Transport(GatedTransport(func(u *tptu.Upgrader) transport.Transport {
+			return tcp.NewTCPTransport(u)
+		}, gater, nil)),
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestGatedTransport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bans := NewBanList()
+	a := newGatedHost(t, ctx, bans)
+	defer a.Close()
+	b := newGatedHost(t, ctx, NewBanList())
+	defer b.Close()
+	c := newGatedHost(t, ctx, NewBanList())
+	defer c.Close()
+
+	var connected int32
+	a.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(net network.Network, conn network.Conn) {
+			if conn.RemotePeer() == b.ID() {
+				atomic.AddInt32(&connected, 1)
+			}
+		},
+	})
+	bans.Add(b.ID(), "test", 0)
+
+	// inbound: the banned peer completes the handshake, but the connection is closed before the host sees it
+	if err := b.Connect(ctx, peer.AddrInfo{ID: a.ID(), Addrs: a.Addrs()}); err == nil {
+		// the dialer may see the connection before it is closed by the listener
+		time.Sleep(100 * time.Millisecond)
+	}
+	// outbound: the dial does not start
+	if err := a.Connect(ctx, peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}); err == nil {
+		t.Fatal("expected dial to banned peer to fail")
+	}
+	if n := atomic.LoadInt32(&connected); n != 0 {
+		t.Fatalf("expected no connection events of the banned peer, got %d", n)
+	}
+	if len(a.Network().ConnsToPeer(b.ID())) != 0 {
+		t.Fatal("expected no connections with the banned peer")
+	}
+
+	// other peers are not affected
+	if err := c.Connect(ctx, peer.AddrInfo{ID: a.ID(), Addrs: a.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Connect(ctx, peer.AddrInfo{ID: c.ID(), Addrs: c.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGatedTransportRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rules := NewGateRules()
+	a := newGatedHost(t, ctx, rules)
+	defer a.Close()
+	b := newGatedHost(t, ctx, NewBanList())
+	defer b.Close()
+	c := newGatedHost(t, ctx, NewBanList())
+	defer c.Close()
+
+	var connected int32
+	a.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(net network.Network, conn network.Conn) {
+			atomic.AddInt32(&connected, 1)
+		},
+	})
+	// only the allowed peer may connect
+	if _, err := rules.Add(GateRule{Allow: true, Kind: RulePeer, Value: c.ID().Pretty()}); err != nil {
+		t.Fatal(err)
+	}
+	_ = b.Connect(ctx, peer.AddrInfo{ID: a.ID(), Addrs: a.Addrs()})
+	if err := a.Connect(ctx, peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}); err == nil {
+		t.Fatal("expected dial to peer that is not allowed to fail")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&connected); n != 0 {
+		t.Fatalf("expected no connection events, got %d", n)
+	}
+	if err := c.Connect(ctx, peer.AddrInfo{ID: a.ID(), Addrs: a.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Network().ClosePeer(a.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	// deny rules take precedence, and are checked on the address of inbound connections
+	if _, err := rules.Add(GateRule{Allow: false, Kind: RuleIP, Value: "127.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&connected, 0)
+	_ = c.Connect(ctx, peer.AddrInfo{ID: a.ID(), Addrs: a.Addrs()})
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&connected); n != 0 {
+		t.Fatalf("expected no connection events from a denied IP, got %d", n)
+	}
+}
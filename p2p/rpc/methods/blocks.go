@@ -46,7 +46,7 @@ func (r *BlocksByRangeReqV1) String() string {
 }
 
 var BlocksByRangeRPCv1 = reqresp.RPCMethod{
-	Protocol:                  "/eth2/beacon_chain/req/beacon_blocks_by_range/1/ssz",
+	Protocol:                  "/eth2/beacon_chain/req/beacon_blocks_by_range/1",
 	RequestCodec:              reqresp.NewSSZCodec((*BlocksByRangeReqV1)(nil)),
 	ResponseChunkCodec:        reqresp.NewSSZCodec((*SignedBeaconBlock)(nil)),
 	DefaultResponseChunkCount: 20,
@@ -82,7 +82,7 @@ func (r BlocksByRootReq) String() string {
 }
 
 var BlocksByRootRPCv1 = reqresp.RPCMethod{
-	Protocol:                  "/eth2/beacon_chain/req/beacon_blocks_by_root/1/ssz",
+	Protocol:                  "/eth2/beacon_chain/req/beacon_blocks_by_root/1",
 	RequestCodec:              reqresp.NewSSZCodec((*BlocksByRootReq)(nil)),
 	ResponseChunkCodec:        reqresp.NewSSZCodec((*SignedBeaconBlock)(nil)),
 	DefaultResponseChunkCount: 20,
@@ -0,0 +1,51 @@
+package methods
+
+import (
+	"fmt"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SSZTypeCodecs maps SSZ type descriptions to codecs, to define new RPC methods at runtime.
+// Byte lists are described with "bytes:<limit>", see ParseSSZTypeCodec.
+var SSZTypeCodecs = map[string]reqresp.Codec{
+	"empty":               (*reqresp.SSZCodec)(nil),
+	"uint64":              reqresp.NewSSZCodec((*uint64)(nil)),
+	"bytes32":             reqresp.NewSSZCodec((*Root)(nil)),
+	"goodbye":             GoodbyeRPCv1.RequestCodec,
+	"status":              StatusRPCv1.RequestCodec,
+	"ping":                PingRPCv1.RequestCodec,
+	"metadata":            MetaDataRPCv1.ResponseChunkCodec,
+	"blocks_by_range_req": BlocksByRangeRPCv1.RequestCodec,
+	"blocks_by_root_req":  BlocksByRootRPCv1.RequestCodec,
+	"signed_beacon_block": BlocksByRangeRPCv1.ResponseChunkCodec,
+}
+
+// SSZTypeNames lists the names of the known SSZ type descriptions, sorted.
+func SSZTypeNames() []string {
+	out := make([]string, 0, len(SSZTypeCodecs)+1)
+	for k := range SSZTypeCodecs {
+		out = append(out, k)
+	}
+	out = append(out, "bytes:<limit>")
+	sort.Strings(out)
+	return out
+}
+
+// ParseSSZTypeCodec gets the codec for the given SSZ type description.
+func ParseSSZTypeCodec(desc string) (reqresp.Codec, error) {
+	if strings.HasPrefix(desc, "bytes:") {
+		limit, err := strconv.ParseUint(strings.TrimPrefix(desc, "bytes:"), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte list limit in type '%s': %v", desc, err)
+		}
+		return reqresp.RawBytesCodec{Limit: limit}, nil
+	}
+	c, ok := SSZTypeCodecs[desc]
+	if !ok {
+		return nil, fmt.Errorf("unknown SSZ type '%s', known types: %s", desc, strings.Join(SSZTypeNames(), ", "))
+	}
+	return c, nil
+}
@@ -3,16 +3,73 @@ package methods
 import (
 	"fmt"
 	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"sort"
+	"strconv"
 )
 
 type Goodbye uint64
 
+const (
+	GoodbyeClientShutdown    Goodbye = 1
+	GoodbyeIrrelevantNetwork Goodbye = 2
+	GoodbyeFaultError        Goodbye = 3
+)
+
+// Extension reason codes, not part of the spec, but used by clients in practice.
+const (
+	GoodbyeUnableToVerifyNetwork Goodbye = 128
+	GoodbyeTooManyPeers          Goodbye = 129
+	GoodbyeBadScore              Goodbye = 250
+	GoodbyeBanned                Goodbye = 251
+)
+
+var goodbyeNames = map[Goodbye]string{
+	GoodbyeClientShutdown:        "client_shutdown",
+	GoodbyeIrrelevantNetwork:     "irrelevant_network",
+	GoodbyeFaultError:            "fault_error",
+	GoodbyeUnableToVerifyNetwork: "unable_to_verify_network",
+	GoodbyeTooManyPeers:          "too_many_peers",
+	GoodbyeBadScore:              "bad_score",
+	GoodbyeBanned:                "banned",
+}
+
+// Name of the reason code, or "unknown" if the code is not known.
+func (r Goodbye) Name() string {
+	if name, ok := goodbyeNames[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
 func (r Goodbye) String() string {
-	return fmt.Sprintf("Goodbye(%d)", r)
+	return fmt.Sprintf("Goodbye(%d: %s)", uint64(r), r.Name())
+}
+
+// ParseGoodbye parses a reason code, by name or by number.
+func ParseGoodbye(v string) (Goodbye, error) {
+	for code, name := range goodbyeNames {
+		if name == v {
+			return code, nil
+		}
+	}
+	n, err := strconv.ParseUint(v, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unrecognized goodbye reason: %s", v)
+	}
+	return Goodbye(n), nil
+}
+
+// GoodbyeNames lists the names of the known reason codes
+func GoodbyeNames() (out []string) {
+	for _, name := range goodbyeNames {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return
 }
 
 var GoodbyeRPCv1 = reqresp.RPCMethod{
-	Protocol:                  "/eth2/beacon_chain/req/goodbye/1/ssz",
+	Protocol:                  "/eth2/beacon_chain/req/goodbye/1",
 	RequestCodec:              reqresp.NewSSZCodec((*Goodbye)(nil)),
 	ResponseChunkCodec:        reqresp.NewSSZCodec((*Goodbye)(nil)),
 	DefaultResponseChunkCount: 0,
@@ -27,7 +27,7 @@ func (m *MetaData) String() string {
 }
 
 var MetaDataRPCv1 = reqresp.RPCMethod{
-	Protocol:                  "/eth2/beacon_chain/req/metadata/1/ssz",
+	Protocol:                  "/eth2/beacon_chain/req/metadata/1",
 	RequestCodec:              (*reqresp.SSZCodec)(nil), // no request data, just empty bytes.
 	ResponseChunkCodec:        reqresp.NewSSZCodec((*MetaData)(nil)),
 	DefaultResponseChunkCount: 1,
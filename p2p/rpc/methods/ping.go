@@ -24,7 +24,7 @@ func (r Pong) String() string {
 }
 
 var PingRPCv1 = reqresp.RPCMethod{
-	Protocol:                  "/eth2/beacon_chain/req/ping/1/ssz",
+	Protocol:                  "/eth2/beacon_chain/req/ping/1",
 	RequestCodec:              reqresp.NewSSZCodec((*Ping)(nil)),
 	ResponseChunkCodec:        reqresp.NewSSZCodec((*Pong)(nil)),
 	DefaultResponseChunkCount: 1,
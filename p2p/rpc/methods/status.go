@@ -33,7 +33,7 @@ func (s *Status) String() string {
 }
 
 var StatusRPCv1 = reqresp.RPCMethod{
-	Protocol:                  "/eth2/beacon_chain/req/status/1/ssz",
+	Protocol:                  "/eth2/beacon_chain/req/status/1",
 	RequestCodec:              reqresp.NewSSZCodec((*Status)(nil)),
 	ResponseChunkCodec:        reqresp.NewSSZCodec((*Status)(nil)),
 	DefaultResponseChunkCount: 1,
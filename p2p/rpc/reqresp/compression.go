@@ -17,6 +17,18 @@ type Compression interface {
 	Name() string
 }
 
+// CompressionByName returns the compression with the given name. "none" returns nil, for uncompressed contents.
+func CompressionByName(name string) (Compression, error) {
+	switch name {
+	case "none", "":
+		return nil, nil
+	case "snappy":
+		return SnappyCompression{}, nil
+	default:
+		return nil, fmt.Errorf("unrecognized compression: %s", name)
+	}
+}
+
 type SnappyCompression struct{}
 
 func (c SnappyCompression) Decompress(reader io.Reader) io.Reader {
@@ -0,0 +1,114 @@
+package reqresp
+
+import (
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"sync"
+)
+
+// Encoding is the wire encoding of request and response chunk contents,
+// named by the last segment of the protocol ID. E.g. "ssz_snappy" in "/eth2/beacon_chain/req/status/1/ssz_snappy".
+type Encoding struct {
+	Name string
+	// Compression of the SSZ contents. Nil if the contents are not compressed.
+	Compression Compression
+	// Experimental encodings are not part of the spec, and may change at any time.
+	Experimental bool
+}
+
+func (e *Encoding) String() string {
+	return e.Name
+}
+
+var (
+	SSZEncoding       = &Encoding{Name: "ssz"}
+	SSZSnappyEncoding = &Encoding{Name: "ssz_snappy", Compression: SnappyCompression{}}
+)
+
+// EncodingRegistry maps encoding names to encodings, in order of preference.
+// There is one slot for an experimental encoding, which is always the last preference.
+type EncodingRegistry struct {
+	lock         sync.RWMutex
+	encodings    []*Encoding
+	experimental *Encoding
+}
+
+func NewEncodingRegistry(encodings ...*Encoding) *EncodingRegistry {
+	return &EncodingRegistry{encodings: encodings}
+}
+
+// Encodings is the registry used by rumor, supporting the spec encodings.
+var Encodings = NewEncodingRegistry(SSZSnappyEncoding, SSZEncoding)
+
+// Get the encoding by name. Returns nil if the encoding is not registered.
+func (r *EncodingRegistry) Get(name string) *Encoding {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, enc := range r.encodings {
+		if enc.Name == name {
+			return enc
+		}
+	}
+	if r.experimental != nil && r.experimental.Name == name {
+		return r.experimental
+	}
+	return nil
+}
+
+// All returns the registered encodings, in order of preference.
+func (r *EncodingRegistry) All() []*Encoding {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	out := append([]*Encoding(nil), r.encodings...)
+	if r.experimental != nil {
+		out = append(out, r.experimental)
+	}
+	return out
+}
+
+// Experimental returns the encoding in the experimental slot, or nil if there is none.
+func (r *EncodingRegistry) Experimental() *Encoding {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.experimental
+}
+
+// SetExperimental puts the encoding in the experimental slot, replacing any previous experimental encoding.
+// Nil clears the slot.
+func (r *EncodingRegistry) SetExperimental(enc *Encoding) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if enc != nil {
+		for _, e := range r.encodings {
+			if e.Name == enc.Name {
+				return fmt.Errorf("encoding %s is already registered", enc.Name)
+			}
+		}
+		enc.Experimental = true
+	}
+	r.experimental = enc
+	return nil
+}
+
+// ProtocolID resolves the protocol ID of the method for the given encoding.
+func (m *RPCMethod) ProtocolID(enc *Encoding) protocol.ID {
+	return m.Protocol + protocol.ID("/"+enc.Name)
+}
+
+// MakeEncodingHandler creates the stream handler to serve the method in the given encoding.
+type MakeEncodingHandler func(enc *Encoding) network.StreamHandler
+
+// SetStreamHandlers registers a stream handler for each of the encodings,
+// similar to how clients support multiple encodings, and let the remote select one during negotiation.
+// Returns the protocol IDs, to remove the handlers with later.
+func (m *RPCMethod) SetStreamHandlers(setHandler func(pid protocol.ID, handler network.StreamHandler),
+	encodings []*Encoding, makeHandler MakeEncodingHandler) []protocol.ID {
+	protocols := make([]protocol.ID, 0, len(encodings))
+	for _, enc := range encodings {
+		prot := m.ProtocolID(enc)
+		setHandler(prot, makeHandler(enc))
+		protocols = append(protocols, prot)
+	}
+	return protocols
+}
@@ -0,0 +1,31 @@
+package reqresp
+
+import "testing"
+
+func TestEncodingRegistryExperimental(t *testing.T) {
+	r := NewEncodingRegistry(SSZSnappyEncoding, SSZEncoding)
+	if err := r.SetExperimental(&Encoding{Name: "ssz"}); err == nil {
+		t.Fatal("expected registered encoding name to be refused")
+	}
+	exp := &Encoding{Name: "ssz_draft", Compression: SnappyCompression{}}
+	if err := r.SetExperimental(exp); err != nil {
+		t.Fatal(err)
+	}
+	if r.Get("ssz_draft") != exp || !exp.Experimental {
+		t.Fatal("expected experimental encoding to be registered")
+	}
+	all := r.All()
+	if len(all) != 3 || all[2] != exp {
+		t.Fatal("expected experimental encoding to be the last preference")
+	}
+	m := RPCMethod{Protocol: "/eth2/beacon_chain/req/status/1"}
+	if got := m.ProtocolID(exp); got != "/eth2/beacon_chain/req/status/1/ssz_draft" {
+		t.Fatalf("unexpected protocol ID: %s", got)
+	}
+	if err := r.SetExperimental(nil); err != nil {
+		t.Fatal(err)
+	}
+	if r.Get("ssz_draft") != nil || len(r.All()) != 2 {
+		t.Fatal("expected experimental slot to be cleared")
+	}
+}
@@ -0,0 +1,136 @@
+package reqresp
+
+import (
+	"bytes"
+	"context"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"sync"
+	"time"
+)
+
+// RateLimiter decides if a peer is allowed to make another request.
+type RateLimiter interface {
+	// Allow consumes a request token of the peer, and returns false if the peer is over quota.
+	Allow(peerId peer.ID) bool
+}
+
+// OnRateLimited is called with the stream of a request that is over quota, instead of handling the request.
+// The stream is closed after the call returns.
+type OnRateLimited func(peerId peer.ID, stream network.Stream)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// PeerRateLimiter is a token-bucket rate limiter, with a bucket per peer.
+// Use a separate limiter per protocol to limit per peer and per protocol.
+type PeerRateLimiter struct {
+	// tokens added per second
+	rate float64
+	// max tokens in a bucket
+	burst float64
+
+	sync.Mutex
+	buckets map[peer.ID]*tokenBucket
+}
+
+// NewPeerRateLimiter creates a limiter that allows each peer the given limit of requests per period,
+// with bursts of up to the given burst size. If the burst is 0, it defaults to the limit.
+func NewPeerRateLimiter(limit uint64, period time.Duration, burst uint64) *PeerRateLimiter {
+	if burst == 0 {
+		burst = limit
+	}
+	return &PeerRateLimiter{
+		rate:    float64(limit) / period.Seconds(),
+		burst:   float64(burst),
+		buckets: make(map[peer.ID]*tokenBucket),
+	}
+}
+
+func (l *PeerRateLimiter) refill(b *tokenBucket, now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+}
+
+func (l *PeerRateLimiter) Allow(peerId peer.ID) bool {
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	b, ok := l.buckets[peerId]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[peerId] = b
+	} else {
+		l.refill(b, now)
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens -= 1
+	return true
+}
+
+// Prune removes the buckets that are full again. Full buckets are equal to new buckets, no need to track them.
+func (l *PeerRateLimiter) Prune() {
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	for id, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+// PruneEvery prunes the limiter at the given interval, until the context is done.
+func (l *PeerRateLimiter) PruneEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.Prune()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// LimitStreamHandler wraps a stream handler to only handle requests within the quota of the limiter.
+// Requests over quota are passed to onLimit instead, which may be nil to just close the stream.
+// If the limiter is nil, the handler is returned as-is.
+func LimitStreamHandler(limiter RateLimiter, onLimit OnRateLimited, handler network.StreamHandler) network.StreamHandler {
+	if limiter == nil {
+		return handler
+	}
+	return func(stream network.Stream) {
+		peerId := stream.Conn().RemotePeer()
+		if limiter.Allow(peerId) {
+			handler(stream)
+			return
+		}
+		if onLimit != nil {
+			onLimit(peerId, stream)
+		}
+		_ = stream.Close()
+	}
+}
+
+// RateLimitedMsg is the error message that is used when responding to requests over quota.
+const RateLimitedMsg = "rate limited"
+
+// WriteRateLimitedErr writes a server-error chunk, to signal the request was rate limited.
+// The compression is optional and may be nil.
+func WriteRateLimitedErr(stream network.Stream, comp Compression, timeout time.Duration) error {
+	if timeout != 0 {
+		_ = stream.SetWriteDeadline(time.Now().Add(timeout))
+	}
+	msg := []byte(RateLimitedMsg)
+	return StreamChunk(ServerErrCode, uint64(len(msg)), bytes.NewReader(msg), stream, comp)
+}
@@ -0,0 +1,44 @@
+package reqresp
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	"testing"
+	"time"
+)
+
+func TestPeerRateLimiterBurst(t *testing.T) {
+	l := NewPeerRateLimiter(1, time.Hour, 2)
+	a, b := peer.ID("a"), peer.ID("b")
+	if !l.Allow(a) || !l.Allow(a) {
+		t.Fatal("expected burst of 2 to be allowed")
+	}
+	if l.Allow(a) {
+		t.Fatal("expected request over burst to be denied")
+	}
+	if !l.Allow(b) {
+		t.Fatal("expected other peer to be unaffected")
+	}
+}
+
+func TestPeerRateLimiterRefill(t *testing.T) {
+	l := NewPeerRateLimiter(1, time.Millisecond, 1)
+	p := peer.ID("a")
+	if !l.Allow(p) {
+		t.Fatal("expected first request to be allowed")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if !l.Allow(p) {
+		t.Fatal("expected request to be allowed after refill")
+	}
+}
+
+func TestPeerRateLimiterPrune(t *testing.T) {
+	l := NewPeerRateLimiter(1, time.Millisecond, 1)
+	l.Allow("a")
+	l.Allow("b")
+	time.Sleep(5 * time.Millisecond)
+	l.Prune()
+	if len(l.buckets) != 0 {
+		t.Fatalf("expected refilled buckets to be pruned, got %d", len(l.buckets))
+	}
+}
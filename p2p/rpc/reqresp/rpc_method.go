@@ -3,6 +3,7 @@ package reqresp
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/libp2p/go-libp2p-core/network"
@@ -78,6 +79,7 @@ func (c *SSZCodec) Alloc() interface{} {
 }
 
 type RPCMethod struct {
+	// Protocol ID, without the encoding segment. See ProtocolID to get the full protocol ID.
 	Protocol                  protocol.ID
 	RequestCodec              Codec
 	ResponseChunkCodec        Codec
@@ -165,7 +167,7 @@ func (c *chRespHandler) ReadObj(dest interface{}) error {
 }
 
 func (m *RPCMethod) RunRequest(ctx context.Context, newStreamFn NewStreamFn,
-	peerId peer.ID, comp Compression, req RequestInput, maxRespChunks uint64, madeRequest func() error,
+	peerId peer.ID, enc *Encoding, req RequestInput, maxRespChunks uint64, madeRequest func() error,
 	onResponse OnResponseListener) error {
 
 	handleChunks := ResponseChunkHandler(func(ctx context.Context, chunkIndex uint64, chunkSize uint64, result ResponseCode, r io.Reader, w io.Writer) error {
@@ -183,10 +185,10 @@ func (m *RPCMethod) RunRequest(ctx context.Context, newStreamFn NewStreamFn,
 		return err
 	}
 
-	protocolId := m.Protocol
+	protocolId := m.ProtocolID(enc)
+	comp := enc.Compression
 	maxChunkContentSize := m.ResponseChunkCodec.MaxByteLen()
 	if comp != nil {
-		protocolId += protocol.ID("_" + comp.Name())
 		if s, err := comp.MaxEncodedLen(maxChunkContentSize); err != nil {
 			return err
 		} else {
@@ -291,10 +293,69 @@ func (h *chReqHandler) WriteErrorChunk(code ResponseCode, msg string) error {
 
 type OnRequestListener func(ctx context.Context, peerId peer.ID, handler ChunkedRequestHandler)
 
-func (m *RPCMethod) MakeStreamHandler(newCtx StreamCtxFn, comp Compression, listener OnRequestListener) network.StreamHandler {
+func (m *RPCMethod) MakeStreamHandler(newCtx StreamCtxFn, enc *Encoding, listener OnRequestListener) network.StreamHandler {
 	return RequestPayloadHandler(func(ctx context.Context, peerId peer.ID, requestLen uint64, r io.Reader, w io.Writer, comp Compression, invalidInputErr error) {
 		listener(ctx, peerId, &chReqHandler{
 			m: m, comp: comp, reqLen: requestLen, r: r, w: w, invalidInputErr: invalidInputErr,
 		})
-	}).MakeStreamHandler(newCtx, comp, m.RequestCodec.MaxByteLen())
+	}).MakeStreamHandler(newCtx, enc.Compression, m.RequestCodec.MaxByteLen())
+}
+
+// RawBytes is a byte string that is encoded as-is, formatted as hex in logs.
+type RawBytes []byte
+
+func (b RawBytes) MarshalText() ([]byte, error) {
+	return []byte("0x" + hex.EncodeToString(b)), nil
+}
+
+func (b RawBytes) String() string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+// RawBytesCodec encodes and decodes byte strings of up to Limit bytes, equal to an SSZ byte-list.
+type RawBytesCodec struct {
+	Limit uint64
+}
+
+func (c RawBytesCodec) MaxByteLen() uint64 {
+	return c.Limit
+}
+
+func (c RawBytesCodec) Encode(w io.Writer, input interface{}) error {
+	var data []byte
+	switch v := input.(type) {
+	case []byte:
+		data = v
+	case RawBytes:
+		data = v
+	case *RawBytes:
+		data = *v
+	default:
+		return fmt.Errorf("cannot encode %T as raw bytes", input)
+	}
+	if uint64(len(data)) > c.Limit {
+		return fmt.Errorf("byte length %d exceeds limit %d", len(data), c.Limit)
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func (c RawBytesCodec) Decode(r io.Reader, bytesLen uint64, dest interface{}) error {
+	if bytesLen > c.Limit {
+		return fmt.Errorf("byte length %d exceeds limit %d", bytesLen, c.Limit)
+	}
+	d, ok := dest.(*RawBytes)
+	if !ok {
+		return fmt.Errorf("cannot decode raw bytes into %T", dest)
+	}
+	data := make([]byte, bytesLen, bytesLen)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	*d = data
+	return nil
+}
+
+func (c RawBytesCodec) Alloc() interface{} {
+	return new(RawBytes)
 }
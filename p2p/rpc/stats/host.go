@@ -0,0 +1,234 @@
+package stats
+
+import (
+	"context"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/mux"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"github.com/protolambda/rumor/p2p/track"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OnRecord is called with every completed request
+type OnRecord func(rec *track.RPCRecord)
+
+// IsReqResp returns true for request-response protocols, i.e. protocols with a "/req/" path segment.
+// Other protocols, like gossipsub or identify, use long-lived streams, and are not tracked.
+func IsReqResp(prot protocol.ID) bool {
+	return strings.Contains(string(prot), "/req/")
+}
+
+type trackedHost struct {
+	host.Host
+	onRecord OnRecord
+}
+
+// WrapHost wraps the host, to track every request-response stream that is opened or handled through the host.
+func WrapHost(h host.Host, onRecord OnRecord) host.Host {
+	return &trackedHost{Host: h, onRecord: onRecord}
+}
+
+func (h *trackedHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	tracked := false
+	for _, pid := range pids {
+		if IsReqResp(pid) {
+			tracked = true
+			break
+		}
+	}
+	if !tracked {
+		return h.Host.NewStream(ctx, p, pids...)
+	}
+	start := time.Now()
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		rec := &track.RPCRecord{
+			Peer:     p,
+			Protocol: pids[0],
+			Inbound:  false,
+			Start:    start,
+			Duration: time.Since(start),
+			ErrClass: "open_" + ClassifyErr(err),
+		}
+		h.onRecord(rec)
+		return nil, err
+	}
+	return newTrackedStream(s, false, start, h.onRecord), nil
+}
+
+func (h *trackedHost) wrapHandler(handler network.StreamHandler) network.StreamHandler {
+	return func(s network.Stream) {
+		if !IsReqResp(s.Protocol()) {
+			handler(s)
+			return
+		}
+		ts := newTrackedStream(s, true, time.Now(), h.onRecord)
+		handler(ts)
+		ts.finish("")
+	}
+}
+
+func (h *trackedHost) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	h.Host.SetStreamHandler(pid, h.wrapHandler(handler))
+}
+
+func (h *trackedHost) SetStreamHandlerMatch(pid protocol.ID, match func(string) bool, handler network.StreamHandler) {
+	h.Host.SetStreamHandlerMatch(pid, match, h.wrapHandler(handler))
+}
+
+// ClassifyErr maps a stream error to a short error class.
+func ClassifyErr(err error) string {
+	if err == nil || err == io.EOF {
+		return ""
+	}
+	if err == mux.ErrReset {
+		return "reset"
+	}
+	if err == context.DeadlineExceeded {
+		return "timeout"
+	}
+	if err == context.Canceled {
+		return "canceled"
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return "timeout"
+	}
+	if strings.Contains(err.Error(), "protocol not supported") {
+		return "unsupported_protocol"
+	}
+	return "other"
+}
+
+// classifyResult maps the result code of a response chunk to an error class
+func classifyResult(code reqresp.ResponseCode) string {
+	switch code {
+	case reqresp.SuccessCode:
+		return ""
+	case reqresp.InvalidReqCode:
+		return "invalid_request"
+	case reqresp.ServerErrCode:
+		return "server_error"
+	default:
+		return "unknown_result"
+	}
+}
+
+// trackedStream measures a request stream.
+// The first byte of the response is the result code of the first chunk,
+// which marks the time to the first chunk, and classifies error responses.
+type trackedStream struct {
+	network.Stream
+	onRecord OnRecord
+
+	lock        sync.Mutex
+	rec         track.RPCRecord
+	writeClosed bool
+	done        bool
+}
+
+func newTrackedStream(s network.Stream, inbound bool, start time.Time, onRecord OnRecord) *trackedStream {
+	return &trackedStream{
+		Stream:   s,
+		onRecord: onRecord,
+		rec: track.RPCRecord{
+			Peer:     s.Conn().RemotePeer(),
+			Protocol: s.Protocol(),
+			Inbound:  inbound,
+			Start:    start,
+		},
+	}
+}
+
+// firstResponseByte registers the first byte of the response, if it is the first. Requires the lock.
+func (s *trackedStream) firstResponseByte(b byte) {
+	if s.rec.FirstChunk == 0 {
+		s.rec.FirstChunk = time.Since(s.rec.Start)
+		if s.rec.ErrClass == "" {
+			s.rec.ErrClass = classifyResult(reqresp.ResponseCode(b))
+		}
+	}
+}
+
+// finish completes the record, and reports it, if not already done.
+func (s *trackedStream) finish(errClass string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.finishLocked(errClass)
+}
+
+func (s *trackedStream) finishLocked(errClass string) {
+	if s.done {
+		return
+	}
+	s.done = true
+	if s.rec.ErrClass == "" {
+		s.rec.ErrClass = errClass
+	}
+	s.rec.Duration = time.Since(s.rec.Start)
+	rec := s.rec
+	s.onRecord(&rec)
+}
+
+func (s *trackedStream) Read(p []byte) (n int, err error) {
+	n, err = s.Stream.Read(p)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if n > 0 {
+		s.rec.BytesIn += uint64(n)
+		if !s.rec.Inbound {
+			s.firstResponseByte(p[0])
+		}
+	}
+	if err != nil {
+		if s.rec.Inbound {
+			// keep the error, the request completes when the handler returns
+			if s.rec.ErrClass == "" {
+				s.rec.ErrClass = ClassifyErr(err)
+			}
+		} else {
+			s.finishLocked(ClassifyErr(err))
+		}
+	}
+	return
+}
+
+func (s *trackedStream) Write(p []byte) (n int, err error) {
+	n, err = s.Stream.Write(p)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if n > 0 {
+		s.rec.BytesOut += uint64(n)
+		if s.rec.Inbound {
+			s.firstResponseByte(p[0])
+		}
+	}
+	if err != nil && s.rec.ErrClass == "" {
+		s.rec.ErrClass = ClassifyErr(err)
+	}
+	return
+}
+
+func (s *trackedStream) Close() error {
+	err := s.Stream.Close()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// The request side is closed first, the request completes when the response side is closed after.
+	if !s.rec.Inbound && (s.writeClosed || s.rec.BytesIn > 0) {
+		s.finishLocked("")
+	}
+	s.writeClosed = true
+	return err
+}
+
+func (s *trackedStream) Reset() error {
+	err := s.Stream.Reset()
+	s.finish("reset")
+	return err
+}
@@ -0,0 +1,85 @@
+package stats
+
+import (
+	"context"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/protolambda/rumor/p2p/track"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+const testProtocol = protocol.ID("/eth2/beacon_chain/req/test/1/ssz")
+
+func expectRecord(t *testing.T, records chan *track.RPCRecord, inbound bool, bytesIn, bytesOut uint64, errClass string) {
+	t.Helper()
+	select {
+	case rec := <-records:
+		if rec.Inbound != inbound {
+			t.Errorf("expected inbound=%v record", inbound)
+		}
+		if rec.BytesIn != bytesIn || rec.BytesOut != bytesOut {
+			t.Errorf("expected %d bytes in and %d bytes out, got %d and %d", bytesIn, bytesOut, rec.BytesIn, rec.BytesOut)
+		}
+		if rec.ErrClass != errClass {
+			t.Errorf("expected error class %q, got %q", errClass, rec.ErrClass)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for record")
+	}
+}
+
+func TestWrapHost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mn, err := mocknet.FullMeshConnected(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outRecords := make(chan *track.RPCRecord, 10)
+	inRecords := make(chan *track.RPCRecord, 10)
+	a := WrapHost(mn.Hosts()[0], func(rec *track.RPCRecord) { outRecords <- rec })
+	b := WrapHost(mn.Hosts()[1], func(rec *track.RPCRecord) { inRecords <- rec })
+
+	// The handler responds with the first request byte as result code, followed by 2 bytes, or resets if it is 0xff.
+	b.SetStreamHandler(testProtocol, func(s network.Stream) {
+		req, _ := ioutil.ReadAll(s)
+		if req[0] == 0xff {
+			_ = s.Reset()
+			return
+		}
+		_, _ = s.Write([]byte{req[0], 1, 2})
+		_ = s.Close()
+	})
+
+	request := func(req []byte) {
+		s, err := a.NewStream(ctx, b.ID(), testProtocol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Write(req); err != nil {
+			t.Fatal(err)
+		}
+		_ = s.Close()
+		_, _ = ioutil.ReadAll(s)
+	}
+
+	request([]byte{0, 42, 42, 42})
+	expectRecord(t, inRecords, true, 4, 3, "")
+	expectRecord(t, outRecords, false, 3, 4, "")
+
+	request([]byte{2, 42})
+	expectRecord(t, inRecords, true, 2, 3, "server_error")
+	expectRecord(t, outRecords, false, 3, 2, "server_error")
+
+	request([]byte{0xff})
+	expectRecord(t, inRecords, true, 1, 0, "reset")
+	expectRecord(t, outRecords, false, 0, 1, "reset")
+
+	if _, err := a.NewStream(ctx, b.ID(), "/eth2/beacon_chain/req/unknown/1/ssz"); err == nil {
+		t.Fatal("expected unsupported protocol to fail")
+	}
+	expectRecord(t, outRecords, false, 0, 0, "open_unsupported_protocol")
+}
@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/protolambda/rumor/p2p/track"
+	"sync"
+)
+
+// Registry aggregates request stats per peer and protocol.
+type Registry struct {
+	sync.Mutex
+	peers map[peer.ID]*track.PeerRPCStats
+}
+
+var _ track.RPCStatsBook = (*Registry)(nil)
+
+func (r *Registry) RegisterRPC(rec *track.RPCRecord) {
+	r.Lock()
+	defer r.Unlock()
+	if r.peers == nil {
+		r.peers = make(map[peer.ID]*track.PeerRPCStats)
+	}
+	ps, ok := r.peers[rec.Peer]
+	if !ok {
+		ps = new(track.PeerRPCStats)
+		r.peers[rec.Peer] = ps
+	}
+	ps.Add(rec)
+}
+
+func (r *Registry) RPCStats(id peer.ID) *track.PeerRPCStats {
+	r.Lock()
+	defer r.Unlock()
+	ps, ok := r.peers[id]
+	if !ok {
+		return nil
+	}
+	return ps.Copy()
+}
+
+// Peers returns a copy of the stats of all peers
+func (r *Registry) Peers() map[peer.ID]*track.PeerRPCStats {
+	r.Lock()
+	defer r.Unlock()
+	out := make(map[peer.ID]*track.PeerRPCStats, len(r.peers))
+	for id, ps := range r.peers {
+		out[id] = ps.Copy()
+	}
+	return out
+}
+
+// Protocols merges the stats of all peers, per protocol, in the requested direction.
+func (r *Registry) Protocols(inbound bool) map[protocol.ID]*track.RPCStats {
+	r.Lock()
+	defer r.Unlock()
+	out := make(map[protocol.ID]*track.RPCStats)
+	for _, ps := range r.peers {
+		m := ps.Outbound
+		if inbound {
+			m = ps.Inbound
+		}
+		for prot, s := range m {
+			total, ok := out[prot]
+			if !ok {
+				total = new(track.RPCStats)
+				out[prot] = total
+			}
+			total.Merge(s)
+		}
+	}
+	return out
+}
+
+// Reset forgets all stats
+func (r *Registry) Reset() {
+	r.Lock()
+	defer r.Unlock()
+	r.peers = nil
+}
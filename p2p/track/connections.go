@@ -0,0 +1,32 @@
+package track
+
+import (
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/network"
+	"time"
+)
+
+// goodbyeWindow is how recent a goodbye must be to be the likely reason of a disconnect
+const goodbyeWindow = 10 * time.Second
+
+// ConnectionNotifiee feeds the connection book with the connections of a host network.
+// Libp2p does not report why a connection closed: a recent goodbye with the peer is recorded as reason,
+// otherwise the reason set with SetDisconnectReason, if any.
+func ConnectionNotifiee(book ConnectionBook, goodbyes GoodbyeBook) network.Notifiee {
+	return &network.NotifyBundle{
+		ConnectedF: func(net network.Network, conn network.Conn) {
+			book.RegisterConnected(conn.RemotePeer(), conn.Stat().Direction)
+		},
+		DisconnectedF: func(net network.Network, conn network.Conn) {
+			id := conn.RemotePeer()
+			if gb := goodbyes.LastGoodbye(id); gb != nil && time.Since(gb.Time) < goodbyeWindow {
+				if gb.Received {
+					book.SetDisconnectReason(id, fmt.Sprintf("goodbye received: %s", gb.Reason.Name()))
+				} else {
+					book.SetDisconnectReason(id, fmt.Sprintf("goodbye sent: %s", gb.Reason.Name()))
+				}
+			}
+			book.RegisterDisconnected(id)
+		},
+	}
+}
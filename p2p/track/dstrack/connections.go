@@ -0,0 +1,182 @@
+package dstrack
+
+import (
+	"encoding/json"
+	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/p2p/track"
+	"sync"
+	"time"
+)
+
+var connectionSuffix = ds.NewKey("/connection")
+
+// connState is the connection history of a peer, with the state of the current connections
+type connState struct {
+	rec track.ConnectionRecord
+	// number of open connections
+	open int
+	// time of the first of the open connections
+	since time.Time
+	// reason to record on the next disconnect
+	reason string
+}
+
+func (cs *connState) snapshot(now time.Time) *track.ConnectionRecord {
+	out := cs.rec
+	if cs.open > 0 {
+		out.Connected = true
+		out.TotalConnected += now.Sub(cs.since)
+	}
+	return &out
+}
+
+type dsConnectionBook struct {
+	ds ds.Datastore
+	// lock for the connection states. Only peers with open connections are cached,
+	// the history of a peer is written to the datastore when its last connection closes.
+	lock sync.Mutex
+	data map[peer.ID]*connState
+}
+
+var _ track.ConnectionBook = (*dsConnectionBook)(nil)
+
+func NewConnectionBook(store ds.Datastore) (*dsConnectionBook, error) {
+	return &dsConnectionBook{ds: store, data: make(map[peer.ID]*connState)}, nil
+}
+
+func (cb *dsConnectionBook) loadConnection(p peer.ID) (*track.ConnectionRecord, error) {
+	key := peerIdToKey(eth2Base, p).Child(connectionSuffix)
+	value, err := cb.ds.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching connection record from datastore for peer %s: %s\n", p.Pretty(), err)
+	}
+	var rec track.ConnectionRecord
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return nil, fmt.Errorf("failed parse connection record from datastore: %v", err)
+	}
+	return &rec, nil
+}
+
+func (cb *dsConnectionBook) storeConnection(p peer.ID, rec *track.ConnectionRecord) error {
+	key := peerIdToKey(eth2Base, p).Child(connectionSuffix)
+	dat, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed encode connection record for datastore: %v", err)
+	}
+	if err := cb.ds.Put(key, dat); err != nil {
+		return fmt.Errorf("failed to store connection record: %v", err)
+	}
+	return nil
+}
+
+// state gets the cached state of the peer, or loads the history from the datastore, without caching it.
+// If create is false, nil is returned when the peer has no history. The lock must be held.
+func (cb *dsConnectionBook) state(id peer.ID, create bool) *connState {
+	if cs, ok := cb.data[id]; ok {
+		return cs
+	}
+	if rec, err := cb.loadConnection(id); err == nil {
+		// connections from a previous session are closed by now
+		rec.Connected = false
+		return &connState{rec: *rec}
+	}
+	if create {
+		return &connState{}
+	}
+	return nil
+}
+
+func (cb *dsConnectionBook) RegisterConnected(id peer.ID, dir network.Direction) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cs := cb.state(id, true)
+	now := time.Now()
+	if cs.rec.FirstSeen.IsZero() {
+		cs.rec.FirstSeen = now
+	}
+	cs.rec.Direction = fmtDirection(dir)
+	if cs.open == 0 {
+		cs.since = now
+		cs.rec.LastConnected = now
+		cs.rec.Connections += 1
+		cs.reason = ""
+	}
+	cs.open += 1
+	cb.data[id] = cs
+}
+
+func (cb *dsConnectionBook) RegisterDisconnected(id peer.ID) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cs, ok := cb.data[id]
+	if !ok || cs.open == 0 {
+		return
+	}
+	cs.open -= 1
+	if cs.open > 0 {
+		return
+	}
+	now := time.Now()
+	cs.rec.LastDisconnected = now
+	cs.rec.TotalConnected += now.Sub(cs.since)
+	cs.rec.DisconnectReason = cs.reason
+	cs.reason = ""
+	// records that fail to be written stay in the cache, and are retried with the next flush
+	if err := cb.storeConnection(id, &cs.rec); err == nil {
+		delete(cb.data, id)
+	}
+}
+
+func (cb *dsConnectionBook) SetDisconnectReason(id peer.ID, reason string) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if cs, ok := cb.data[id]; ok && cs.open > 0 {
+		cs.reason = reason
+	}
+}
+
+func (cb *dsConnectionBook) Connection(id peer.ID) *track.ConnectionRecord {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cs := cb.state(id, false)
+	if cs == nil {
+		return nil
+	}
+	return cs.snapshot(time.Now())
+}
+
+// flush writes the cached connection records to the datastore, and evicts the peers that are not connected.
+func (cb *dsConnectionBook) flush() error {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	now := time.Now()
+	for id, cs := range cb.data {
+		rec := cs.snapshot(now)
+		rec.Connected = false
+		if err := cb.storeConnection(id, rec); err != nil {
+			return err
+		}
+		if cs.open == 0 {
+			delete(cb.data, id)
+		}
+	}
+	return nil
+}
+
+func (cb *dsConnectionBook) Close() error {
+	return cb.flush()
+}
+
+func fmtDirection(d network.Direction) string {
+	switch d {
+	case network.DirInbound:
+		return "inbound"
+	case network.DirOutbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,45 @@
+package dstrack
+
+import (
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/network"
+	"testing"
+)
+
+func TestConnectionBookWriteThrough(t *testing.T) {
+	cb, err := NewConnectionBook(ds.NewMapDatastore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.RegisterConnected("peer-a", network.DirOutbound)
+	cb.RegisterConnected("peer-a", network.DirOutbound)
+	cb.SetDisconnectReason("peer-a", "test")
+	cb.RegisterDisconnected("peer-a")
+	if rec := cb.Connection("peer-a"); rec == nil || !rec.Connected {
+		t.Fatalf("expected peer to still be connected: %v", rec)
+	}
+	cb.RegisterDisconnected("peer-a")
+	if len(cb.data) != 0 {
+		t.Fatalf("expected disconnected peer to be evicted, got %d cached", len(cb.data))
+	}
+	rec := cb.Connection("peer-a")
+	if rec == nil || rec.Connected || rec.Connections != 1 || rec.DisconnectReason != "test" {
+		t.Fatalf("unexpected record from datastore: %+v", rec)
+	}
+	if len(cb.data) != 0 {
+		t.Fatal("expected lookups of disconnected peers not to be cached")
+	}
+
+	// the history continues from the datastore
+	cb.RegisterConnected("peer-a", network.DirInbound)
+	if err := cb.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cb.data) != 1 {
+		t.Fatal("expected connected peer to stay cached after flush")
+	}
+	cb.RegisterDisconnected("peer-a")
+	if rec := cb.Connection("peer-a"); rec == nil || rec.Connections != 2 || rec.Direction != "inbound" || rec.DisconnectReason != "" {
+		t.Fatalf("unexpected record after reconnect: %+v", rec)
+	}
+}
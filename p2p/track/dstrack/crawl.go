@@ -0,0 +1,91 @@
+package dstrack
+
+import (
+	"encoding/json"
+	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/p2p/track"
+	"sync"
+)
+
+var crawlSuffix = ds.NewKey("/crawl")
+
+type dsCrawlBook struct {
+	ds ds.Datastore
+	// Crawl records are written through to the datastore. Only records that failed to be written are kept,
+	// to retry them with the next flush.
+	lock    sync.Mutex
+	pending map[peer.ID]*track.CrawlRecord
+}
+
+var _ track.CrawlBook = (*dsCrawlBook)(nil)
+
+func NewCrawlBook(store ds.Datastore) (*dsCrawlBook, error) {
+	return &dsCrawlBook{ds: store, pending: make(map[peer.ID]*track.CrawlRecord)}, nil
+}
+
+func (cb *dsCrawlBook) loadCrawl(p peer.ID) (*track.CrawlRecord, error) {
+	key := peerIdToKey(eth2Base, p).Child(crawlSuffix)
+	value, err := cb.ds.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching crawl record from datastore for peer %s: %s\n", p.Pretty(), err)
+	}
+	var rec track.CrawlRecord
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return nil, fmt.Errorf("failed parse crawl record from datastore: %v", err)
+	}
+	return &rec, nil
+}
+
+func (cb *dsCrawlBook) storeCrawl(p peer.ID, rec *track.CrawlRecord) error {
+	key := peerIdToKey(eth2Base, p).Child(crawlSuffix)
+	dat, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed encode crawl record for datastore: %v", err)
+	}
+	if err := cb.ds.Put(key, dat); err != nil {
+		return fmt.Errorf("failed to store crawl record: %v", err)
+	}
+	return nil
+}
+
+func (cb *dsCrawlBook) LastCrawl(id peer.ID) *track.CrawlRecord {
+	cb.lock.Lock()
+	rec, ok := cb.pending[id]
+	cb.lock.Unlock()
+	if ok {
+		return rec
+	}
+	rec, err := cb.loadCrawl(id)
+	if err != nil {
+		return nil
+	}
+	return rec
+}
+
+func (cb *dsCrawlBook) RegisterCrawl(id peer.ID, rec *track.CrawlRecord) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if err := cb.storeCrawl(id, rec); err != nil {
+		cb.pending[id] = rec
+	} else {
+		delete(cb.pending, id)
+	}
+}
+
+func (cb *dsCrawlBook) flush() error {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	for id, rec := range cb.pending {
+		if err := cb.storeCrawl(id, rec); err != nil {
+			return err
+		}
+		delete(cb.pending, id)
+	}
+	return nil
+}
+
+func (cb *dsCrawlBook) Close() error {
+	return cb.flush()
+}
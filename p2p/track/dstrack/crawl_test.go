@@ -0,0 +1,60 @@
+package dstrack
+
+import (
+	"errors"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/protolambda/rumor/p2p/track"
+	"testing"
+)
+
+// failingDatastore fails to write while failing is true
+type failingDatastore struct {
+	ds.Datastore
+	failing bool
+}
+
+func (f *failingDatastore) Put(key ds.Key, value []byte) error {
+	if f.failing {
+		return errors.New("write failed")
+	}
+	return f.Datastore.Put(key, value)
+}
+
+func TestCrawlBookWriteThrough(t *testing.T) {
+	store := &failingDatastore{Datastore: ds.NewMapDatastore()}
+	cb, err := NewCrawlBook(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.RegisterCrawl("peer-a", &track.CrawlRecord{Success: true})
+	if len(cb.pending) != 0 {
+		t.Fatal("expected crawl record to be written through")
+	}
+	other, err := NewCrawlBook(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := other.LastCrawl("peer-a"); rec == nil || !rec.Success {
+		t.Fatalf("expected crawl record in the datastore, got %v", rec)
+	}
+
+	// failed writes are kept, and retried with the next flush
+	store.failing = true
+	cb.RegisterCrawl("peer-b", &track.CrawlRecord{Errors: map[string]string{"dial": "timeout"}})
+	if rec := cb.LastCrawl("peer-b"); rec == nil || rec.Errors["dial"] != "timeout" {
+		t.Fatalf("expected pending crawl record, got %v", rec)
+	}
+	if err := cb.flush(); err == nil {
+		t.Fatal("expected flush to fail")
+	}
+	store.failing = false
+	if err := cb.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cb.pending) != 0 {
+		t.Fatal("expected pending crawl records to be evicted after flush")
+	}
+	if rec := other.LastCrawl("peer-b"); rec == nil || rec.Errors["dial"] != "timeout" {
+		t.Fatalf("expected flushed crawl record in the datastore, got %v", rec)
+	}
+}
@@ -18,10 +18,14 @@ import (
 	"github.com/protolambda/rumor/p2p/types"
 	"github.com/protolambda/zrnt/eth2/beacon"
 	"io"
+	"time"
 )
 
 var eth2Base = ds.NewKey("/eth2")
 
+// FlushInterval is the interval to write the cached peer data of the books to the datastore at.
+const FlushInterval = time.Minute
+
 func peerIdToKey(base ds.Key, p peer.ID) ds.Key {
 	return base.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p)))
 }
@@ -31,6 +35,15 @@ type dsExtendedPeerstore struct {
 	*dsStatusBook
 	*dsMetadataBook
 	*dsENRBook
+	*dsRPCStatsBook
+	*dsGoodbyeBook
+	*dsConnectionBook
+	*dsCrawlBook
+	*dsGossipStatsBook
+
+	// stops the background flushing
+	cancelFlush context.CancelFunc
+	flushDone   chan struct{}
 }
 
 func NewExtendedPeerstore(ctx context.Context, store ds.Batching, opts pstoreds.Options) (track.ExtendedPeerstore, error) {
@@ -50,13 +63,43 @@ func NewExtendedPeerstore(ctx context.Context, store ds.Batching, opts pstoreds.
 	if err != nil {
 		return nil, err
 	}
+	rb, err := NewRPCStatsBook(store)
+	if err != nil {
+		return nil, err
+	}
+	gb, err := NewGoodbyeBook(store)
+	if err != nil {
+		return nil, err
+	}
+	cb, err := NewConnectionBook(store)
+	if err != nil {
+		return nil, err
+	}
+	crb, err := NewCrawlBook(store)
+	if err != nil {
+		return nil, err
+	}
+	gsb, err := NewGossipStatsBook(store)
+	if err != nil {
+		return nil, err
+	}
 
-	return &dsExtendedPeerstore{
-		Peerstore:      ps,
-		dsStatusBook:   sb,
-		dsMetadataBook: mb,
-		dsENRBook:      eb,
-	}, nil
+	flushCtx, cancelFlush := context.WithCancel(ctx)
+	ep := &dsExtendedPeerstore{
+		Peerstore:         ps,
+		dsStatusBook:      sb,
+		dsMetadataBook:    mb,
+		dsENRBook:         eb,
+		dsRPCStatsBook:    rb,
+		dsGoodbyeBook:     gb,
+		dsConnectionBook:  cb,
+		dsCrawlBook:       crb,
+		dsGossipStatsBook: gsb,
+		cancelFlush:       cancelFlush,
+		flushDone:         make(chan struct{}),
+	}
+	go ep.flushEvery(flushCtx, FlushInterval)
+	return ep, nil
 }
 
 var _ track.IdentifyBook = (*dsExtendedPeerstore)(nil)
@@ -89,7 +132,22 @@ type Flusher interface {
 	flush() error
 }
 
-// TODO: start this as a background service, similar to the GC of addrs in the peerstore.
+// flushEvery flushes the books on every interval, until the context is done.
+// Data that fails to be written stays cached, and is retried with the next flush, or on Close.
+func (ep *dsExtendedPeerstore) flushEvery(ctx context.Context, interval time.Duration) {
+	defer close(ep.flushDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = ep.flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (ep *dsExtendedPeerstore) flush() error {
 	var errs []error
 	weakFlush := func(name string, c interface{}) {
@@ -102,6 +160,10 @@ func (ep *dsExtendedPeerstore) flush() error {
 	weakFlush("statusbook", ep.dsStatusBook)
 	weakFlush("metadatabook", ep.dsMetadataBook)
 	weakFlush("enrbook", ep.dsENRBook)
+	weakFlush("rpcstatsbook", ep.dsRPCStatsBook)
+	weakFlush("goodbyebook", ep.dsGoodbyeBook)
+	weakFlush("connectionbook", ep.dsConnectionBook)
+	weakFlush("crawlbook", ep.dsCrawlBook)
 
 	if len(errs) > 0 {
 		return fmt.Errorf("failed while flushing peerstore data; err(s): %q", errs)
@@ -109,7 +171,10 @@ func (ep *dsExtendedPeerstore) flush() error {
 	return nil
 }
 
+// Close stops the background flushing, and flushes and closes the books.
 func (ep *dsExtendedPeerstore) Close() error {
+	ep.cancelFlush()
+	<-ep.flushDone
 	var errs []error
 	weakClose := func(name string, c interface{}) {
 		if cl, ok := c.(io.Closer); ok {
@@ -122,6 +187,10 @@ func (ep *dsExtendedPeerstore) Close() error {
 	weakClose("statusbook", ep.dsStatusBook)
 	weakClose("metadatabook", ep.dsMetadataBook)
 	weakClose("enrbook", ep.dsENRBook)
+	weakClose("rpcstatsbook", ep.dsRPCStatsBook)
+	weakClose("goodbyebook", ep.dsGoodbyeBook)
+	weakClose("connectionbook", ep.dsConnectionBook)
+	weakClose("crawlbook", ep.dsCrawlBook)
 
 	if len(errs) > 0 {
 		return fmt.Errorf("failed while closing peerstore; err(s): %q", errs)
@@ -130,15 +199,15 @@ func (ep *dsExtendedPeerstore) Close() error {
 }
 
 func (ep *dsExtendedPeerstore) GetAllData(id peer.ID) *track.PeerAllData {
-	pub := ep.PubKey(id)
-	secpKey := (pub).(*ic.Secp256k1PublicKey)
-	keyBytes, err := secpKey.Raw()
 	pubStr := ""
-	if err == nil {
-		pubStr = hex.EncodeToString(keyBytes[:])
+	var nodeID enode.ID
+	// eth2 peers use secp256k1 keys, other keys do not have a node ID
+	if secpKey, ok := ep.PubKey(id).(*ic.Secp256k1PublicKey); ok {
+		if keyBytes, err := secpKey.Raw(); err == nil {
+			pubStr = hex.EncodeToString(keyBytes[:])
+		}
+		nodeID = enode.PubkeyToIDV4((*ecdsa.PublicKey)(secpKey))
 	}
-
-	nodeID := enode.PubkeyToIDV4((*ecdsa.PublicKey)(secpKey))
 	protocols, _ := ep.GetProtocols(id)
 	userAgent, _ := ep.UserAgent(id)
 	protVersion, _ := ep.ProtocolVersion(id)
@@ -181,5 +250,9 @@ func (ep *dsExtendedPeerstore) GetAllData(id peer.ID) *track.PeerAllData {
 		ClaimedSeq:      seq,
 		Status:          ep.Status(id),
 		ENR:             en,
+		RPCStats:        ep.RPCStats(id),
+		Goodbye:         ep.LastGoodbye(id),
+		Connection:      ep.Connection(id),
+		Crawl:           ep.LastCrawl(id),
 	}
 }
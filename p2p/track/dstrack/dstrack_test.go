@@ -0,0 +1,43 @@
+package dstrack
+
+import (
+	"context"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p-peerstore/pstoreds"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"testing"
+)
+
+func TestExtendedPeerstoreReopen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := sync.MutexWrap(ds.NewMapDatastore())
+	ep, err := NewExtendedPeerstore(ctx, store, pstoreds.DefaultOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep.RegisterStatus("peer-a", methods.Status{HeadSlot: 42})
+	ep.RegisterMetadata("peer-a", methods.MetaData{SeqNumber: 3})
+	if err := ep.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewExtendedPeerstore(ctx, store, pstoreds.DefaultOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	// loaded from the datastore first, then from the cache
+	for i := 0; i < 2; i++ {
+		if st := reopened.Status("peer-a"); st == nil || st.HeadSlot != 42 {
+			t.Fatalf("expected status to be persisted, got %v", st)
+		}
+	}
+	if md := reopened.Metadata("peer-a"); md == nil || md.SeqNumber != 3 {
+		t.Fatalf("expected metadata to be persisted, got %v", md)
+	}
+	if err := reopened.(*dsExtendedPeerstore).flush(); err != nil {
+		t.Fatal(err)
+	}
+}
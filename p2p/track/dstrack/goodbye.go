@@ -0,0 +1,90 @@
+package dstrack
+
+import (
+	"encoding/json"
+	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/track"
+	"sync"
+	"time"
+)
+
+var goodbyeSuffix = ds.NewKey("/goodbye")
+
+type dsGoodbyeBook struct {
+	ds ds.Datastore
+	// cache goodbye records to not load/store them all the time
+	data sync.Map
+}
+
+var _ track.GoodbyeBook = (*dsGoodbyeBook)(nil)
+
+func NewGoodbyeBook(store ds.Datastore) (*dsGoodbyeBook, error) {
+	return &dsGoodbyeBook{ds: store}, nil
+}
+
+func (gb *dsGoodbyeBook) loadGoodbye(p peer.ID) (*track.GoodbyeRecord, error) {
+	key := peerIdToKey(eth2Base, p).Child(goodbyeSuffix)
+	value, err := gb.ds.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching goodbye from datastore for peer %s: %s\n", p.Pretty(), err)
+	}
+	var rec track.GoodbyeRecord
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return nil, fmt.Errorf("failed parse goodbye from datastore: %v", err)
+	}
+	// cache it
+	gb.data.Store(p, &rec)
+	return &rec, nil
+}
+
+func (gb *dsGoodbyeBook) storeGoodbye(p peer.ID, rec *track.GoodbyeRecord) error {
+	key := peerIdToKey(eth2Base, p).Child(goodbyeSuffix)
+	dat, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed encode goodbye for datastore: %v", err)
+	}
+	if err := gb.ds.Put(key, dat); err != nil {
+		return fmt.Errorf("failed to store goodbye: %v", err)
+	}
+	return nil
+}
+
+func (gb *dsGoodbyeBook) LastGoodbye(id peer.ID) *track.GoodbyeRecord {
+	dat, loaded := gb.data.Load(id)
+	if loaded {
+		return dat.(*track.GoodbyeRecord)
+	} else {
+		// lazy-load goodbye into the db
+		rec, err := gb.loadGoodbye(id)
+		if err != nil {
+			return nil
+		}
+		return rec
+	}
+}
+
+func (gb *dsGoodbyeBook) RegisterGoodbye(id peer.ID, reason methods.Goodbye, received bool) {
+	gb.data.Store(id, &track.GoodbyeRecord{Reason: reason, Received: received, Time: time.Now()})
+}
+
+func (gb *dsGoodbyeBook) flush() error {
+	var clErr error
+	// store all goodbyes to datastore before exiting
+	gb.data.Range(func(key, value interface{}) bool {
+		id := key.(peer.ID)
+		rec := value.(*track.GoodbyeRecord)
+		if err := gb.storeGoodbye(id, rec); err != nil {
+			clErr = err
+			return false
+		}
+		return true
+	})
+	return clErr
+}
+
+func (gb *dsGoodbyeBook) Close() error {
+	return gb.flush()
+}
@@ -0,0 +1,46 @@
+package dstrack
+
+import (
+	"encoding/json"
+	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/protolambda/rumor/p2p/track"
+)
+
+var gossipStatsBase = eth2Base.ChildString("gossip_stats")
+
+// Gossip stats are stored infrequently, so the book writes through to the datastore, without caching.
+type dsGossipStatsBook struct {
+	ds ds.Datastore
+}
+
+var _ track.GossipStatsBook = (*dsGossipStatsBook)(nil)
+
+func NewGossipStatsBook(store ds.Datastore) (*dsGossipStatsBook, error) {
+	return &dsGossipStatsBook{ds: store}, nil
+}
+
+func (gb *dsGossipStatsBook) LoadGossipStats(name string, dest interface{}) (bool, error) {
+	value, err := gb.ds.Get(gossipStatsBase.ChildString(name))
+	if err == ds.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error while fetching gossip stats %s from datastore: %v", name, err)
+	}
+	if err := json.Unmarshal(value, dest); err != nil {
+		return false, fmt.Errorf("failed parse gossip stats %s from datastore: %v", name, err)
+	}
+	return true, nil
+}
+
+func (gb *dsGossipStatsBook) StoreGossipStats(name string, stats interface{}) error {
+	dat, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed encode gossip stats %s for datastore: %v", name, err)
+	}
+	if err := gb.ds.Put(gossipStatsBase.ChildString(name), dat); err != nil {
+		return fmt.Errorf("failed to store gossip stats %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package dstrack
+
+import (
+	ds "github.com/ipfs/go-datastore"
+	"testing"
+)
+
+func TestGossipStatsBook(t *testing.T) {
+	gb, err := NewGossipStatsBook(ds.NewMapDatastore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	type stats struct {
+		Messages uint64 `json:"messages"`
+	}
+	var out stats
+	if ok, err := gb.LoadGossipStats("dups", &out); err != nil || ok {
+		t.Fatalf("expected no stats, got %v, %v", ok, err)
+	}
+	if err := gb.StoreGossipStats("dups", &stats{Messages: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := gb.LoadGossipStats("dups", &out); err != nil || !ok {
+		t.Fatalf("expected stats, got %v, %v", ok, err)
+	}
+	if out.Messages != 3 {
+		t.Fatalf("expected 3 messages, got %d", out.Messages)
+	}
+	if ok, _ := gb.LoadGossipStats("other", &out); ok {
+		t.Fatal("expected stats to be stored by name")
+	}
+}
@@ -56,7 +56,7 @@ func (mb *dsMetadataBook) loadMetadata(p peer.ID) (*methods.MetaData, error) {
 func (mb *dsMetadataBook) storeMetadata(p peer.ID, md *methods.MetaData) error {
 	key := peerIdToKey(eth2Base, p).Child(metadataSuffix)
 	size := zssz.SizeOf(md, methods.MetaDataSSZ)
-	out := bytes.NewBuffer(make([]byte, size, size))
+	out := bytes.NewBuffer(make([]byte, 0, size))
 	if _, err := zssz.Encode(out, md, methods.MetaDataSSZ); err != nil {
 		return fmt.Errorf("failed encode metadata bytes for datastore: %v", err)
 	}
@@ -0,0 +1,145 @@
+package dstrack
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/p2p/track"
+	"strings"
+	"sync"
+)
+
+var rpcStatsSuffix = ds.NewKey("/rpc_stats")
+
+// maxCachedRPCStats is the number of peers to cache stats of.
+// The least recently used stats are written to the datastore when the cache is full.
+const maxCachedRPCStats = 1000
+
+type cachedRPCStats struct {
+	id peer.ID
+	st *track.PeerRPCStats
+}
+
+type dsRPCStatsBook struct {
+	ds ds.Datastore
+	// cache stats, to not load/store them for every request
+	sync.Mutex
+	stats map[peer.ID]*list.Element
+	// least recently used stats at the back
+	order *list.List
+}
+
+var _ track.RPCStatsBook = (*dsRPCStatsBook)(nil)
+
+func NewRPCStatsBook(store ds.Datastore) (*dsRPCStatsBook, error) {
+	return &dsRPCStatsBook{
+		ds:    store,
+		stats: make(map[peer.ID]*list.Element),
+		order: list.New(),
+	}, nil
+}
+
+func (sb *dsRPCStatsBook) loadStats(p peer.ID) (*track.PeerRPCStats, error) {
+	key := peerIdToKey(eth2Base, p).Child(rpcStatsSuffix)
+	value, err := sb.ds.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching rpc stats from datastore for peer %s: %s\n", p.Pretty(), err)
+	}
+	var st track.PeerRPCStats
+	if err := json.Unmarshal(value, &st); err != nil {
+		return nil, fmt.Errorf("failed parse rpc stats from datastore: %v", err)
+	}
+	return &st, nil
+}
+
+func (sb *dsRPCStatsBook) storeStats(p peer.ID, st *track.PeerRPCStats) error {
+	key := peerIdToKey(eth2Base, p).Child(rpcStatsSuffix)
+	dat, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed encode rpc stats for datastore: %v", err)
+	}
+	if err := sb.ds.Put(key, dat); err != nil {
+		return fmt.Errorf("failed to store rpc stats: %v", err)
+	}
+	return nil
+}
+
+// get the cached stats, lazy-loading them from the datastore. Requires the lock.
+func (sb *dsRPCStatsBook) get(id peer.ID) (*track.PeerRPCStats, bool) {
+	if el, ok := sb.stats[id]; ok {
+		sb.order.MoveToFront(el)
+		return el.Value.(*cachedRPCStats).st, true
+	}
+	loaded, err := sb.loadStats(id)
+	if err != nil {
+		return nil, false
+	}
+	sb.put(id, loaded)
+	return loaded, true
+}
+
+// put adds the stats to the cache, and writes back the least recently used stats if the cache is full.
+// Stats that fail to be written stay in the cache. Requires the lock.
+func (sb *dsRPCStatsBook) put(id peer.ID, st *track.PeerRPCStats) {
+	sb.stats[id] = sb.order.PushFront(&cachedRPCStats{id: id, st: st})
+	for sb.order.Len() > maxCachedRPCStats {
+		el := sb.order.Back()
+		evicted := el.Value.(*cachedRPCStats)
+		if err := sb.storeStats(evicted.id, evicted.st); err != nil {
+			// retried with the next eviction or flush
+			return
+		}
+		sb.order.Remove(el)
+		delete(sb.stats, evicted.id)
+	}
+}
+
+func (sb *dsRPCStatsBook) RegisterRPC(rec *track.RPCRecord) {
+	sb.Lock()
+	defer sb.Unlock()
+	st, ok := sb.get(rec.Peer)
+	if !ok {
+		st = new(track.PeerRPCStats)
+		sb.put(rec.Peer, st)
+	}
+	st.Add(rec)
+}
+
+func (sb *dsRPCStatsBook) RPCStats(id peer.ID) *track.PeerRPCStats {
+	sb.Lock()
+	defer sb.Unlock()
+	st, ok := sb.get(id)
+	if !ok {
+		return nil
+	}
+	return st.Copy()
+}
+
+// flush writes the cached stats to the datastore, and evicts them from the cache.
+// Stats that fail to be written stay in the cache, the errors are combined.
+func (sb *dsRPCStatsBook) flush() error {
+	sb.Lock()
+	defer sb.Unlock()
+	var errs []string
+	for el := sb.order.Back(); el != nil; {
+		prev := el.Prev()
+		c := el.Value.(*cachedRPCStats)
+		if err := sb.storeStats(c.id, c.st); err != nil {
+			errs = append(errs, fmt.Sprintf("peer %s: %v", c.id.Pretty(), err))
+		} else {
+			sb.order.Remove(el)
+			delete(sb.stats, c.id)
+		}
+		el = prev
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to flush rpc stats of %d peers: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (sb *dsRPCStatsBook) Close() error {
+	return sb.flush()
+}
@@ -0,0 +1,78 @@
+package dstrack
+
+import (
+	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/p2p/track"
+	"testing"
+)
+
+func TestRPCStatsBookBounded(t *testing.T) {
+	sb, err := NewRPCStatsBook(ds.NewMapDatastore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= maxCachedRPCStats; i++ {
+		sb.RegisterRPC(&track.RPCRecord{Peer: peer.ID(fmt.Sprintf("peer%d", i)), Protocol: "/test/req/1"})
+	}
+	if len(sb.stats) > maxCachedRPCStats {
+		t.Fatalf("expected least recently used stats to be evicted, got %d entries", len(sb.stats))
+	}
+	// evicted stats are loaded back from the datastore
+	sb.RegisterRPC(&track.RPCRecord{Peer: "peer0", Protocol: "/test/req/1"})
+	if st := sb.RPCStats("peer0"); st == nil {
+		t.Fatal("expected stats of evicted peer")
+	} else if n := st.Outbound["/test/req/1"].Count; n != 2 {
+		t.Fatalf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestRPCStatsBookLRU(t *testing.T) {
+	store := &failingDatastore{Datastore: ds.NewMapDatastore()}
+	sb, err := NewRPCStatsBook(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxCachedRPCStats; i++ {
+		sb.RegisterRPC(&track.RPCRecord{Peer: peer.ID(fmt.Sprintf("peer%d", i)), Protocol: "/test/req/1"})
+	}
+	// peer0 is used again, peer1 becomes the least recently used
+	sb.RegisterRPC(&track.RPCRecord{Peer: "peer0", Protocol: "/test/req/1"})
+	sb.RegisterRPC(&track.RPCRecord{Peer: "new", Protocol: "/test/req/1"})
+	if _, ok := sb.stats["peer0"]; !ok {
+		t.Fatal("expected recently used stats to stay cached")
+	}
+	if _, ok := sb.stats["peer1"]; ok {
+		t.Fatal("expected least recently used stats to be evicted")
+	}
+	if _, err := store.Get(peerIdToKey(eth2Base, "peer1").Child(rpcStatsSuffix)); err != nil {
+		t.Fatalf("expected evicted stats to be written back: %v", err)
+	}
+	if _, err := store.Get(peerIdToKey(eth2Base, "peer2").Child(rpcStatsSuffix)); err != ds.ErrNotFound {
+		t.Fatalf("expected cached stats not to be written yet, got %v", err)
+	}
+
+	// failed writes keep the stats cached, and do not stop the flush of the others
+	store.failing = true
+	sb.RegisterRPC(&track.RPCRecord{Peer: "new2", Protocol: "/test/req/1"})
+	if _, ok := sb.stats["peer2"]; !ok {
+		t.Fatal("expected stats that failed to be written to stay cached")
+	}
+	if err := sb.flush(); err == nil {
+		t.Fatal("expected flush to fail")
+	}
+	if len(sb.stats) != maxCachedRPCStats+1 {
+		t.Fatalf("expected all stats to stay cached, got %d", len(sb.stats))
+	}
+	store.failing = false
+	if err := sb.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sb.stats) != 0 || sb.order.Len() != 0 {
+		t.Fatal("expected cache to be empty after flush")
+	}
+	if st := sb.RPCStats("peer0"); st == nil || st.Outbound["/test/req/1"].Count != 2 {
+		t.Fatalf("expected stats of peer0 in the datastore, got %v", st)
+	}
+}
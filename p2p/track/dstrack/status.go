@@ -36,14 +36,14 @@ func (sb *dsStatusBook) loadStatus(p peer.ID) (*methods.Status, error) {
 		return nil, fmt.Errorf("failed parse status bytes from datastore: %v", err)
 	}
 	// cache it
-	sb.data.Store(p, status)
+	sb.data.Store(p, &status)
 	return &status, nil
 }
 
 func (sb *dsStatusBook) storeStatus(p peer.ID, st *methods.Status) error {
 	key := peerIdToKey(eth2Base, p).Child(statusSuffix)
 	size := zssz.SizeOf(st, methods.StatusSSZ)
-	out := bytes.NewBuffer(make([]byte, size, size))
+	out := bytes.NewBuffer(make([]byte, 0, size))
 	if _, err := zssz.Encode(out, st, methods.StatusSSZ); err != nil {
 		return fmt.Errorf("failed encode status bytes for datastore: %v", err)
 	}
@@ -0,0 +1,472 @@
+// Package filter implements a small query language over peer data, to select peers by their peerstore data.
+//
+// A filter is a list of conditions, all conditions must match. Conditions refer to fields of the JSON form of
+// the peer data (see track.PeerAllData), nested fields are separated with dots:
+//
+//	status.head_slot>1000    numeric comparison: = != > >= < <=, durations like 100ms are compared to nanoseconds
+//	user_agent~Lighthouse    regular expression match
+//	fork_digest=e7a75d5a     string equality, case-insensitive, ignoring a 0x prefix
+//	attnets has 12           bit 12 of a bitfield, or an element of a list
+//	connected, !connected    the field is set (non-zero, non-empty, true), or not
+//
+// The ENR fields have short names: fork_digest, next_fork_version, next_fork_epoch and attnets.
+// The "connected" field is added to the peer data, to select peers that are currently connected.
+// Conditions on fields that a peer does not have do not match.
+package filter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/p2p/addrutil"
+	"github.com/protolambda/rumor/p2p/track"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var aliases = map[string]string{
+	"fork_digest":       "enr_fork_digest",
+	"next_fork_version": "enr_next_fork_version",
+	"next_fork_epoch":   "enr_next_fork_epoch",
+	"attnets":           "enr_attnets",
+}
+
+// Peer is the peer data to match a filter against
+type Peer struct {
+	Data *track.PeerAllData
+	// If the peer is currently connected
+	Connected bool
+
+	fields map[string]interface{}
+}
+
+func (p *Peer) lookup(path string) (interface{}, bool) {
+	if p.fields == nil {
+		p.fields = make(map[string]interface{})
+		if p.Data != nil {
+			dat, err := json.Marshal(p.Data)
+			if err == nil {
+				dec := json.NewDecoder(bytes.NewReader(dat))
+				dec.UseNumber()
+				_ = dec.Decode(&p.fields)
+			}
+		}
+		p.fields["connected"] = p.Connected
+	}
+	if full, ok := aliases[path]; ok {
+		path = full
+	}
+	var v interface{} = p.fields
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok || v == nil {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+type condition interface {
+	match(p *Peer) bool
+	String() string
+}
+
+type op string
+
+const (
+	opEq    op = "="
+	opNotEq op = "!="
+	opGt    op = ">"
+	opGtEq  op = ">="
+	opLt    op = "<"
+	opLtEq  op = "<="
+	opMatch op = "~"
+)
+
+// operators in order of parsing, longer operators first
+var operators = []op{opGtEq, opLtEq, opNotEq, opEq, opGt, opLt, opMatch}
+
+type compareCond struct {
+	field string
+	op    op
+	value string
+	re    *regexp.Regexp
+}
+
+func (c *compareCond) String() string {
+	return c.field + string(c.op) + c.value
+}
+
+func (c *compareCond) match(p *Peer) bool {
+	v, ok := p.lookup(c.field)
+	if !ok {
+		return false
+	}
+	if list, ok := v.([]interface{}); ok {
+		// lists match if any of the elements matches
+		for _, elem := range list {
+			if c.matchValue(elem) {
+				return true
+			}
+		}
+		return false
+	}
+	return c.matchValue(v)
+}
+
+func (c *compareCond) matchValue(v interface{}) bool {
+	if c.op == opMatch {
+		return c.re.MatchString(fmt.Sprintf("%v", v))
+	}
+	var cmp int
+	switch x := v.(type) {
+	case json.Number:
+		var ok bool
+		if cmp, ok = compareNumber(x, c.value); !ok {
+			return false
+		}
+	case bool:
+		b, err := strconv.ParseBool(c.value)
+		if err != nil || (c.op != opEq && c.op != opNotEq) {
+			return false
+		}
+		if x == b {
+			cmp = 0
+		} else {
+			cmp = 1
+		}
+	case string:
+		if c.op == opEq || c.op == opNotEq {
+			if normalize(x) == normalize(c.value) {
+				cmp = 0
+			} else {
+				cmp = 1
+			}
+		} else {
+			cmp = strings.Compare(x, c.value)
+		}
+	default:
+		return false
+	}
+	switch c.op {
+	case opEq:
+		return cmp == 0
+	case opNotEq:
+		return cmp != 0
+	case opGt:
+		return cmp > 0
+	case opGtEq:
+		return cmp >= 0
+	case opLt:
+		return cmp < 0
+	case opLtEq:
+		return cmp <= 0
+	}
+	return false
+}
+
+func normalize(v string) string {
+	return strings.TrimPrefix(strings.ToLower(v), "0x")
+}
+
+// compareNumber compares the number to the value, as integers if possible, or as duration in nanoseconds.
+func compareNumber(n json.Number, value string) (int, bool) {
+	if a, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		if b, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return compareInts(a, b), true
+		}
+		if b, err := time.ParseDuration(value); err == nil {
+			return compareInts(a, int64(b)), true
+		}
+	}
+	if a, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		if b, err := strconv.ParseUint(value, 10, 64); err == nil {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+	a, err := n.Float64()
+	if err != nil {
+		return 0, false
+	}
+	b, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case a < b:
+		return -1, true
+	case a > b:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type hasCond struct {
+	field string
+	value string
+}
+
+func (c *hasCond) String() string {
+	return c.field + " has " + c.value
+}
+
+func (c *hasCond) match(p *Peer) bool {
+	v, ok := p.lookup(c.field)
+	if !ok {
+		return false
+	}
+	switch x := v.(type) {
+	case []interface{}:
+		for _, elem := range x {
+			if normalize(fmt.Sprintf("%v", elem)) == normalize(c.value) {
+				return true
+			}
+		}
+		return false
+	case string:
+		// a hex-encoded bitfield, bits are little-endian within each byte
+		bit, err := strconv.ParseUint(c.value, 10, 64)
+		if err != nil {
+			return false
+		}
+		dat, err := hex.DecodeString(strings.TrimPrefix(x, "0x"))
+		if err != nil || bit/8 >= uint64(len(dat)) {
+			return false
+		}
+		return dat[bit/8]&(1<<(bit%8)) != 0
+	}
+	return false
+}
+
+type setCond struct {
+	field  string
+	negate bool
+}
+
+func (c *setCond) String() string {
+	if c.negate {
+		return "!" + c.field
+	}
+	return c.field
+}
+
+func (c *setCond) match(p *Peer) bool {
+	v, ok := p.lookup(c.field)
+	set := ok
+	if ok {
+		switch x := v.(type) {
+		case bool:
+			set = x
+		case json.Number:
+			f, err := x.Float64()
+			set = err == nil && f != 0
+		case string:
+			set = x != ""
+		case []interface{}:
+			set = len(x) > 0
+		}
+	}
+	return set != c.negate
+}
+
+// Filter selects peers that match all of its conditions
+type Filter struct {
+	conds []condition
+}
+
+// Parse parses filter expressions. Each expression may contain multiple conditions separated by whitespace,
+// a "has" condition may be split over multiple expressions, e.g. the separate arguments "attnets", "has", "12".
+func Parse(exprs ...string) (*Filter, error) {
+	var tokens []string
+	for _, expr := range exprs {
+		tokens = append(tokens, strings.Fields(expr)...)
+	}
+	f := new(Filter)
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if i+1 < len(tokens) && tokens[i+1] == "has" {
+			if i+2 >= len(tokens) {
+				return nil, fmt.Errorf("missing value of condition '%s has'", tok)
+			}
+			f.conds = append(f.conds, &hasCond{field: tok, value: tokens[i+2]})
+			i += 2
+			continue
+		}
+		cond, err := parseCondition(tok)
+		if err != nil {
+			return nil, err
+		}
+		f.conds = append(f.conds, cond)
+	}
+	return f, nil
+}
+
+func parseCondition(tok string) (condition, error) {
+	for _, o := range operators {
+		idx := strings.Index(tok, string(o))
+		if idx < 0 {
+			continue
+		}
+		// the first operator in the expression wins, e.g. "a=b>c" compares a to "b>c"
+		if other := firstOperator(tok); other < idx {
+			continue
+		}
+		field, value := tok[:idx], tok[idx+len(o):]
+		if field == "" {
+			return nil, fmt.Errorf("missing field in condition '%s'", tok)
+		}
+		cond := &compareCond{field: field, op: o, value: value}
+		if o == opMatch {
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression in condition '%s': %v", tok, err)
+			}
+			cond.re = re
+		}
+		return cond, nil
+	}
+	if strings.HasPrefix(tok, "!") {
+		return &setCond{field: tok[1:], negate: true}, nil
+	}
+	return &setCond{field: tok}, nil
+}
+
+// firstOperator returns the index of the first operator character in the token
+func firstOperator(tok string) int {
+	idx := strings.IndexAny(tok, "=!<>~")
+	if idx < 0 {
+		return len(tok)
+	}
+	return idx
+}
+
+// Empty if the filter has no conditions, and matches any peer
+func (f *Filter) Empty() bool {
+	return len(f.conds) == 0
+}
+
+func (f *Filter) Match(p *Peer) bool {
+	for _, c := range f.conds {
+		if !c.match(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Filter) String() string {
+	out := make([]string, len(f.conds))
+	for i, c := range f.conds {
+		out[i] = c.String()
+	}
+	return strings.Join(out, " ")
+}
+
+// Query selects, sorts and limits peers.
+type Query struct {
+	Filter *Filter
+	// Field to sort by, not sorted if empty. Peers without the field are sorted last.
+	SortBy string
+	// Sort in descending order
+	Desc bool
+	// Max number of peers to select, 0 for no limit
+	Limit int
+}
+
+// Select the peers that match the query. The connected function may be nil if no peers are connected.
+func (q *Query) Select(store track.AllDataGetter, ids []peer.ID, connected func(id peer.ID) bool) []*Peer {
+	var out []*Peer
+	for _, id := range ids {
+		p := &Peer{Data: store.GetAllData(id)}
+		if connected != nil {
+			p.Connected = connected(id)
+		}
+		if q.Filter == nil || q.Filter.Match(p) {
+			out = append(out, p)
+		}
+	}
+	if q.SortBy != "" {
+		sort.SliceStable(out, func(i, j int) bool {
+			a, aOk := out[i].lookup(q.SortBy)
+			b, bOk := out[j].lookup(q.SortBy)
+			if !aOk || !bOk {
+				return aOk && !bOk
+			}
+			cmp := compareValues(a, b)
+			if q.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out
+}
+
+func compareValues(a, b interface{}) int {
+	if an, ok := a.(json.Number); ok {
+		if bn, ok := b.(json.Number); ok {
+			if cmp, ok := compareNumber(an, string(bn)); ok {
+				return cmp
+			}
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// IDs returns the peer IDs of the selected peers
+func IDs(peers []*Peer) []peer.ID {
+	out := make([]peer.ID, len(peers))
+	for i, p := range peers {
+		out[i] = p.Data.PeerID
+	}
+	return out
+}
+
+// ENRPeer builds the peer data of a node from just its ENR, to filter discovered nodes before they are added.
+func ENRPeer(n *enode.Node) *Peer {
+	dat := &track.PeerAllData{
+		PeerID: addrutil.PeerIDFromPubkey(n.Pubkey()),
+		NodeID: n.ID(),
+		ENR:    n,
+	}
+	if eth2, ok, err := addrutil.ParseEnrEth2Data(n); err == nil && ok {
+		dat.ForkDigest = &eth2.ForkDigest
+		dat.NextForkVersion = &eth2.NextForkVersion
+		dat.NextForkEpoch = &eth2.NextForkEpoch
+	}
+	if attnets, ok, err := addrutil.ParseEnrAttnets(n); err == nil && ok {
+		dat.Attnets = attnets
+	}
+	return &Peer{Data: dat}
+}
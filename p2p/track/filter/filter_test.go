@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/types"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"testing"
+	"time"
+)
+
+func TestFilterMatch(t *testing.T) {
+	digest := beacon.ForkDigest{0xe7, 0xa7, 0x5d, 0x5a}
+	var attnets types.AttnetBits
+	attnets[1] = 1 << 4 // subnet 12
+	p := &Peer{
+		Data: &track.PeerAllData{
+			UserAgent:  "Lighthouse/v0.2.0",
+			Protocols:  []string{"/ipfs/id/1.0.0", "/ipfs/ping/1.0.0"},
+			Latency:    50 * time.Millisecond,
+			ForkDigest: &digest,
+			Attnets:    &attnets,
+			Status:     &methods.Status{HeadSlot: 1234},
+		},
+		Connected: true,
+	}
+	for expr, expected := range map[string]bool{
+		"fork_digest=e7a75d5a":                         true,
+		"fork_digest=0xE7A75D5A":                       true,
+		"fork_digest!=e7a75d5a":                        false,
+		"status.head_slot>1000":                        true,
+		"status.head_slot<=1000":                       false,
+		"status.head_slot>=1234 status.head_slot<1235": true,
+		"user_agent~Lighthouse":                        true,
+		"user_agent~^Prysm":                            false,
+		"attnets has 12":                               true,
+		"attnets has 13":                               false,
+		"protocols has /ipfs/ping/1.0.0":               true,
+		"latency<100ms":                                true,
+		"connected":                                    true,
+		"!connected":                                   false,
+		"metadata.seq_number>0":                        false,
+		"!metadata":                                    true,
+	} {
+		f, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if f.Match(p) != expected {
+			t.Errorf("%s: expected match to be %v", expr, expected)
+		}
+	}
+	// a "has" condition may be split over arguments
+	if f, err := Parse("attnets", "has", "12"); err != nil || !f.Match(p) {
+		t.Errorf("expected split 'has' condition to match: %v", err)
+	}
+	if _, err := Parse("attnets has"); err == nil {
+		t.Error("expected missing value error")
+	}
+	if _, err := Parse("user_agent~("); err == nil {
+		t.Error("expected invalid regex error")
+	}
+}
@@ -3,6 +3,7 @@ package track
 import (
 	"encoding/json"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
 	"github.com/protolambda/rumor/p2p/rpc/methods"
@@ -41,6 +42,84 @@ type MetadataBook interface {
 	RegisterMetadata(id peer.ID, md methods.MetaData) (newer bool)
 }
 
+type RPCStatsBook interface {
+	// RegisterRPC adds the request to the aggregate request stats of the peer
+	RegisterRPC(rec *RPCRecord)
+	// RPCStats returns a copy of the request stats of the peer, may be nil if there are none
+	RPCStats(id peer.ID) *PeerRPCStats
+}
+
+// GoodbyeRecord describes the last goodbye sent to or received from a peer
+type GoodbyeRecord struct {
+	Reason methods.Goodbye `json:"reason"`
+	// True if the goodbye was received from the peer, false if it was sent by us.
+	Received bool      `json:"received"`
+	Time     time.Time `json:"time"`
+}
+
+type GoodbyeBook interface {
+	// RegisterGoodbye records a goodbye sent to or received from the peer
+	RegisterGoodbye(id peer.ID, reason methods.Goodbye, received bool)
+	// LastGoodbye returns the last goodbye with the peer, may be nil if there was none
+	LastGoodbye(id peer.ID) *GoodbyeRecord
+}
+
+// ConnectionRecord is the connection history with a peer
+type ConnectionRecord struct {
+	// Time of the first connection with the peer
+	FirstSeen        time.Time `json:"first_seen"`
+	LastConnected    time.Time `json:"last_connected"`
+	LastDisconnected time.Time `json:"last_disconnected,omitempty"`
+	// Total time connected, including the current connection
+	TotalConnected time.Duration `json:"total_connected"`
+	// Number of times the peer got connected, simultaneous connections count once
+	Connections uint64 `json:"connections"`
+	// Direction of the last connection: "inbound", "outbound" or "unknown"
+	Direction string `json:"direction"`
+	// Reason of the last disconnect, empty if unknown
+	DisconnectReason string `json:"disconnect_reason,omitempty"`
+	// True if currently connected
+	Connected bool `json:"connected"`
+}
+
+type ConnectionBook interface {
+	// RegisterConnected records a new connection with the peer
+	RegisterConnected(id peer.ID, dir network.Direction)
+	// RegisterDisconnected records a closed connection with the peer.
+	// The peer is only disconnected once all of its connections are closed.
+	RegisterDisconnected(id peer.ID)
+	// SetDisconnectReason sets the reason to record on the next disconnect of the peer
+	SetDisconnectReason(id peer.ID, reason string)
+	// Connection returns a copy of the connection history of the peer, may be nil if it never connected
+	Connection(id peer.ID) *ConnectionRecord
+}
+
+// CrawlRecord describes the last crawl of a peer: a dial, followed by identify, status and metadata requests
+type CrawlRecord struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	// True if all steps succeeded
+	Success bool `json:"success"`
+	// Failed step ("dial", "identify", "status" or "metadata") -> failure reason.
+	// The other steps are skipped if the dial fails.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type CrawlBook interface {
+	// RegisterCrawl records the result of a crawl of the peer
+	RegisterCrawl(id peer.ID, rec *CrawlRecord)
+	// LastCrawl returns the last crawl of the peer, may be nil if it was never crawled
+	LastCrawl(id peer.ID) *CrawlRecord
+}
+
+// GossipStatsBook stores aggregate gossip stats that are not specific to a peer, e.g. duplicate message counters.
+type GossipStatsBook interface {
+	// LoadGossipStats decodes the stats stored under the name into dest. It returns false if there are none.
+	LoadGossipStats(name string, dest interface{}) (bool, error)
+	// StoreGossipStats stores the stats under the name, replacing any previous stats.
+	StoreGossipStats(name string, stats interface{}) error
+}
+
 type PeerAllData struct {
 	PeerID peer.ID  `json:"peer_id"`
 	NodeID enode.ID `json:"node_id"`
@@ -68,6 +147,14 @@ type PeerAllData struct {
 	Status *methods.Status `json:"status,omitempty"`
 	// Latest ENR
 	ENR *enode.Node `json:"enr,omitempty"`
+	// Aggregate stats of requests with the peer
+	RPCStats *PeerRPCStats `json:"rpc_stats,omitempty"`
+	// Last goodbye sent or received
+	Goodbye *GoodbyeRecord `json:"goodbye,omitempty"`
+	// Connection history
+	Connection *ConnectionRecord `json:"connection,omitempty"`
+	// Last crawl result
+	Crawl *CrawlRecord `json:"crawl,omitempty"`
 }
 
 func (p *PeerAllData) String() string {
@@ -91,6 +178,10 @@ type ExtendedPeerstore interface {
 	StatusBook
 	MetadataBook
 	ENRBook
+	RPCStatsBook
+	GoodbyeBook
+	ConnectionBook
+	CrawlBook
+	GossipStatsBook
 	AllDataGetter
-	// TODO: maybe track when we've last been connected to a peer?
 }
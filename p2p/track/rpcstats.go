@@ -0,0 +1,178 @@
+package track
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"time"
+)
+
+// RPCRecord describes a single request, made or served.
+type RPCRecord struct {
+	Peer     peer.ID
+	Protocol protocol.ID
+	// True if the request was served, false if it was made by us.
+	Inbound bool
+	Start   time.Time
+	// Time until the first byte of the response (the result code of the first chunk). Zero if there was no response.
+	FirstChunk time.Duration
+	// Time until the request was completed, including any failure.
+	Duration time.Duration
+	BytesIn  uint64
+	BytesOut uint64
+	// Empty if there was no error. E.g. "timeout", "reset", "server_error"
+	ErrClass string
+}
+
+// RPCStats aggregates request records
+type RPCStats struct {
+	Count uint64 `json:"count"`
+	// Count of requests with a response
+	Responded uint64 `json:"responded"`
+	// Error class -> count
+	Errors map[string]uint64 `json:"errors,omitempty"`
+
+	TotalFirstChunk time.Duration `json:"total_first_chunk"`
+	TotalDuration   time.Duration `json:"total_duration"`
+	MaxDuration     time.Duration `json:"max_duration"`
+
+	BytesIn  uint64 `json:"bytes_in"`
+	BytesOut uint64 `json:"bytes_out"`
+
+	Last time.Time `json:"last"`
+}
+
+func (s *RPCStats) Add(rec *RPCRecord) {
+	s.Count += 1
+	if rec.FirstChunk != 0 {
+		s.Responded += 1
+		s.TotalFirstChunk += rec.FirstChunk
+	}
+	if rec.ErrClass != "" {
+		if s.Errors == nil {
+			s.Errors = make(map[string]uint64)
+		}
+		s.Errors[rec.ErrClass] += 1
+	}
+	s.TotalDuration += rec.Duration
+	if rec.Duration > s.MaxDuration {
+		s.MaxDuration = rec.Duration
+	}
+	s.BytesIn += rec.BytesIn
+	s.BytesOut += rec.BytesOut
+	if end := rec.Start.Add(rec.Duration); end.After(s.Last) {
+		s.Last = end
+	}
+}
+
+func (s *RPCStats) Merge(other *RPCStats) {
+	s.Count += other.Count
+	s.Responded += other.Responded
+	for k, v := range other.Errors {
+		if s.Errors == nil {
+			s.Errors = make(map[string]uint64)
+		}
+		s.Errors[k] += v
+	}
+	s.TotalFirstChunk += other.TotalFirstChunk
+	s.TotalDuration += other.TotalDuration
+	if other.MaxDuration > s.MaxDuration {
+		s.MaxDuration = other.MaxDuration
+	}
+	s.BytesIn += other.BytesIn
+	s.BytesOut += other.BytesOut
+	if other.Last.After(s.Last) {
+		s.Last = other.Last
+	}
+}
+
+// ErrCount is the total count of errors, of any class
+func (s *RPCStats) ErrCount() (out uint64) {
+	for _, v := range s.Errors {
+		out += v
+	}
+	return
+}
+
+// AvgFirstChunk is the average time to the first response chunk, of the requests with a response.
+func (s *RPCStats) AvgFirstChunk() time.Duration {
+	if s.Responded == 0 {
+		return 0
+	}
+	return s.TotalFirstChunk / time.Duration(s.Responded)
+}
+
+func (s *RPCStats) AvgDuration() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Count)
+}
+
+func (s *RPCStats) Data() map[string]interface{} {
+	return map[string]interface{}{
+		"count":           s.Count,
+		"responded":       s.Responded,
+		"errors":          s.Errors,
+		"avg_first_chunk": s.AvgFirstChunk().String(),
+		"avg_duration":    s.AvgDuration().String(),
+		"max_duration":    s.MaxDuration.String(),
+		"bytes_in":        s.BytesIn,
+		"bytes_out":       s.BytesOut,
+	}
+}
+
+// PeerRPCStats aggregates the requests with a single peer, per protocol.
+type PeerRPCStats struct {
+	// Requests made by us
+	Outbound map[protocol.ID]*RPCStats `json:"outbound,omitempty"`
+	// Requests served by us
+	Inbound map[protocol.ID]*RPCStats `json:"inbound,omitempty"`
+}
+
+func (ps *PeerRPCStats) Add(rec *RPCRecord) {
+	m := &ps.Outbound
+	if rec.Inbound {
+		m = &ps.Inbound
+	}
+	if *m == nil {
+		*m = make(map[protocol.ID]*RPCStats)
+	}
+	s, ok := (*m)[rec.Protocol]
+	if !ok {
+		s = new(RPCStats)
+		(*m)[rec.Protocol] = s
+	}
+	s.Add(rec)
+}
+
+// Copy returns a deep copy of the stats
+func (ps *PeerRPCStats) Copy() *PeerRPCStats {
+	out := new(PeerRPCStats)
+	cp := func(m map[protocol.ID]*RPCStats) map[protocol.ID]*RPCStats {
+		if m == nil {
+			return nil
+		}
+		res := make(map[protocol.ID]*RPCStats, len(m))
+		for k, v := range m {
+			var s RPCStats
+			s.Merge(v)
+			res[k] = &s
+		}
+		return res
+	}
+	out.Outbound = cp(ps.Outbound)
+	out.Inbound = cp(ps.Inbound)
+	return out
+}
+
+// Total merges the stats of all protocols, in the requested direction.
+func (ps *PeerRPCStats) Total(inbound bool) (out RPCStats) {
+	m := ps.Outbound
+	if inbound {
+		m = ps.Inbound
+	}
+	for _, s := range m {
+		out.Merge(s)
+	}
+	return
+}